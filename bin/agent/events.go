@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
+)
+
+// JSONLSink writes the consensus events to a writer, one JSON object per
+// line. Events that cannot be written are discarded.
+type JSONLSink struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewJSONLSink creates a sink writing events to the provided writer.
+func NewJSONLSink(w io.Writer) *JSONLSink {
+	return &JSONLSink{encoder: json.NewEncoder(w)}
+}
+
+// Emit writes an event as a JSON line.
+func (s *JSONLSink) Emit(event *consensus.Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.encoder.Encode(event); err != nil {
+		log.Println("Could not write event:", err)
+	}
+}
@@ -9,20 +9,41 @@ import (
 type KeySet struct {
 	PrivateKeys []crypto.PrivateKey
 	PublicKeys  []crypto.PublicKey
+
+	// Whether the keys are BLS keys
+	BLS bool
 }
 
-func DeterministicKeySet(seed int64, numProcess int) *KeySet {
+func DeterministicKeySet(seed int64, numProcess int, bls bool) *KeySet {
 	set := &KeySet{
 		PrivateKeys: make([]crypto.PrivateKey, numProcess),
 		PublicKeys:  make([]crypto.PublicKey, numProcess),
+		BLS:         bls,
 	}
 	// Secret: [seed (8 bytes), processId (4 bytes)]
 	secret := make([]byte, 8+4)
 	binary.LittleEndian.PutUint64(secret, uint64(seed))
 	for id := 0; id < numProcess; id++ {
 		binary.LittleEndian.PutUint32(secret[8:], uint32(id))
-		set.PrivateKeys[id] = crypto.GeneratePrivateKeyFromSecret(secret)
+		set.PrivateKeys[id] = generateKey(secret, bls)
 		set.PublicKeys[id] = set.PrivateKeys[id].PubKey()
 	}
 	return set
 }
+
+// Generates an ed25519 or a BLS private key from a secret.
+func generateKey(secret []byte, bls bool) crypto.PrivateKey {
+	if bls {
+		return crypto.GenerateBLSPrivateKeyFromSecret(secret)
+	}
+	return crypto.GeneratePrivateKeyFromSecret(secret)
+}
+
+// OperatorKey generates the key of the operator, which signs the
+// reconfigurations of the validator set.
+// Secret: [seed (8 bytes), "operator"]
+func OperatorKey(seed int64) crypto.PrivateKey {
+	secret := make([]byte, 8, 8+len("operator"))
+	binary.LittleEndian.PutUint64(secret, uint64(seed))
+	return crypto.GeneratePrivateKeyFromSecret(append(secret, "operator"...))
+}
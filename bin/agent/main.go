@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"math/rand"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"dslab.inf.usi.ch/tendermint"
+	"dslab.inf.usi.ch/tendermint/consensus"
 	"dslab.inf.usi.ch/tendermint/net"
 	"dslab.inf.usi.ch/tendermint/net/gossip"
 	"dslab.inf.usi.ch/tendermint/net/libp2p"
@@ -17,6 +24,8 @@ import (
 var clientMode bool
 var debug bool
 var sd bool
+var ec bool
+var blsCerts bool
 var zone string
 var advertiseProxy bool
 var randomSeed int64
@@ -43,14 +52,33 @@ var bigDelta int
 var fastOpt bool
 var coolTime int
 
+var adaptiveDelays bool
+var smallDeltaMin, smallDeltaMax int
+var bigDeltaMin, bigDeltaMax int
+
 var numByzantines int
 var byzTime int
 var byzAttack string
+var byzPartition string
+var byzPower float64
 
 var maxEpoch int64
 
 var chunksNumber int
 
+var walFile string
+var eventsFile string
+var statusAddr string
+var metricsAddr string
+var verifierThreads int
+var verifierBatch int
+var priorityDelivery bool
+var leaderPolicy string
+
+var maxActiveEpochs int64
+var numJoiners int
+var reconfigSchedule string
+
 var log net.Log
 var cproxy *proxy.Proxy
 
@@ -60,6 +88,8 @@ func init() {
 	flag.BoolVar(&clientMode, "client", false, "Operate in client-mode.")
 	flag.BoolVar(&debug, "debug", false, "Enables debug.")
 	flag.BoolVar(&sd, "sd", false, "Enables separate dissemination")
+	flag.BoolVar(&ec, "ec", false, "Enables erasure-coded dissemination")
+	flag.BoolVar(&blsCerts, "bls", false, "Enables BLS keys and aggregate certificates")
 	flag.Int64Var(&eid, "e", 0, "Experiment ID.")
 	flag.IntVar(&pid, "i", -1, "Process ID.")
 	flag.IntVar(&n, "n", 0, "Number of processes.")
@@ -68,8 +98,10 @@ func init() {
 	flag.BoolVar(&fastOpt, "fast", false, "Enable FastAlter optimization. ")
 
 	flag.IntVar(&numByzantines, "byz", 0, "Number of byzantines.")
-	flag.IntVar(&byzTime, "byzTime", 0, "Time a byzantine leader should wait.")
-	flag.StringVar(&byzAttack, "attack", "silence", "Byzantine attack.")
+	flag.IntVar(&byzTime, "byzTime", 0, "Time in milliseconds byzantines delay their proposals.")
+	flag.StringVar(&byzAttack, "attack", consensus.HONEST, "Byzantine attack: "+strings.Join(consensus.Attacks(), ", ")+".")
+	flag.StringVar(&byzPartition, "byzPartition", "", "Comma-separated IDs of the processes receiving the first proposal of an equivocating byzantine.")
+	flag.Float64Var(&byzPower, "byzPower", 0, "Fraction of the voting power held by byzantines. When unset, every process has the same power.")
 
 	// Agent setup
 	flag.IntVar(&capacity, "cap", 1024, "Capacity of all storages.")
@@ -77,6 +109,13 @@ func init() {
 	flag.IntVar(&smallDelta, "s-delta", 150, "Sync delta in milliseconds.")
 	flag.IntVar(&bigDelta, "b-delta", 1000, "Sync delta in milliseconds.")
 	flag.IntVar(&coolTime, "cool", 10, "Cool down time in seconds.")
+	flag.IntVar(&verifierThreads, "vthreads", 1, "Number of threads verifying message signatures.")
+	flag.IntVar(&verifierBatch, "vbatch", 0, "Verifies in batch the signatures of messages with more signatures. When unset, signatures are verified one by one.")
+	flag.BoolVar(&adaptiveDelays, "adaptive", false, "Adapts the sync deltas to the observed delays.")
+	flag.IntVar(&smallDeltaMin, "s-delta-min", 0, "Minimum adapted small delta in milliseconds.")
+	flag.IntVar(&smallDeltaMax, "s-delta-max", 0, "Maximum adapted small delta in milliseconds.")
+	flag.IntVar(&bigDeltaMin, "b-delta-min", 0, "Minimum adapted big delta in milliseconds.")
+	flag.IntVar(&bigDeltaMax, "b-delta-max", 0, "Maximum adapted big delta in milliseconds.")
 	// Host and discovery setup
 	flag.StringVar(&listenAddr, "l", "",
 		"Host listen adddress in Multiaddr format")
@@ -92,6 +131,14 @@ func init() {
 	flag.StringVar(&topology, "topology", "", "Topology of the agents in the experiment.")
 	flag.Int64Var(&maxEpoch, "maxEpoch", 100, "Maximum number of epochs to run in the experiment.")
 	flag.IntVar(&chunksNumber, "cNum", 64, "Number of chunks.")
+	flag.StringVar(&walFile, "wal", "", "Write-ahead log file, enables recovery after a restart.")
+	flag.StringVar(&eventsFile, "events", "", "File receiving the consensus events as JSON lines.")
+	flag.StringVar(&statusAddr, "status", "", "Local address serving the agent status as JSON, e.g., 'localhost:8080'.")
+	flag.StringVar(&metricsAddr, "metrics", "", "Local address serving the agent metrics in Prometheus format, e.g., 'localhost:9100'.")
+	flag.StringVar(&leaderPolicy, "leader", "round-robin", "Leader policy (round-robin, random, reputation).")
+	flag.Int64Var(&maxActiveEpochs, "active", 0, "Maximum number of active epochs, after which reconfigurations take effect. When unset, the default is used.")
+	flag.IntVar(&numJoiners, "joiners", 0, "Number of processes, the last ones, not initially in the validator set.")
+	flag.StringVar(&reconfigSchedule, "reconfig", "", "Reconfigurations submitted in epochs, e.g., '10:+4,20:-1,30:~2' (add, remove, rotate key).")
 
 	// Gossip filtering parameters
 	flag.IntVar(&gossip.LRUCacheSize, "gcache", 262144, "Gossip LRU cache size.")
@@ -105,6 +152,8 @@ func init() {
 	flag.IntVar(&gossip.DefaultQueueSize, "qsize", 1024, "Default size for all queues.")
 	flag.IntVar(&gossip.BroadcastQueueSize, "bqsize", 32, "Size of broadcast queue.")
 	flag.IntVar(&gossip.DeliveryQueueSize, "dqsize", 8192, "Size of delivery queue.")
+	flag.IntVar(&gossip.ControlQueueSize, "cqsize", 8192, "Size of control messages delivery queue.")
+	flag.BoolVar(&priorityDelivery, "priority", false, "Delivers control messages with priority over proposals and blocks.")
 	flag.IntVar(&gossip.SendQueuesSize, "sqsize", 65536, "Size of send queues.")
 	flag.BoolVar(&gossip.SendQueuesDrop, "sqdrop", false, "Set to true for send queues to drop messages when full.")
 	flag.IntVar(&gossip.RecvQueueSize, "rqsize", 524288, "Size of receive queue.")
@@ -116,7 +165,6 @@ func main() {
 	flag.Parse()
 
 	log = net.StartLog(fmt.Sprint("p", pid))
-	//go forceExit(maxDuration)
 
 	if len(topology) == 0 {
 		topology = "full"
@@ -127,6 +175,9 @@ func main() {
 
 	log.Printf("Model: %v\n", model)
 	log.Printf("FastAlterOptimization enabled: %v\n", fastOpt)
+	log.Printf("Separate dissemination enabled: %v\n", sd)
+	log.Printf("Erasure-coded dissemination enabled: %v\n", ec)
+	log.Printf("Aggregate certificates enabled: %v\n", blsCerts)
 
 	log.Printf("Small delta: %v\n", smallDelta)
 	log.Printf("Big delta: %v\n", bigDelta)
@@ -137,6 +188,8 @@ func main() {
 
 	log.Printf("Byz time: %v\n", byzTime)
 
+	log.Printf("Byz attack: %v\n", byzAttack)
+
 	log.Printf("Block size(B): %v\n", size)
 
 	if len(cpuprofile) > 0 || len(memprofile) > 0 {
@@ -145,6 +198,11 @@ func main() {
 
 	SetupHost()
 	gossip.StatsInterval = 4 * time.Second
+	if priorityDelivery {
+		gossip.ControlMessage = func(message net.Message) bool {
+			return consensus.IsControlMessage(message)
+		}
+	}
 	if topology == "gossip" {
 		SetupGossip()
 	} else {
@@ -225,34 +283,97 @@ func main() {
 	}
 	workload := workload.NewGenerator(pid, wconfig)
 	config := tendermint.DefaultConfig()
-	keys := DeterministicKeySet(eid, n)
+	keys := DeterministicKeySet(eid, n, blsCerts)
 	config.PrivateKeys = keys.PrivateKeys
 	config.PublicKeys = keys.PublicKeys
 	config.VerifySignatures = true
+	config.SignatureVerificationThreads = verifierThreads
+	config.BatchVerificationThreshold = verifierBatch
 	config.Log = log
 	config.StatsPublishingInterval = 5 * time.Second
 	config.TimeoutSmallDelta = time.Duration(smallDelta) * time.Millisecond
 	config.TimeoutBigDelta = time.Duration(bigDelta) * time.Millisecond
+	config.AdaptiveDelays = adaptiveDelays
+	config.TimeoutSmallDeltaMin = time.Duration(smallDeltaMin) * time.Millisecond
+	config.TimeoutSmallDeltaMax = time.Duration(smallDeltaMax) * time.Millisecond
+	config.TimeoutBigDeltaMin = time.Duration(bigDeltaMin) * time.Millisecond
+	config.TimeoutBigDeltaMax = time.Duration(bigDeltaMax) * time.Millisecond
 	config.Model = model
+	config.LeaderPolicy = leaderPolicy
 	config.FastAlterEnabled = fastOpt
+	config.SeparateDissemination = sd
+	config.ErasureCoding = ec
+	config.AggregateCertificates = blsCerts
 	config.MaxEpochToStart = maxEpoch
 	if randomSeed == 0 {
 		randomSeed = eid
 	}
+	votingPower := make([]int64, n)
+	for id := range votingPower {
+		votingPower[id] = 1
+	}
 	if numByzantines > 0 {
 		byzantines := generateByzantines(numByzantines, n, randomSeed)
+		if byzPower > 0 {
+			votingPower = generateVotingPower(byzantines, n, byzPower)
+		}
 		if byzantines[pid] {
 			config.Byzantines = byzantines
 			log.Println("Byzantine process")
 		}
 	}
+	if maxActiveEpochs > 0 {
+		config.MaxActiveEpochs = maxActiveEpochs
+	}
+	operator := OperatorKey(eid)
+	config.OperatorKey = operator.PubKey()
+	if len(reconfigSchedule) > 0 {
+		var err error
+		config.Reconfigurations, config.SpareKeys, err = ParseReconfigurations(
+			reconfigSchedule, eid, pid, keys, votingPower, operator)
+		if err != nil {
+			panic(err)
+		}
+	}
+	config.VotingPower = make([]int64, n)
+	copy(config.VotingPower, votingPower)
+	for id := n - numJoiners; id < n; id++ {
+		config.VotingPower[id] = 0
+	}
 	config.ByzTime = byzTime
 	config.ByzAttack = byzAttack
+	partition, err := parseIDs(byzPartition)
+	if err != nil {
+		panic(err)
+	}
+	config.ByzPartition = partition
 	config.ChunksNumber = chunksNumber
+	config.WALFile = walFile
+	var events *os.File
+	if len(eventsFile) > 0 {
+		events, err = os.Create(eventsFile)
+		if err != nil {
+			panic(err)
+		}
+		config.Events = NewJSONLSink(events)
+	}
 	process = tendermint.NewProcess(pid, n, config, gtransport, workload)
 	log.Printf("Created Tendermint process in zone %v\n", zone)
+	if len(statusAddr) > 0 {
+		StartStatusServer(statusAddr)
+	}
+	if len(metricsAddr) > 0 {
+		StartMetricsServer(metricsAddr)
+	}
 
+	// The agent is interrupted by SIGINT or SIGTERM
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 	stopChan := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		close(stopChan)
+	}()
 	go workload.ProduceValues(stopChan)
 
 	timestamp = time.Now()
@@ -260,23 +381,53 @@ func main() {
 	bduration := time.Now().Sub(timestamp)
 	log.Println("Bootstraped process in", bduration)
 
-	go statsRoutine()
-	go process.MainLoop()
+	statsDone := make(chan struct{})
+	go statsRoutine(statsDone)
+	process.Start(ctx)
 	workload.Run(time.Duration(maxDuration)*time.Second, stopChan)
 
 	//	coolDownTime := time.Duration(coolTime) * time.Second
 	//	workload.NoopRoutine(coolDownTime)
+
+	cancel()
+	shutdown(statsDone, events)
 }
 
-// This should check that in every zone we have at least one byzantine.
+// Stops the process, then the proxy and the transport, and closes the host.
+// The last stats are logged and the events file is closed.
+func shutdown(statsDone <-chan struct{}, events *os.File) {
+	timestamp := time.Now()
+	process.Stop()
+	<-statsDone
+	cproxy.Stop()
+	gtransport.Stop()
+	if events != nil {
+		if err := events.Close(); err != nil {
+			log.Println("Could not close events file:", err)
+		}
+	}
+	if err := host.Host.Close(); err != nil {
+		log.Println("Could not close host:", err)
+	}
+	log.Println("Agent stopped in", time.Now().Sub(timestamp))
+}
 
-// Force the agent to exit after 'duration' seconds.
-func forceExit(duration int) {
-	start := time.Now()
-	time.Sleep(time.Duration(duration) * time.Second)
-	log.Println("forced exit after", time.Now().Sub(start))
-	panic("Agent maximum duration reached")
+// This should check that in every zone we have at least one byzantine.
 
+// parseIDs parses a comma-separated list of process IDs.
+func parseIDs(list string) ([]int, error) {
+	if list == "" {
+		return nil, nil
+	}
+	var ids []int
+	for _, entry := range strings.Split(list, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(entry))
+		if err != nil {
+			return nil, fmt.Errorf("invalid process ID %q: %v", entry, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
 }
 
 func generateByzantines(f, n int, seed int64) map[int]bool {
@@ -284,6 +435,24 @@ func generateByzantines(f, n int, seed int64) map[int]bool {
 	return byzantines
 }
 
+// Total voting power shared among processes.
+const totalVotingPower = 1000000
+
+// Byzantines share the provided fraction of the total voting power, correct
+// processes share the remaining power.
+func generateVotingPower(byzantines map[int]bool, n int, fraction float64) []int64 {
+	byzPower := int64(fraction * totalVotingPower)
+	power := make([]int64, n)
+	for id := range power {
+		if byzantines[id] {
+			power[id] = byzPower / int64(len(byzantines))
+		} else {
+			power[id] = (totalVotingPower - byzPower) / int64(n-len(byzantines))
+		}
+	}
+	return power
+}
+
 func getRandomByzantines(f, n int, seed int64) map[int]bool {
 	src := rand.NewSource(seed)
 	randGen := rand.New(src)
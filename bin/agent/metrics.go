@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"dslab.inf.usi.ch/tendermint"
+	"dslab.inf.usi.ch/tendermint/consensus"
+	"dslab.inf.usi.ch/tendermint/net/gossip"
+)
+
+// Prefix of the names of the exported metrics.
+const metricsPrefix = "alterbft_"
+
+// Labels of the exported metrics.
+var messageLabels = map[int]string{
+	consensus.PROPOSE:        "propose",
+	consensus.SILENCE:        "silence",
+	consensus.VOTE:           "vote",
+	consensus.QUIT_EPOCH:     "quit-epoch",
+	consensus.CERTIFICATE:    "certificate",
+	consensus.DELTA_REQUEST:  "delta-request",
+	consensus.DELTA_RESPONSE: "delta-response",
+	consensus.BLOCK_REQUEST:  "block-request",
+	consensus.BLOCK_RESPONSE: "block-response",
+	consensus.EVIDENCE:       "evidence",
+	consensus.PAYLOAD:        "payload",
+	consensus.CHUNK:          "chunk",
+	consensus.PRECOMMIT:      "precommit",
+}
+
+var phaseLabels = map[int]string{
+	tendermint.ProposalPhase:    "proposal",
+	tendermint.VotePhase:        "vote",
+	tendermint.CertificatePhase: "certificate",
+	tendermint.DecisionPhase:    "decision",
+	tendermint.DeliveryPhase:    "delivery",
+}
+
+var instanceLabels = []string{"started", "decided", "delivered"}
+var deliveryLabels = []string{"blocks", "transactions"}
+var pathLabels = []string{"fast", "slow"}
+var deltaLabels = []string{"small", "big"}
+var classLabels = []string{"control", "bulk"}
+
+// Process stats accumulated since the agent started, as the process resets
+// its stats whenever it publishes them.
+var processMetrics struct {
+	sync.Mutex
+	stats           tendermint.Stats
+	histograms      []tendermint.Histogram
+	queueHistograms []tendermint.Histogram
+}
+
+// Accumulates the stats published by the process.
+func recordProcessStats(stats *tendermint.Stats) {
+	processMetrics.Lock()
+	defer processMetrics.Unlock()
+	total := &processMetrics.stats
+	for i := range stats.Instances {
+		total.Instances[i] += stats.Instances[i]
+	}
+	for i := range stats.Messages {
+		total.Messages[i] += stats.Messages[i]
+	}
+	for i := range stats.Deliveries {
+		total.Deliveries[i] += stats.Deliveries[i]
+	}
+	for i := range stats.Paths {
+		total.Paths[i] += stats.Paths[i]
+	}
+	total.Deltas = stats.Deltas
+	if processMetrics.histograms == nil {
+		processMetrics.histograms = make([]tendermint.Histogram, len(stats.Histograms))
+	}
+	for phase := range stats.Histograms {
+		processMetrics.histograms[phase].Add(stats.Histograms[phase])
+	}
+	if processMetrics.queueHistograms == nil {
+		processMetrics.queueHistograms = make([]tendermint.Histogram, len(stats.QueueHistograms))
+	}
+	for class := range stats.QueueHistograms {
+		processMetrics.queueHistograms[class].Add(stats.QueueHistograms[class])
+	}
+}
+
+// StartMetricsServer serves the metrics of the agent in the Prometheus text
+// format on the provided address, at the /metrics path.
+func StartMetricsServer(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", serveMetrics)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Println("Metrics server failed:", err)
+		}
+	}()
+	log.Println("Serving metrics on", addr)
+}
+
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	m := &metricsWriter{}
+	m.writeProcessMetrics()
+	if process != nil {
+		m.writeVerifierMetrics(process.VerifierStats())
+	}
+	gossipStats.Lock()
+	stats := gossipStats.last
+	gossipStats.Unlock()
+	if stats != nil {
+		m.writeGossipMetrics(stats)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if _, err := w.Write(m.Bytes()); err != nil {
+		log.Println("Could not write metrics:", err)
+	}
+}
+
+// metricsWriter formats metrics in the Prometheus text format.
+type metricsWriter struct {
+	bytes.Buffer
+}
+
+func (m *metricsWriter) header(name, kind, help string) {
+	fmt.Fprintf(m, "# HELP %s%s %s\n# TYPE %s%s %s\n", metricsPrefix, name, help, metricsPrefix, name, kind)
+}
+
+func (m *metricsWriter) sample(name, labels string, value interface{}) {
+	if len(labels) > 0 {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(m, "%s%s%s %v\n", metricsPrefix, name, labels, value)
+}
+
+func (m *metricsWriter) writeProcessMetrics() {
+	processMetrics.Lock()
+	defer processMetrics.Unlock()
+	stats := &processMetrics.stats
+
+	m.header("instances_total", "counter", "Consensus instances by state.")
+	for i, count := range stats.Instances {
+		m.sample("instances_total", fmt.Sprintf("state=%q", instanceLabels[i]), count)
+	}
+	m.header("messages_received_total", "counter", "Consensus messages received by type.")
+	for mtype, count := range stats.Messages {
+		m.sample("messages_received_total", fmt.Sprintf("type=%q", messageLabels[mtype]), count)
+	}
+	m.header("deliveries_total", "counter", "Blocks and transactions delivered.")
+	for i, count := range stats.Deliveries {
+		m.sample("deliveries_total", fmt.Sprintf("kind=%q", deliveryLabels[i]), count)
+	}
+	m.header("decisions_total", "counter", "Decisions by path, fast with the votes of all processes or slow, in models with a fast path.")
+	for i, count := range stats.Paths {
+		m.sample("decisions_total", fmt.Sprintf("path=%q", pathLabels[i]), count)
+	}
+	m.header("delta_seconds", "gauge", "Small and big deltas of the timeouts.")
+	for i, delta := range stats.Deltas {
+		m.sample("delta_seconds", fmt.Sprintf("delta=%q", deltaLabels[i]), delta.Seconds())
+	}
+
+	m.header("phase_latency_seconds", "histogram", "Latencies from the start of epochs to their phases.")
+	for phase, h := range processMetrics.histograms {
+		m.histogram("phase_latency_seconds", fmt.Sprintf("phase=%q", phaseLabels[phase]), h)
+	}
+	m.header("queue_delay_seconds", "histogram", "Delays of messages from their reception to their processing by class.")
+	for class, h := range processMetrics.queueHistograms {
+		m.histogram("queue_delay_seconds", fmt.Sprintf("class=%q", classLabels[class]), h)
+	}
+}
+
+func (m *metricsWriter) histogram(name, label string, h tendermint.Histogram) {
+	var count int
+	for i, bound := range tendermint.LatencyBuckets {
+		count += h.Counts[i]
+		le := strconv.FormatFloat(bound.Seconds(), 'g', -1, 64)
+		m.sample(name+"_bucket", fmt.Sprintf("%s,le=%q", label, le), count)
+	}
+	count += h.Counts[len(tendermint.LatencyBuckets)]
+	m.sample(name+"_bucket", label+`,le="+Inf"`, count)
+	m.sample(name+"_sum", label, h.Sum.Seconds())
+	m.sample(name+"_count", label, count)
+}
+
+func (m *metricsWriter) writeVerifierMetrics(stats tendermint.VerifierStats) {
+	m.header("verifier_signatures_total", "counter", "Signatures to verify, and the ones found in the cache.")
+	m.sample("verifier_signatures_total", `result="queried"`, stats.Queries)
+	m.sample("verifier_signatures_total", `result="cached"`, stats.Cached)
+	m.header("verifier_rejected_total", "counter", "Messages with invalid signatures.")
+	m.sample("verifier_rejected_total", "", stats.Rejected)
+	m.header("verifier_batches_total", "counter", "Messages whose signatures were verified in batch.")
+	m.sample("verifier_batches_total", "", stats.Batches)
+	m.header("verifier_worker_messages_total", "counter", "Messages verified by each verifier worker.")
+	for i, worker := range stats.Workers {
+		m.sample("verifier_worker_messages_total", fmt.Sprintf("worker=\"%d\"", i), worker.Messages)
+	}
+	m.header("verifier_worker_busy_seconds_total", "counter", "Time spent verifying messages by each verifier worker.")
+	for i, worker := range stats.Workers {
+		m.sample("verifier_worker_busy_seconds_total", fmt.Sprintf("worker=\"%d\"", i), worker.Busy.Seconds())
+	}
+}
+
+func (m *metricsWriter) writeGossipMetrics(stats *gossip.Stats) {
+	m.header("gossip_queue_messages_total", "counter", "Messages added to the gossip queues by queue and fill level.")
+	queues := []struct {
+		name  string
+		stats gossip.QueueStats
+	}{
+		{"broadcast", stats.BQueue},
+		{"delivery", stats.DQueue},
+		{"control", stats.CQueue},
+		{"receive", stats.RQueue},
+		{"send", stats.SQueues},
+	}
+	for _, queue := range queues {
+		for _, level := range []struct {
+			name  string
+			count int
+		}{{"low", queue.stats.Low}, {"high", queue.stats.High}, {"full", queue.stats.Full}} {
+			m.sample("gossip_queue_messages_total", fmt.Sprintf("queue=%q,level=%q", queue.name, level.name), level.count)
+		}
+	}
+	m.header("gossip_cache_messages_total", "counter", "Messages added to the gossip cache and duplicates.")
+	m.sample("gossip_cache_messages_total", `result="added"`, stats.Cache.Added)
+	m.sample("gossip_cache_messages_total", `result="duplicated"`, stats.Cache.Duplicated)
+	m.header("gossip_filtered_total", "counter", "Messages filtered by the gossip validator.")
+	m.sample("gossip_filtered_total", "", stats.Validator.Filtered)
+	m.header("gossip_messages_received_total", "counter", "Messages received by the gossip transport.")
+	m.sample("gossip_messages_received_total", "", stats.MessageLoss.Received)
+	m.header("gossip_messages_lost_total", "counter", "Messages dropped by the simulated message loss.")
+	m.sample("gossip_messages_lost_total", "", stats.MessageLoss.Lost)
+}
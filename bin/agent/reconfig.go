@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
+	"dslab.inf.usi.ch/tendermint/crypto"
+)
+
+// RotatedKey generates the key of a process after a number of rotations.
+// Secret: [seed (8 bytes), processId (4 bytes), rotations (4 bytes)]
+func RotatedKey(seed int64, id, rotations int, bls bool) crypto.PrivateKey {
+	secret := make([]byte, 8+4+4)
+	binary.LittleEndian.PutUint64(secret, uint64(seed))
+	binary.LittleEndian.PutUint32(secret[8:], uint32(id))
+	binary.LittleEndian.PutUint32(secret[12:], uint32(rotations))
+	return generateKey(secret, bls)
+}
+
+// ParseReconfigurations parses a schedule of reconfigurations in the format
+// "<epoch>:<op><id>,...", where op is '+' to add a process, '-' to remove it
+// and '~' to rotate its key. Added and rotated processes have the provided
+// voting power. The rotated keys of process pid are returned as spare keys.
+// Reconfigurations are numbered in epoch order and signed by the operator.
+func ParseReconfigurations(schedule string, seed int64, pid int, keys *KeySet,
+	votingPower []int64, operator crypto.PrivateKey) (map[int64]*consensus.Reconfiguration, []crypto.PrivateKey, error) {
+	type change struct {
+		epoch int64
+		op    byte
+		id    int
+	}
+	var changes []change
+	for _, entry := range strings.Split(schedule, ",") {
+		fields := strings.Split(strings.TrimSpace(entry), ":")
+		if len(fields) != 2 || len(fields[1]) < 2 {
+			return nil, nil, fmt.Errorf("invalid reconfiguration %q", entry)
+		}
+		epoch, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid reconfiguration %q: %v", entry, err)
+		}
+		id, err := strconv.Atoi(fields[1][1:])
+		if err != nil || id < 0 || id >= len(keys.PublicKeys) {
+			return nil, nil, fmt.Errorf("invalid process in reconfiguration %q", entry)
+		}
+		changes = append(changes, change{epoch, fields[1][0], id})
+	}
+	// Rotations are numbered in epoch order
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].epoch < changes[j].epoch })
+
+	reconfigurations := make(map[int64]*consensus.Reconfiguration)
+	var spareKeys []crypto.PrivateKey
+	rotations := make(map[int]int)
+	for _, c := range changes {
+		update := consensus.ValidatorUpdate{ID: c.id, VotingPower: votingPower[c.id]}
+		switch c.op {
+		case '+':
+			update.PublicKey = keys.PublicKeys[c.id]
+		case '-':
+			update.VotingPower = 0
+		case '~':
+			rotations[c.id]++
+			key := RotatedKey(seed, c.id, rotations[c.id], keys.BLS)
+			update.PublicKey = key.PubKey()
+			if c.id == pid {
+				spareKeys = append(spareKeys, key)
+			}
+		default:
+			return nil, nil, fmt.Errorf("invalid reconfiguration operation %q", c.op)
+		}
+		r, ok := reconfigurations[c.epoch]
+		if !ok {
+			r = &consensus.Reconfiguration{Sequence: uint64(len(reconfigurations) + 1)}
+			reconfigurations[c.epoch] = r
+		}
+		r.Updates = append(r.Updates, update)
+	}
+	for _, r := range reconfigurations {
+		r.Sign(operator)
+	}
+	return reconfigurations, spareKeys, nil
+}
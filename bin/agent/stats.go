@@ -1,33 +1,67 @@
 package main
 
-func statsRoutine() {
+import (
+	"dslab.inf.usi.ch/tendermint"
+	"dslab.inf.usi.ch/tendermint/net/gossip"
+)
+
+// Logs the stats published by the process and the transport, until the
+// process is stopped. The provided channel is closed when it returns.
+func statsRoutine(done chan<- struct{}) {
+	defer close(done)
 	for {
 		select {
 		case stats := <-process.StatsQueue():
-			log.Println("Process", stats.Messages, stats.Instances, stats.Deliveries)
+			logProcessStats(stats)
 
 		case stats := <-gtransport.StatsQueue():
-			if stats.BQueue.Total() > 0 {
-				log.Println("BcastQ:", stats.BQueue)
-			}
-			if stats.DQueue.Total() > 0 {
-				log.Println("DelivQ:", stats.DQueue)
-			}
-			if stats.Cache.Added > 0 {
-				log.Println("CacheF:", stats.Cache)
-			}
-			if stats.RQueue.Total() > 0 {
-				log.Println("RecevQ:", stats.RQueue)
-			}
-			if stats.SQueues.Total() > 0 {
-				log.Println("SendsQ:", stats.SQueues)
-			}
-			if stats.Validator.Filtered > 0 {
-				log.Println("ValidF:", stats.Validator)
-			}
-			if stats.MessageLoss.Lost > 0 {
-				log.Println("MessageLoss:", stats.MessageLoss)
+			logGossipStats(stats)
+
+		case <-process.Done():
+			// Stats published by the process when stopped
+			for len(process.StatsQueue()) > 0 {
+				logProcessStats(<-process.StatsQueue())
 			}
+			return
 		}
 	}
 }
+
+func logProcessStats(stats *tendermint.Stats) {
+	recordProcessStats(stats)
+	log.Println("Process", stats.Messages, stats.Instances, stats.Deliveries, stats.Deltas)
+	if stats.Latencies[tendermint.ProposalPhase].Count > 0 {
+		log.Println("Phases:", stats.Latencies, "Paths:", stats.Paths)
+	}
+	if stats.QueueDelays[0].Count > 0 {
+		log.Println("Queues:", stats.QueueDelays)
+	}
+}
+
+func logGossipStats(stats *gossip.Stats) {
+	recordGossipStats(stats)
+	if stats.BQueue.Total() > 0 {
+		log.Println("BcastQ:", stats.BQueue)
+	}
+	if stats.DQueue.Total() > 0 {
+		log.Println("DelivQ:", stats.DQueue)
+	}
+	if stats.CQueue.Total() > 0 {
+		log.Println("CtrlQ:", stats.CQueue)
+	}
+	if stats.Cache.Added > 0 {
+		log.Println("CacheF:", stats.Cache)
+	}
+	if stats.RQueue.Total() > 0 {
+		log.Println("RecevQ:", stats.RQueue)
+	}
+	if stats.SQueues.Total() > 0 {
+		log.Println("SendsQ:", stats.SQueues)
+	}
+	if stats.Validator.Filtered > 0 {
+		log.Println("ValidF:", stats.Validator)
+	}
+	if stats.MessageLoss.Lost > 0 {
+		log.Println("MessageLoss:", stats.MessageLoss)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"dslab.inf.usi.ch/tendermint"
+	"dslab.inf.usi.ch/tendermint/net/gossip"
+)
+
+// Time the status server waits for the process main routine.
+var statusTimeout = time.Second
+
+// Last stats published by the gossip transport, reported by the status server.
+var gossipStats struct {
+	sync.Mutex
+	last *gossip.Stats
+}
+
+// agentStatus is the JSON status served by the status server.
+type agentStatus struct {
+	// Nil if the process is not yet running its main routine or if it is
+	// not responsive
+	Process *tendermint.Status `json:"process"`
+	Gossip  *gossip.Stats      `json:"gossip"`
+	Peers   []peerStatus       `json:"peers"`
+}
+
+// peerStatus is the state of an entry of the peers table.
+type peerStatus struct {
+	ID        int      `json:"id"`
+	PeerID    string   `json:"peer"`
+	Active    bool     `json:"active"`
+	Chosen    bool     `json:"chosen"`
+	Connected bool     `json:"connected"`
+	Errors    []string `json:"errors,omitempty"`
+	Comment   string   `json:"comment,omitempty"`
+}
+
+// StartStatusServer serves the status of the agent as JSON on the provided
+// address, at the /status path.
+func StartStatusServer(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", serveStatus)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Println("Status server failed:", err)
+		}
+	}()
+	log.Println("Serving status on", addr)
+}
+
+// Records the last stats published by the gossip transport.
+func recordGossipStats(stats *gossip.Stats) {
+	gossipStats.Lock()
+	gossipStats.last = stats
+	gossipStats.Unlock()
+}
+
+func serveStatus(w http.ResponseWriter, r *http.Request) {
+	status := &agentStatus{Peers: peersStatus()}
+	if process != nil {
+		status.Process = process.Status(statusTimeout)
+	}
+	gossipStats.Lock()
+	status.Gossip = gossipStats.last
+	gossipStats.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(status); err != nil {
+		log.Println("Could not write status:", err)
+	}
+}
+
+func peersStatus() []peerStatus {
+	statuses := []peerStatus{}
+	if gtransport == nil {
+		return statuses
+	}
+	gtransport.Peers.Lock()
+	peers := gtransport.Peers.List()
+	gtransport.Peers.Unlock()
+	for _, peer := range peers {
+		status := peerStatus{
+			ID:        peer.ID,
+			PeerID:    peer.Addr.ID.Pretty(),
+			Active:    peer.Active,
+			Chosen:    peer.Chosen,
+			Connected: peer.FulllyConnected(),
+			Comment:   peer.Comment,
+		}
+		for _, err := range []error{peer.ConnE, peer.RecvStreamE, peer.SendStreamE} {
+			if err != nil {
+				status.Errors = append(status.Errors, err.Error())
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].PeerID < statuses[j].PeerID
+	})
+	return statuses
+}
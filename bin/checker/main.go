@@ -0,0 +1,40 @@
+// Command checker verifies the agreement of the deliveries logged by agents.
+//
+// Usage: checker deliveries.0 deliveries.1 ...
+//
+// It exits with status 1 if a safety violation is found, 2 on errors.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"dslab.inf.usi.ch/tendermint/safety"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s deliveries.ID...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	checker := safety.NewChecker()
+	for _, filename := range flag.Args() {
+		if err := checker.ReadDeliveriesFile(filename); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	violations := checker.Check()
+	fmt.Printf("%d commits from %d files\n", checker.Commits(), flag.NArg())
+	fmt.Print(safety.Report(violations))
+	if len(violations) > 0 {
+		os.Exit(1)
+	}
+}
@@ -3,6 +3,7 @@ package tendermint
 import (
 	"time"
 
+	"dslab.inf.usi.ch/tendermint/consensus"
 	"dslab.inf.usi.ch/tendermint/crypto"
 	"dslab.inf.usi.ch/tendermint/net"
 )
@@ -27,15 +28,39 @@ type Config struct {
 	// Model in which we operate.
 	Model string
 
+	// Policy defining the proposer of each epoch: "round-robin", "random"
+	// or "reputation". If unset, the round-robin policy is used.
+	LeaderPolicy string
+
 	// Byzantine processes.
 	Byzantines map[int]bool
 
+	// Initial voting power of each process, used to weight certificates.
+	// If unset, every process has voting power 1. Processes with no voting
+	// power are not members of the initial validator set.
+	VotingPower []int64
+
+	// Reconfigurations of the validator set submitted by the process when
+	// starting an epoch, by epoch. A reconfiguration takes effect
+	// MaxActiveEpochs epochs after the epoch of the block committing it.
+	Reconfigurations map[int64]*consensus.Reconfiguration
+
+	// Key of the operator, whose signature authorises reconfigurations.
+	// If unset, the validator set cannot be reconfigured.
+	OperatorKey crypto.PublicKey
+
 	// Time a byzantine leader should wait before proposing.
 	ByzTime int
 
-	// Byz attack
+	// Attack run by byzantine processes, one of consensus.Attacks().
+	// The "silence" and "equiv" models run the silence and equivocation
+	// attacks regardless of this field.
 	ByzAttack string
 
+	// Processes receiving the first of the proposals of an equivocating
+	// leader. If unset, the first half of the processes.
+	ByzPartition []int
+
 	// Number of chunks in delta_chunked
 	ChunksNumber int
 
@@ -50,10 +75,18 @@ type Config struct {
 	// Size (lenght) of internal message processing queues.
 	MessageQueuesSize int
 
+	// Size (length) of the internal queues of control messages, which are
+	// processed with priority when the transport delivers them separately.
+	ControlQueuesSize int
+
 	// Schedule and trigger timeouts to the consensus protocol.
 	// If set to false, the protocol will not tolerate failures.
 	ScheduleTimeouts bool
 
+	// If set, clock driving the timeouts, e.g., a consensus.ManualClock in
+	// tests. When unset, the system clock is used.
+	Clock consensus.Clock
+
 	// If set to true, received messages have their signatures verified.
 	// In this case, 'PublicKeys' should contain keys for every process.
 	// Received messages with wrong or invalid signatures are discarded.
@@ -64,12 +97,21 @@ type Config struct {
 	SignatureGenerationThreads int
 
 	// Number of threads used for verifying message signatures.
-	// If unset, signatures are verified in the process main thread.
+	// If unset, signatures are verified by a single thread.
 	SignatureVerificationThreads int
 
+	// Messages with more signatures than this number, e.g. carrying
+	// certificates, have their signatures verified in batch.
+	// If unset, signatures are verified one by one.
+	BatchVerificationThreshold int
+
 	// Used to produce signatures attached to generated messages.
 	PrivateKeys []crypto.PrivateKey
 
+	// Private keys a process may be assigned by reconfigurations, used to
+	// sign messages of epochs in which its key is rotated.
+	SpareKeys []crypto.PrivateKey
+
 	// Set of public keys used to verfiy signatures attached to messages.
 	// Each process in the system should have an associated public key.
 	PublicKeys []crypto.PublicKey
@@ -79,8 +121,45 @@ type Config struct {
 	TimeoutBigDelta   time.Duration
 	FastAlterEnabled  bool
 
+	// If set to true, proposers broadcast the header of their blocks and
+	// disseminate the values separately, see consensus.FastAlterBFT.
+	SeparateDissemination bool
+
+	// If set to true, proposers disseminate erasure-coded chunks of the
+	// values of their blocks, see consensus.FastAlterBFT.
+	ErasureCoding bool
+
+	// If set to true, certificates are sent with a single aggregate
+	// signature of their signers, see consensus.Certificate.Aggregate.
+	// Certificates can only be aggregated if the keys are BLS keys.
+	AggregateCertificates bool
+
+	// If set to true, the small and big deltas are adapted to the observed
+	// message delays, see DelayController. The deltas above are the initial
+	// values, bounded by the minimum and maximum values, if set.
+	AdaptiveDelays       bool
+	TimeoutSmallDeltaMin time.Duration
+	TimeoutSmallDeltaMax time.Duration
+	TimeoutBigDeltaMin   time.Duration
+	TimeoutBigDeltaMax   time.Duration
+
+	// Factor applied to the largest observed delays to set the deltas.
+	DelayMargin float64
+
+	// Number of recent delays observed per sender.
+	DelaySamples int
+
 	// If set, defines the interval for publishing process stats.
 	StatsPublishingInterval time.Duration
+
+	// If set, receives the events of the consensus instances, see
+	// consensus.Event.
+	Events consensus.EventSink
+
+	// If set, file of the write-ahead log recording votes, locked
+	// certificates and committed blocks. A process created with an existing
+	// log recovers its state and does not vote twice in an epoch.
+	WALFile string
 }
 
 // DefaultConfig returns a default configuration for Tendermint.
@@ -93,14 +172,17 @@ func DefaultConfig() *Config {
 		MaxActiveEpochs:        2000,
 		BlockchainSize:         2000,
 		Model:                  "sync",
+		LeaderPolicy:           "round-robin",
 
 		Byzantines: nil,
 		ByzTime:    0,
 
 		MessageQueuesSize: 32,
+		ControlQueuesSize: 32,
 
 		SignatureGenerationThreads:   0,
 		SignatureVerificationThreads: 0,
+		BatchVerificationThreshold:   0,
 
 		ScheduleTimeouts:  true,
 		TimeoutSmallDelta: time.Second / 5,
@@ -108,6 +190,33 @@ func DefaultConfig() *Config {
 
 		FastAlterEnabled: false,
 
+		AdaptiveDelays: false,
+		DelayMargin:    1.5,
+		DelaySamples:   32,
+
 		ChunksNumber: 64,
 	}
 }
+
+// attackName returns the name of the attack run by byzantine processes.
+func (c *Config) attackName() string {
+	switch {
+	case c.Model == "silence":
+		return consensus.SILENCE_ATTACK
+	case c.Model == "equiv":
+		return consensus.EQUIVOCATION_ATTACK
+	case c.ByzAttack == "":
+		return consensus.HONEST
+	}
+	return c.ByzAttack
+}
+
+// hasFastPath returns whether the consensus model decides on a fast path,
+// with the votes of all processes.
+func (c *Config) hasFastPath() bool {
+	switch c.Model {
+	case "alter", "silence", "equiv":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,385 @@
+package consensus
+
+import (
+	"sort"
+	"time"
+)
+
+// Attack names.
+const (
+	HONEST                     = "honest"
+	SILENCE_ATTACK             = "silence"
+	CRASH_ATTACK               = "crash"
+	EQUIVOCATION_ATTACK        = "equivocation"
+	EQUIVOCATION_WITHOUT_VOTES = "equivocation-without-votes"
+	WITHHOLD_VOTES             = "withhold-votes"
+	DELAY_PROPOSAL             = "delay-proposal"
+	SELECTIVE_FORWARDING       = "selective-forwarding"
+	WITHHOLD_CERTIFICATE       = "withhold-certificate"
+)
+
+// TimeoutAttack is the type of the timeouts releasing messages delayed by an
+// attack, handled by the Attacker.
+const TimeoutAttack = TimeoutEpochChange + 1
+
+// Outgoing is a message sent by a consensus instance.
+type Outgoing struct {
+	Message *Message
+
+	// Receivers of the message, nil if the message is broadcast.
+	To []int
+
+	// Whether the message is forwarded, rather than sent by the process.
+	Forwarded bool
+}
+
+// AttackConfig parameterizes the attacks of Byzantine processes.
+type AttackConfig struct {
+	// Byzantine processes, colluding in the attacks.
+	Byzantines map[int]bool
+
+	// Delay of the messages delayed by attacks.
+	Delay time.Duration
+
+	// Processes receiving the first of two equivocating proposals, the
+	// other processes receiving the second. If unset, processes with IDs
+	// lower than half the number of processes receive the first proposal.
+	Partition []int
+}
+
+// Attack is a Byzantine behavior, implemented by intercepting the messages
+// sent and the timeouts scheduled by an honest FastAlterBFT instance.
+//
+// Attacks are shared by the consensus instances of a process, state of an
+// epoch is stored by the Attacker.
+type Attack interface {
+	// Send intercepts a message sent by the consensus instance. The attack
+	// sends it, altered versions of it or nothing through the Attacker.
+	Send(a *Attacker, out *Outgoing)
+
+	// Schedule intercepts a timeout scheduled by the consensus instance.
+	Schedule(a *Attacker, timeout *Timeout)
+}
+
+var attacks = make(map[string]Attack)
+
+func init() {
+	RegisterAttack(HONEST, Honest{})
+	RegisterAttack(SILENCE_ATTACK, silenceAttack{})
+	RegisterAttack(CRASH_ATTACK, crashAttack{})
+	RegisterAttack(EQUIVOCATION_ATTACK, equivocationAttack{vote: true})
+	RegisterAttack(EQUIVOCATION_WITHOUT_VOTES, equivocationAttack{})
+	RegisterAttack(WITHHOLD_VOTES, withholdVotesAttack{})
+	RegisterAttack(DELAY_PROPOSAL, delayProposalAttack{})
+	RegisterAttack(SELECTIVE_FORWARDING, selectiveForwardingAttack{})
+	RegisterAttack(WITHHOLD_CERTIFICATE, withholdCertificateAttack{})
+}
+
+// RegisterAttack registers an attack with a name, replacing any attack
+// registered with the same name.
+func RegisterAttack(name string, attack Attack) {
+	attacks[name] = attack
+}
+
+// NewAttack returns the attack registered with the provided name, nil if
+// there is no such attack.
+func NewAttack(name string) Attack {
+	return attacks[name]
+}
+
+// Attacks returns the names of the registered attacks in increasing order.
+func Attacks() []string {
+	names := make([]string, 0, len(attacks))
+	for name := range attacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Attacker runs an attack in an epoch of consensus. It is the Process of an
+// honest FastAlterBFT instance, whose messages and timeouts are intercepted
+// by the attack, and the Consensus instance the actual process runs.
+type Attacker struct {
+	// The actual process
+	Process
+
+	Config AttackConfig
+
+	honest *FastAlterBFT
+	attack Attack
+
+	// Delayed messages, released in order by attack timeouts
+	delayed []*Outgoing
+}
+
+// NewAttacker wraps an honest consensus instance, replacing its process.
+func NewAttacker(honest *FastAlterBFT, attack Attack, config AttackConfig) *Attacker {
+	a := &Attacker{
+		Process: honest.Process,
+		Config:  config,
+		honest:  honest,
+		attack:  attack,
+	}
+	honest.Process = a
+	return a
+}
+
+// Epoch returns the epoch of the attacked consensus instance.
+func (a *Attacker) Epoch() int64 {
+	return a.honest.Epoch
+}
+
+// Deliver sends a message as the honest consensus instance would. Messages
+// of other processes sent to some peers are not signed again by Send, but
+// forwarded as signed by their sender.
+func (a *Attacker) Deliver(out *Outgoing) {
+	switch {
+	case out.To != nil:
+		a.Process.Send(out.Message, out.To...)
+	case out.Forwarded:
+		a.Process.Forward(out.Message)
+	default:
+		a.Process.Broadcast(out.Message)
+	}
+}
+
+// Delay delivers a message after the delay of the attack configuration.
+func (a *Attacker) Delay(out *Outgoing) {
+	a.delayed = append(a.delayed, out)
+	a.Process.Schedule(&Timeout{
+		Type:     TimeoutAttack,
+		Epoch:    a.Epoch(),
+		Duration: a.Config.Delay,
+	})
+}
+
+// Others returns the IDs of the processes that are not in the provided set.
+func (a *Attacker) Others(ids map[int]bool) []int {
+	var others []int
+	for id := 0; id < a.NumProcesses(); id++ {
+		if !ids[id] {
+			others = append(others, id)
+		}
+	}
+	return others
+}
+
+// Broadcast intercepts a message broadcast by the honest instance.
+func (a *Attacker) Broadcast(message *Message) {
+	a.attack.Send(a, &Outgoing{Message: message})
+}
+
+// Forward intercepts a message forwarded by the honest instance.
+func (a *Attacker) Forward(message *Message) {
+	a.attack.Send(a, &Outgoing{Message: message, Forwarded: true})
+}
+
+// Send intercepts a message sent by the honest instance.
+func (a *Attacker) Send(message *Message, ids ...int) {
+	if ids == nil {
+		ids = []int{}
+	}
+	a.attack.Send(a, &Outgoing{Message: message, To: ids})
+}
+
+// Schedule intercepts a timeout scheduled by the honest instance.
+func (a *Attacker) Schedule(timeout *Timeout) {
+	a.attack.Schedule(a, timeout)
+}
+
+func (a *Attacker) GetEpoch() int64 {
+	return a.honest.GetEpoch()
+}
+
+func (a *Attacker) Start(lockedCertificate *Certificate, sentLockedCertificate bool) {
+	a.honest.Start(lockedCertificate, sentLockedCertificate)
+}
+
+func (a *Attacker) Stop() {
+	a.honest.Stop()
+}
+
+func (a *Attacker) Started() bool {
+	return a.honest.Started()
+}
+
+func (a *Attacker) Status() *EpochStatus {
+	return a.honest.Status()
+}
+
+func (a *Attacker) ProcessMessage(message *Message) {
+	a.honest.ProcessMessage(message)
+}
+
+func (a *Attacker) ProcessTimeout(timeout *Timeout) {
+	if timeout.Type != TimeoutAttack {
+		a.honest.ProcessTimeout(timeout)
+		return
+	}
+	if len(a.delayed) > 0 {
+		out := a.delayed[0]
+		a.delayed = a.delayed[1:]
+		a.Deliver(out)
+	}
+}
+
+// Honest is the attack that does not deviate from the protocol. Attacks
+// embed it to intercept only some of the messages or timeouts.
+type Honest struct{}
+
+func (Honest) Send(a *Attacker, out *Outgoing) {
+	a.Deliver(out)
+}
+
+func (Honest) Schedule(a *Attacker, timeout *Timeout) {
+	a.Process.Schedule(timeout)
+}
+
+// silenceAttack sends no message but its votes: it does not propose, nor
+// take part in epoch changes.
+type silenceAttack struct {
+	Honest
+}
+
+func (silenceAttack) Send(a *Attacker, out *Outgoing) {
+	if out.Message.Type == VOTE || (out.Message.Type == PROPOSE && out.Forwarded) {
+		a.Deliver(out)
+	}
+}
+
+// crashAttack sends no message at all, as a crashed process.
+type crashAttack struct {
+	Honest
+}
+
+func (crashAttack) Send(a *Attacker, out *Outgoing) {}
+
+// equivocationAttack proposes two blocks, each sent to a partition of the
+// processes, and votes for both if vote is set. Headers of blocks whose
+// values are disseminated separately are not equivocated.
+type equivocationAttack struct {
+	Honest
+	vote bool
+}
+
+func (e equivocationAttack) Send(a *Attacker, out *Outgoing) {
+	proposal := out.Message
+	if proposal.Type != PROPOSE || out.Forwarded || proposal.Sender != a.ID() ||
+		!proposal.Block.HasPayload() {
+		a.Deliver(out)
+		return
+	}
+	value := a.GetValue()
+	if value == nil {
+		a.Deliver(out)
+		return
+	}
+	block := &Block{
+		Value:       value,
+		Height:      proposal.Block.Height,
+		Epoch:       proposal.Block.Epoch,
+		PrevBlockID: proposal.Block.PrevBlockID,
+	}
+	equivocation := &Message{
+		Type:        PROPOSE,
+		Epoch:       proposal.Epoch,
+		Block:       block,
+		Certificate: proposal.Certificate,
+		Sender:      proposal.Sender,
+		SenderFwd:   proposal.SenderFwd,
+	}
+	partition := make(map[int]bool)
+	for _, id := range a.Config.Partition {
+		partition[id] = true
+	}
+	if len(partition) == 0 {
+		for id := 0; id < a.NumProcesses()/2; id++ {
+			partition[id] = true
+		}
+	}
+	var first []int
+	for id := range partition {
+		first = append(first, id)
+	}
+	sort.Ints(first)
+	a.Deliver(&Outgoing{Message: proposal, To: first})
+	a.Deliver(&Outgoing{Message: equivocation, To: a.Others(partition)})
+	if !e.vote {
+		return
+	}
+	// Proposals are signed once sent
+	for _, p := range []*Message{proposal, equivocation} {
+		vote := NewVoteMessage(p.Epoch, p.Block.BlockID(), p.Block.Height, int16(a.ID()), int16(a.ID()))
+		vote.Signature2 = p.Signature
+		a.Deliver(&Outgoing{Message: vote})
+	}
+}
+
+// withholdVotesAttack sends no vote.
+type withholdVotesAttack struct {
+	Honest
+}
+
+func (withholdVotesAttack) Send(a *Attacker, out *Outgoing) {
+	if out.Message.Type != VOTE || out.Forwarded {
+		a.Deliver(out)
+	}
+}
+
+// delayProposalAttack delays the dissemination of its proposals.
+type delayProposalAttack struct {
+	Honest
+}
+
+func (delayProposalAttack) Send(a *Attacker, out *Outgoing) {
+	if out.Forwarded || out.Message.Sender != a.ID() {
+		a.Deliver(out)
+		return
+	}
+	switch out.Message.Type {
+	case PROPOSE, PAYLOAD, CHUNK:
+		a.Delay(out)
+	default:
+		a.Deliver(out)
+	}
+}
+
+// selectiveForwardingAttack forwards messages only to Byzantine processes,
+// as signed by their sender.
+type selectiveForwardingAttack struct {
+	Honest
+}
+
+func (selectiveForwardingAttack) Send(a *Attacker, out *Outgoing) {
+	if !out.Forwarded {
+		a.Deliver(out)
+		return
+	}
+	var byzantines []int
+	for id := 0; id < a.NumProcesses(); id++ {
+		if a.Config.Byzantines[id] && id != a.ID() {
+			byzantines = append(byzantines, id)
+		}
+	}
+	if len(byzantines) > 0 {
+		a.Deliver(&Outgoing{Message: out.Message, To: byzantines, Forwarded: true})
+	}
+}
+
+// withholdCertificateAttack does not send its locked certificate, nor the
+// certificates finishing an epoch, to the proposer of the next epoch.
+type withholdCertificateAttack struct {
+	Honest
+}
+
+func (withholdCertificateAttack) Send(a *Attacker, out *Outgoing) {
+	switch out.Message.Type {
+	case CERTIFICATE:
+	case QUIT_EPOCH:
+		next := a.Proposer(out.Message.Epoch + 1)
+		a.Deliver(&Outgoing{Message: out.Message, To: a.Others(map[int]bool{next: true})})
+	default:
+		a.Deliver(out)
+	}
+}
@@ -0,0 +1,168 @@
+package consensus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAttacks(t *testing.T) {
+	names := Attacks()
+	if len(names) != 9 {
+		t.Error("Expected 9 registered attacks, got", names)
+	}
+	for _, name := range names {
+		if NewAttack(name) == nil {
+			t.Errorf("Attack %v is not registered", name)
+		}
+	}
+	if NewAttack("unknown") != nil {
+		t.Error("Expected no attack with an unknown name")
+	}
+}
+
+// testAttacker runs an attack in epoch MIN_EPOCH of process id, out of 4
+// processes, whose proposer is process 0.
+func testAttacker(id int, name string, config AttackConfig) (*Attacker, *TestProcess) {
+	p := NewTestProcess(id, 4)
+	honest := NewFastAlterBFT(MIN_EPOCH, p, true)
+	return NewAttacker(honest, NewAttack(name), config), p
+}
+
+func TestSilenceAttack(t *testing.T) {
+	a, p := testAttacker(1, SILENCE_ATTACK, AttackConfig{})
+	a.Start(nil, false)
+	a.ProcessMessage(testReceivedProposal(&Block{Value: testRandValue(100), Epoch: MIN_EPOCH}))
+	a.honest.broadcastSilence()
+	// The attacker keeps voting
+	if len(p.state.outgoing) != 2 {
+		t.Fatal("Expected only the forwarded proposal and the vote to be sent, got", len(p.state.outgoing))
+	}
+	if out := p.state.outgoing[0]; out.Message.Type != PROPOSE || !out.Forwarded {
+		t.Error("Expected the proposal to be forwarded")
+	}
+	if out := p.state.outgoing[1]; out.Message.Type != VOTE {
+		t.Error("Expected the vote to be broadcast")
+	}
+}
+
+func TestCrashAttack(t *testing.T) {
+	a, p := testAttacker(1, CRASH_ATTACK, AttackConfig{})
+	a.Start(nil, false)
+	a.ProcessMessage(testReceivedProposal(&Block{Value: testRandValue(100), Epoch: MIN_EPOCH}))
+	a.honest.broadcastSilence()
+	if len(p.state.outgoing) != 0 {
+		t.Error("Expected no message to be sent, got", len(p.state.outgoing))
+	}
+}
+
+func TestWithholdVotesAttack(t *testing.T) {
+	a, p := testAttacker(1, WITHHOLD_VOTES, AttackConfig{})
+	a.Start(nil, false)
+	a.ProcessMessage(testReceivedProposal(&Block{Value: testRandValue(100), Epoch: MIN_EPOCH}))
+	forwarded := false
+	for _, out := range p.state.outgoing {
+		if out.Message.Type == VOTE {
+			t.Error("Expected no vote to be sent")
+		}
+		forwarded = forwarded || (out.Message.Type == PROPOSE && out.Forwarded)
+	}
+	if !forwarded {
+		t.Error("Expected the proposal to be forwarded")
+	}
+}
+
+func TestDelayProposalAttack(t *testing.T) {
+	config := AttackConfig{Delay: 3 * time.Second}
+	a, p := testAttacker(0, DELAY_PROPOSAL, config)
+	a.honest.ErasureCoding = true
+	a.Start(nil, false)
+	// The proposal and the chunks of the 4 processes are delayed
+	if len(p.state.outgoing) != 0 || len(p.state.timeoutQueue) != 5 {
+		t.Fatalf("Expected 5 delayed messages, got %v sent and %v timeouts",
+			len(p.state.outgoing), len(p.state.timeoutQueue))
+	}
+	for _, timeout := range p.state.timeoutQueue {
+		if timeout.Type != TimeoutAttack || timeout.Duration != config.Delay {
+			t.Error("Unexpected timeout", timeout)
+		}
+		a.ProcessTimeout(timeout)
+	}
+	types := []int16{PROPOSE, CHUNK, CHUNK, CHUNK, CHUNK}
+	if len(p.state.outgoing) != len(types) {
+		t.Fatal("Expected delayed messages to be sent on attack timeouts, got", len(p.state.outgoing))
+	}
+	for i, out := range p.state.outgoing {
+		if out.Message.Type != types[i] {
+			t.Errorf("Expected message %v of type %v, got %v", i, types[i], out.Message.Type)
+		}
+	}
+}
+
+func TestSelectiveForwardingAttack(t *testing.T) {
+	config := AttackConfig{Byzantines: map[int]bool{1: true, 3: true}}
+	a, p := testAttacker(1, SELECTIVE_FORWARDING, config)
+	a.Start(nil, false)
+	a.ProcessMessage(testReceivedProposal(&Block{Value: testRandValue(100), Epoch: MIN_EPOCH}))
+	// The proposal is forwarded by sending it to the other byzantine process
+	forwarded, voted := false, false
+	for _, out := range p.state.outgoing {
+		if out.Forwarded || (out.Message.Type == PROPOSE && (len(out.To) != 1 || out.To[0] != 3)) {
+			t.Error("Expected the proposal to be forwarded to process 3 only, got", out.To)
+		}
+		forwarded = forwarded || out.Message.Type == PROPOSE
+		voted = voted || (out.Message.Type == VOTE && out.To == nil)
+	}
+	if !forwarded || !voted {
+		t.Error("Expected the proposal to be forwarded and the vote to be broadcast")
+	}
+}
+
+func TestWithholdCertificateAttack(t *testing.T) {
+	a, p := testAttacker(1, WITHHOLD_CERTIFICATE, AttackConfig{})
+	a.Start(nil, false)
+	cert := NewBlockCertificate(MIN_EPOCH, testRandValue(BlockIDSize), MIN_HEIGHT)
+	a.honest.lockedCertificate = cert
+	a.honest.sendCertificateToLeader()
+	a.honest.broadcastQuitEpoch(cert)
+	// Process 0 is the proposer of the next epoch
+	if len(p.state.outgoing) != 1 || p.state.outgoing[0].Message.Type != QUIT_EPOCH {
+		t.Fatal("Expected only the QUIT_EPOCH message to be sent")
+	}
+	to := p.state.outgoing[0].To
+	if len(to) != 3 || to[0] != 1 || to[1] != 2 || to[2] != 3 {
+		t.Error("Expected QUIT_EPOCH not to be sent to the next proposer, got", to)
+	}
+}
+
+func TestEquivocationAttack(t *testing.T) {
+	a, p := testAttacker(0, EQUIVOCATION_ATTACK, AttackConfig{Partition: []int{1}})
+	a.Start(nil, false)
+	// The proposer votes for both of its proposals
+	if len(p.state.outgoing) != 4 {
+		t.Fatal("Expected two proposals and two votes, got", len(p.state.outgoing))
+	}
+	for i := 0; i < 2; i++ {
+		proposal, vote := p.state.outgoing[i].Message, p.state.outgoing[i+2]
+		if proposal.Type != PROPOSE || vote.Message.Type != VOTE || vote.To != nil ||
+			!vote.Message.BlockID.Equal(proposal.Block.BlockID()) {
+			t.Errorf("Expected the vote for proposal %v to be broadcast", i)
+		}
+	}
+}
+
+func TestEquivocationWithoutVotesAttack(t *testing.T) {
+	a, p := testAttacker(0, EQUIVOCATION_WITHOUT_VOTES, AttackConfig{Partition: []int{1}})
+	a.Start(nil, false)
+	if len(p.state.outgoing) != 2 {
+		t.Fatal("Expected two proposals, got", len(p.state.outgoing))
+	}
+	first, second := p.state.outgoing[0], p.state.outgoing[1]
+	if len(first.To) != 1 || first.To[0] != 1 ||
+		len(second.To) != 3 || second.To[0] != 0 || second.To[1] != 2 || second.To[2] != 3 {
+		t.Error("Expected proposals to be sent to the partitions, got", first.To, second.To)
+	}
+	if first.Message.Type != PROPOSE || second.Message.Type != PROPOSE ||
+		first.Message.Block.BlockID().Equal(second.Message.Block.BlockID()) {
+		t.Error("Expected the partitions to receive different blocks")
+	}
+}
@@ -9,6 +9,7 @@ import (
 // Block is a block abstraction
 type Block struct {
 	Height      int64   // Block height
+	Epoch       int64   // Epoch in which the block was proposed
 	Value       []byte  // Block value
 	PrevBlockID BlockID // Hash of the previous block
 
@@ -19,13 +20,20 @@ type Block struct {
 	prevBlock *Block
 	// Set in order not to calcualte hash of a block each time
 	blockID BlockID
+	// Hash of the value, set for block headers, whose value is
+	// disseminated separately
+	payloadHash []byte
+	header      bool
 }
 
+// PayloadHashSize is the size of the hash of a block value.
+const PayloadHashSize = sha256.Size
+
 func (b *Block) String() string {
 	if b == nil {
 		return ""
 	}
-	return fmt.Sprintf("Height: %v\nValue: %v\nPrevBlock:%v\n", b.Height, b.Value, b.PrevBlockID)
+	return fmt.Sprintf("Height: %v\nEpoch: %v\nValue: %v\nPrevBlock:%v\n", b.Height, b.Epoch, b.Value, b.PrevBlockID)
 }
 
 // NewBlock returns a pointer to an instance of block.
@@ -50,8 +58,11 @@ func (b *Block) Equal(bb *Block) bool {
 	if b == nil || bb == nil {
 		return b == bb
 	}
-	return (b.Height == bb.Height &&
-		bytes.Equal(b.Value, bb.Value) &&
+	sameValue := bytes.Equal(b.Value, bb.Value)
+	if b.header || bb.header {
+		sameValue = b.header == bb.header && bytes.Equal(b.PayloadHash(), bb.PayloadHash())
+	}
+	return (b.Height == bb.Height && b.Epoch == bb.Epoch && sameValue &&
 		b.PrevBlockID.Equal(bb.PrevBlockID))
 }
 
@@ -72,25 +83,97 @@ func (b *Block) Extend(bb *Block) bool {
 	return false
 }
 
-// BlockID returns a hash of the full block.
+// BlockID returns a hash of the block header, which includes the hash of the
+// value, so that a block and its header have the same ID.
 func (b *Block) BlockID() BlockID {
 	if b.blockID == nil {
-		if b.marshalled == nil {
-			b.Marshall()
+		header := b
+		if !b.header {
+			header = b.Header()
 		}
-		hash := sha256.Sum256(b.marshalled)
+		hash := sha256.Sum256(header.Marshall())
 		b.blockID = hash[:]
 	}
 	return b.blockID
 }
 
+// PayloadHash returns the hash of the block value.
+func (b *Block) PayloadHash() []byte {
+	if b.payloadHash == nil {
+		hash := sha256.Sum256(b.Value)
+		b.payloadHash = hash[:]
+	}
+	return b.payloadHash
+}
+
+// Header returns the header of the block, carrying the hash of the value
+// instead of the value.
+func (b *Block) Header() *Block {
+	return &Block{
+		Height:      b.Height,
+		Epoch:       b.Epoch,
+		PrevBlockID: b.PrevBlockID,
+		blockID:     b.blockID,
+		payloadHash: b.PayloadHash(),
+		header:      true,
+	}
+}
+
+// HasPayload returns false if the block is a header whose value has not
+// been received yet.
+func (b *Block) HasPayload() bool {
+	return !b.header
+}
+
+// SetPayload completes a block header with its value, returns false if the
+// value does not match the hash in the header.
+func (b *Block) SetPayload(value []byte) bool {
+	if !b.header {
+		return bytes.Equal(b.Value, value)
+	}
+	hash := sha256.Sum256(value)
+	if !bytes.Equal(hash[:], b.payloadHash) {
+		return false
+	}
+	b.Value = value
+	b.header = false
+	b.marshalled = nil
+	return true
+}
+
+// missingPayload returns the oldest block without payload among the block and
+// its ancestors linked in the blockchain, nil if every payload is known.
+func (b *Block) missingPayload() *Block {
+	var missing *Block
+	for bb := b; bb != nil; bb = bb.prevBlock {
+		if !bb.HasPayload() {
+			missing = bb
+		}
+	}
+	return missing
+}
+
+// ancestor returns the block with the provided ID among the block and its
+// ancestors linked in the blockchain, nil if not found.
+func (b *Block) ancestor(blockID BlockID) *Block {
+	for bb := b; bb != nil; bb = bb.prevBlock {
+		if bb.BlockID().Equal(blockID) {
+			return bb
+		}
+	}
+	return nil
+}
+
 // ByteSize returns block size in bytes.
 func (b *Block) ByteSize() int {
-	if b.PrevBlockID == nil {
-		return len(b.Value) + 8
-	} else {
-		return len(b.Value) + BlockIDSize + 8
+	size := len(b.Value) + 16
+	if b.header {
+		size = PayloadHashSize + 16
+	}
+	if b.PrevBlockID != nil {
+		size += BlockIDSize
 	}
+	return size
 }
 
 // Marshall returns marshalled version of a block.
@@ -105,11 +188,16 @@ func (b *Block) Marshall() []byte {
 // MarshallTo marshall block to the buffer.
 func (b *Block) MarshallTo(buffer []byte) int {
 	encoding.PutUint64(buffer[0:], uint64(b.Height))
-	pos := 8
+	encoding.PutUint64(buffer[8:], uint64(b.Epoch))
+	pos := 16
 	if b.PrevBlockID != nil {
 		b.PrevBlockID.MarshallTo(buffer[pos:])
 		pos += BlockIDSize
 	}
+	if b.header {
+		copy(buffer[pos:], b.payloadHash)
+		return pos + PayloadHashSize
+	}
 	copy(buffer[pos:], b.Value)
 	return pos + len(b.Value)
 }
@@ -117,8 +205,9 @@ func (b *Block) MarshallTo(buffer []byte) int {
 // BlockFromBytes unmarshall block from a buffer.
 func BlockFromBytes(buffer []byte) *Block {
 	height := int64(encoding.Uint64(buffer[0:]))
+	epoch := int64(encoding.Uint64(buffer[8:]))
 	var prevBlockID BlockID = nil
-	pos := 8
+	pos := 16
 	if height > MIN_HEIGHT { // it has prevBlockID
 		prevBlockID = BlockIDFromBytes(buffer[pos:])
 		pos += BlockIDSize
@@ -126,6 +215,7 @@ func BlockFromBytes(buffer []byte) *Block {
 	value := buffer[pos:]
 	return &Block{
 		Height:      height,
+		Epoch:       epoch,
 		Value:       value,
 		PrevBlockID: prevBlockID,
 
@@ -133,6 +223,15 @@ func BlockFromBytes(buffer []byte) *Block {
 	}
 }
 
+// BlockHeaderFromBytes unmarshall a block header from a buffer.
+func BlockHeaderFromBytes(buffer []byte) *Block {
+	block := BlockFromBytes(buffer)
+	block.payloadHash = block.Value[:PayloadHashSize]
+	block.Value = nil
+	block.header = true
+	return block
+}
+
 const BlockIDSize = sha256.Size
 
 // BlockID represents a hash of a block.
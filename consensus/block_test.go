@@ -1,6 +1,7 @@
 package consensus
 
 import (
+	"bytes"
 	"math/rand"
 	"testing"
 	"time"
@@ -197,3 +198,25 @@ func TestBlockIDMarshalling(t *testing.T) {
 		t.Errorf("Marshalling of blockID is not working expected \n%v, returned \n%v\n", b, bb)
 	}
 }
+
+func TestBlockHeader(t *testing.T) {
+	b0 := NewBlock(testRandValue(1024), nil)
+	b1 := NewBlock(testRandValue(1024), b0)
+	h := b1.Header()
+	// Test 1: Header has the ID of the block.
+	if !h.BlockID().Equal(b1.BlockID()) || h.HasPayload() {
+		t.Errorf("Header has ID %v, expected %v", h.BlockID(), b1.BlockID())
+	}
+	// Test 2: Marshalling of header.
+	hh := BlockHeaderFromBytes(h.Marshall())
+	if !hh.Equal(h) || !hh.BlockID().Equal(b1.BlockID()) || h.ByteSize() >= b1.ByteSize() {
+		t.Errorf("Marshalling of header is not working expected \n%v, returned \n%v\n", h, hh)
+	}
+	// Test 3: Header is completed only with the value of the block.
+	if hh.SetPayload(testRandValue(1024)) || !hh.SetPayload(b1.Value) {
+		t.Errorf("Header completed with a wrong value")
+	}
+	if !hh.HasPayload() || !hh.Equal(b1) || !bytes.Equal(hh.Marshall(), b1.Marshall()) {
+		t.Errorf("Completed header is \n%v, expected \n%v\n", hh, b1)
+	}
+}
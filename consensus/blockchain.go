@@ -34,6 +34,9 @@ type Blockchain struct {
 	Size         int
 	LastCommited *Block
 	Chain        []*heightData
+	// Recently commited blocks, indexed by height, served to processes
+	// that missed them.
+	Commited []*Block
 }
 
 func NewBlockchain(size int) *Blockchain {
@@ -41,6 +44,7 @@ func NewBlockchain(size int) *Blockchain {
 		Size:         size,
 		LastCommited: nil,
 		Chain:        make([]*heightData, size),
+		Commited:     make([]*Block, size),
 	}
 }
 
@@ -137,11 +141,53 @@ func (b *Blockchain) Commit(block *Block) []*Block {
 		b.resetHeightData(bb.Height)
 		bb = bb.prevBlock
 	}
+	for _, bb := range blocks {
+		b.Commited[bb.Height%int64(b.Size)] = bb
+	}
 	block.prevBlock = nil
 	b.LastCommited = block
 	return blocks
 }
 
+// GetBlock returns a block with the provided height and ID, either commited
+// or a candidate, if present in the blockchain.
+func (b *Blockchain) GetBlock(height int64, blockID BlockID) *Block {
+	if height < MIN_HEIGHT {
+		return nil
+	}
+	index := height % int64(b.Size)
+	if bb := b.Commited[index]; bb != nil && bb.Height == height && bb.BlockID().Equal(blockID) {
+		return bb
+	}
+	if hd := b.Chain[index]; hd != nil && hd.height == height {
+		return hd.getCandidate(blockID)
+	}
+	return nil
+}
+
+// MissingAncestor returns the height and ID of the first missing ancestor of
+// a block, needed to link it to the last commited block.
+// The returned ID is nil if no ancestor is missing.
+func (b *Blockchain) MissingAncestor(block *Block) (int64, BlockID) {
+	tmp := block
+	for tmp.prevBlock != nil {
+		tmp = tmp.prevBlock
+	}
+	if tmp.Height == MIN_HEIGHT || tmp.Equal(b.LastCommited) ||
+		(b.LastCommited != nil && tmp.Height <= b.LastCommited.Height) {
+		return 0, nil
+	}
+	return tmp.Height - 1, tmp.PrevBlockID
+}
+
+// Restore sets the last commited block of an empty blockchain, when
+// recovering the state of a process that has already committed blocks.
+func (b *Blockchain) Restore(block *Block) {
+	block.prevBlock = nil
+	b.LastCommited = block
+	b.Commited[block.Height%int64(b.Size)] = block
+}
+
 // ExtendValidChain returns if block extend last commited block.
 func (b *Blockchain) ExtendValidChain(block *Block) bool {
 	tmp := block
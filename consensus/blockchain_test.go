@@ -220,6 +220,42 @@ func TestExtend(t *testing.T) {
 
 }
 
+func TestBlockchainGetBlock(t *testing.T) {
+	blockchain := NewBlockchain(10)
+	b0 := NewBlock(testRandValue(1024), nil)
+	b1 := NewBlock(testRandValue(1024), b0)
+	b2 := NewBlock(testRandValue(1024), b1)
+	b3 := NewBlock(testRandValue(1024), b2)
+	blockchain.AddBlock(b0)
+	blockchain.AddBlock(b1)
+	if blockchain.GetBlock(b1.Height, b1.BlockID()) != b1 {
+		t.Error("GetBlock() did not return candidate block!")
+	}
+	if blockchain.GetBlock(b2.Height, b2.BlockID()) != nil {
+		t.Error("GetBlock() returned block not in the blockchain!")
+	}
+	blockchain.Commit(b1)
+	if blockchain.GetBlock(b0.Height, b0.BlockID()) != b0 {
+		t.Error("GetBlock() did not return commited block!")
+	}
+	if blockchain.GetBlock(b1.Height, b0.BlockID()) != nil {
+		t.Error("GetBlock() returned block with a different height!")
+	}
+
+	// b2 is missing, so b3 does not extend the commited chain
+	blockchain.AddBlock(b3)
+	if height, blockID := blockchain.MissingAncestor(b3); height != b2.Height || !blockID.Equal(b2.BlockID()) {
+		t.Error("MissingAncestor() returned", height, blockID, "expected", b2.Height, b2.BlockID())
+	}
+	blockchain.AddBlock(b2)
+	if _, blockID := blockchain.MissingAncestor(b3); blockID != nil {
+		t.Error("MissingAncestor() returned", blockID, "expected none!")
+	}
+	if blockchain.ExtendValidChain(b3) == false {
+		t.Error("ExtendValidChain() returned false expected true!")
+	}
+}
+
 func (b *Blockchain) print() string {
 	s := fmt.Sprintf("Last commited: %v\n", b.LastCommited.BlockID())
 	for i := 0; i < b.Size; i++ {
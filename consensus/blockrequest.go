@@ -0,0 +1,76 @@
+package consensus
+
+// TimeoutBlockRequest is the type of the timeouts re-sending the block
+// requests that were not answered.
+const TimeoutBlockRequest = TimeoutPrecommit + 1
+
+// blockRequests tracks the blocks requested by a consensus instance.
+//
+// Requests and responses may be lost, and processes pace their responses,
+// dropping the requests over their rate. Requests not answered within a
+// timeout are sent again to every other member of the validator set.
+type blockRequests struct {
+	// Requests not answered, by block ID
+	pending map[string]*Message
+	// Whether a TimeoutBlockRequest is scheduled
+	scheduled bool
+}
+
+func newBlockRequests() *blockRequests {
+	return &blockRequests{
+		pending: make(map[string]*Message),
+	}
+}
+
+// send requests a block from the provided processes, or from every other
+// member if none is provided. A block is requested only once, until the
+// request is sent again on TimeoutBlockRequest.
+func (r *blockRequests) send(process Process, epoch int64, height int64, blockID BlockID, ids ...int) {
+	if _, ok := r.pending[string(blockID)]; ok {
+		return
+	}
+	var peers []int
+	for _, id := range ids {
+		if id != process.ID() {
+			peers = append(peers, id)
+		}
+	}
+	if len(peers) == 0 {
+		for _, id := range process.Validators(epoch).Members() {
+			if id != process.ID() {
+				peers = append(peers, id)
+			}
+		}
+	}
+	request := NewBlockRequestMessage(epoch, height, blockID, process.ID())
+	r.pending[string(blockID)] = request
+	process.Send(request, peers...)
+	if !r.scheduled {
+		r.scheduled = true
+		// A request is a small message, the response a big one
+		process.Schedule(&Timeout{
+			Type:     TimeoutBlockRequest,
+			Epoch:    epoch,
+			Duration: process.TimeoutPropose(epoch),
+		})
+	}
+}
+
+// received returns whether a block was requested, forgetting its request.
+func (r *blockRequests) received(blockID BlockID) bool {
+	if _, ok := r.pending[string(blockID)]; !ok {
+		return false
+	}
+	delete(r.pending, string(blockID))
+	return true
+}
+
+// retry sends again, to every other member, the requests not answered.
+func (r *blockRequests) retry(process Process, epoch int64) {
+	r.scheduled = false
+	pending := r.pending
+	r.pending = make(map[string]*Message)
+	for _, request := range pending {
+		r.send(process, epoch, request.Height, request.BlockID)
+	}
+}
@@ -2,6 +2,7 @@ package consensus
 
 import (
 	"fmt"
+	"sort"
 
 	"dslab.inf.usi.ch/tendermint/crypto"
 )
@@ -16,6 +17,9 @@ const (
 // MessageSignatureSize is the byte size of the signature portion of a message.
 const MessageSignatureSize = SignatureSize + 2 // Sender (uint16)
 
+// Flag set in the type byte of certificates with the aggregate encoding.
+const aggregateCertFlag = 0x80
+
 // A Certificate aggregates identical messages signed by multiple senders.
 type Certificate struct {
 	// Signed payload
@@ -31,6 +35,22 @@ type Certificate struct {
 	// Signatures
 	Signatures map[int]Signature
 
+	// Aggregate signature of the signers in aggregateSigners, in increasing
+	// order, disjoint from the signers of the individual Signatures.
+	aggregate        Signature
+	aggregateSigners []int
+
+	// Whether the certificate is encoded with an aggregate signature.
+	aggregated bool
+
+	// Set when unmarshalling a certificate with repeated signers.
+	duplicateSigners bool
+
+	// Accumulated voting power of the signers, tracked by Weight.
+	weight        int64
+	weightSigners int
+	weightRule    QuorumRule
+
 	// A certificate has the same payload signed by multiple replicas.
 	// It is set when unmarshalling the message.
 	payload []byte
@@ -62,33 +82,123 @@ func NewSilenceCertificate(epoch int64) *Certificate {
 	}
 }
 
+// Equal returns whether the certificates have the same payload and signers.
+// Signatures are compared when both certificates have them in the same form.
 func (c *Certificate) Equal(cc *Certificate) bool {
 	if c == nil || cc == nil {
 		return c == cc
 	}
 	if !c.blockID.Equal(cc.blockID) ||
 		c.Epoch != cc.Epoch || c.Type != cc.Type ||
-		len(c.Signatures) != len(cc.Signatures) {
+		c.SignatureCount() != cc.SignatureCount() {
 		return false
 	}
-	for i, _ := range c.Signatures {
-		if !c.Signatures[i].Equal(cc.Signatures[i]) {
+	signers, ssigners := c.Signers(), cc.Signers()
+	for i := range signers {
+		if signers[i] != ssigners[i] {
 			return false
 		}
 	}
+	for i, s := range c.Signatures {
+		if ss, ok := cc.Signatures[i]; ok && !s.Equal(ss) {
+			return false
+		}
+	}
+	if c.aggregate != nil && cc.aggregate != nil &&
+		len(c.aggregateSigners) == len(cc.aggregateSigners) {
+		return c.aggregate.Equal(cc.aggregate)
+	}
 	return true
 }
 
 // AddSignature adds a signature to the certificate.
 // The signature should sign the common fields (the payload) of the certificate.
 // FIXME: the certificate signatures are shared with the certified messages.
+//
+// The signature is not checked against the certificate payload: callers add
+// the signatures of the messages they matched to the certificate, and some
+// certificates, as the prevotes for any block counted by Tendermint, collect
+// signatures of different payloads. Received certificates are checked by
+// Validate, and their signatures are verified against the payload, see
+// GetCryptoSignatures.
 func (c *Certificate) AddSignature(s Signature, sender int) bool {
-	// FIXME: check if the message matched the certificate?
-	if _, ok := c.Signatures[sender]; !ok {
-		c.Signatures[sender] = s
+	if _, ok := c.Signatures[sender]; ok || c.hasAggregateSigner(sender) {
+		return false
+	}
+	c.Signatures[sender] = s
+	return true
+}
+
+// Aggregate selects the aggregate encoding of the certificate, carrying a
+// bitmap of the signers and the aggregate of their signatures, which must
+// be BLS signatures. It returns false, keeping the encoding with individual
+// signatures, if the signatures cannot be aggregated.
+func (c *Certificate) Aggregate() bool {
+	if c.aggregated {
 		return true
 	}
-	return false
+	if _, err := c.aggregateSignature(); err != nil {
+		return false
+	}
+	c.aggregated = true
+	c.marshalled = nil
+	return true
+}
+
+// Aggregated returns whether the certificate has the aggregate encoding.
+func (c *Certificate) Aggregated() bool {
+	return c.aggregated
+}
+
+func (c *Certificate) hasAggregateSigner(sender int) bool {
+	i := sort.SearchInts(c.aggregateSigners, sender)
+	return i < len(c.aggregateSigners) && c.aggregateSigners[i] == sender
+}
+
+// Aggregates the aggregate signature and the individual signatures.
+func (c *Certificate) aggregateSignature() (Signature, error) {
+	if len(c.Signatures) == 0 {
+		return c.aggregate, nil
+	}
+	signatures := make([][]byte, 0, len(c.Signatures)+1)
+	if c.aggregate != nil {
+		signatures = append(signatures, c.aggregate)
+	}
+	for _, signature := range c.Signatures {
+		signatures = append(signatures, signature)
+	}
+	return crypto.AggregateSignatures(signatures)
+}
+
+// Validate checks that a received certificate is well-formed and complete:
+// it has a valid type and payload, its signers are distinct processes with
+// IDs lower than numProcesses, and they form a quorum according to the rule.
+// Signatures are verified separately, see GetCryptoSignatures.
+func (c *Certificate) Validate(numProcesses int, rule QuorumRule) error {
+	switch c.Type {
+	case BLOCK_CERT:
+		if len(c.blockID) != BlockIDSize || c.Height < MIN_HEIGHT {
+			return fmt.Errorf("invalid block certificate payload")
+		}
+	case SILENCE_CERT:
+	default:
+		return fmt.Errorf("invalid certificate type %v", c.Type)
+	}
+	if c.Epoch < MIN_EPOCH {
+		return fmt.Errorf("invalid certificate epoch %v", c.Epoch)
+	}
+	if c.duplicateSigners {
+		return fmt.Errorf("duplicated certificate signers")
+	}
+	for _, signer := range c.Signers() {
+		if signer < 0 || signer >= numProcesses {
+			return fmt.Errorf("certificate signer %v out of range", signer)
+		}
+	}
+	if !rule.IsQuorum(c) {
+		return fmt.Errorf("certificate signers are not a quorum")
+	}
+	return nil
 }
 
 func (c *Certificate) RanksHigherOrEqual(cc *Certificate) bool {
@@ -113,24 +223,74 @@ func (c *Certificate) BlockID() BlockID {
 }
 
 func (c *Certificate) SignatureCount() int {
-	return len(c.Signatures)
+	return len(c.Signatures) + len(c.aggregateSigners)
+}
+
+// Weight returns the accumulated voting power of the certificate signers,
+// according to a quorum rule. Signatures added since the last call, or a
+// different rule, are accounted for; signatures are never removed from a
+// certificate.
+func (c *Certificate) Weight(rule QuorumRule) int64 {
+	if c.weightSigners == c.SignatureCount() && c.weightRule == rule {
+		return c.weight
+	}
+	c.weight = 0
+	for _, sender := range c.Signers() {
+		c.weight += rule.VotingPower(sender)
+	}
+	c.weightSigners = c.SignatureCount()
+	c.weightRule = rule
+	return c.weight
+}
+
+// Signers returns the IDs of the certificate signers in increasing order.
+// Iterating signers in this order keeps the encoding of a certificate, and
+// the messages reconstructed from it, independent of map iteration order.
+func (c *Certificate) Signers() []int {
+	signers := make([]int, 0, c.SignatureCount())
+	signers = append(signers, c.aggregateSigners...)
+	for sender := range c.Signatures {
+		signers = append(signers, sender)
+	}
+	sort.Ints(signers)
+	return signers
 }
 
 // String returns string representation of a certificate.
 func (c *Certificate) String() string {
+	if c.aggregateSigners != nil {
+		return fmt.Sprintf("Type: %d\nEpoch: %v\nBlockID:%v\nSignatures:%v\nAggregate:%v %v\n", c.Type, c.Epoch, c.blockID, c.Signatures, c.aggregateSigners, c.aggregate)
+	}
 	return fmt.Sprintf("Type: %d\nEpoch: %v\nBlockID:%v\nSignatures:%v\n", c.Type, c.Epoch, c.blockID, c.Signatures)
 }
 
 // ByteSize returns the size of the bytes encoded version of the certificate.
 // The certificate is composed by a constant-size payload portion, plus a
 // variable number of pairs signer and signature signing the same payload.
+// In the aggregate encoding, the payload is followed by a bitmap of the
+// signers, prefixed by its size (uint16), and by the aggregate signature.
 func (c *Certificate) ByteSize() int {
-	payloadSize := 10
-	if c.Type == BLOCK_CERT {
-		payloadSize += BlockIDSize + 8
+	if c.aggregated {
+		return c.payloadSize() + 2 + bitmapSize(c.Signers()) + SignatureSize
 	}
 	numSignatures := len(c.Signatures)
-	return payloadSize + numSignatures*MessageSignatureSize
+	return c.payloadSize() + numSignatures*MessageSignatureSize
+}
+
+// Size of the payload portion of the encoded certificate.
+func (c *Certificate) payloadSize() int {
+	if c.Type == BLOCK_CERT {
+		return 10 + BlockIDSize + 8
+	}
+	return 10
+}
+
+// Size of the bitmap of signers, sorted in increasing order.
+func bitmapSize(signers []int) int {
+	if len(signers) == 0 {
+		return 0
+	}
+	return signers[len(signers)-1]/8 + 1
 }
 
 // Marshal serialises the certificate to an array of bytes.
@@ -156,24 +316,49 @@ func (c *Certificate) MarshallTo(buffer []byte) (payloadSize int) {
 		c.BlockID().MarshallTo(buffer[index:]) // BlockIDSize
 		index += BlockIDSize
 	}
+	if c.aggregated {
+		return c.marshallAggregateTo(buffer, index)
+	}
 	// 2. Number of signatures * MessageSignatureSize bytes
-	for sender, signature := range c.Signatures {
+	for _, sender := range c.Signers() {
 		encoding.PutUint16(buffer[index:], uint16(sender))
-		signature.MarshallTo(buffer[index+2:])
+		c.Signatures[sender].MarshallTo(buffer[index+2:])
 		index += MessageSignatureSize
 	}
 	return c.ByteSize()
 }
 
+// Writes the signers bitmap and the aggregate signature, from index.
+func (c *Certificate) marshallAggregateTo(buffer []byte, index int) int {
+	buffer[1] |= aggregateCertFlag
+	signers := c.Signers()
+	size := bitmapSize(signers)
+	encoding.PutUint16(buffer[index:], uint16(size))
+	index += 2
+	for i := 0; i < size; i++ {
+		buffer[index+i] = 0
+	}
+	for _, sender := range signers {
+		buffer[index+sender/8] |= 1 << (sender % 8)
+	}
+	index += size
+	aggregate, err := c.aggregateSignature()
+	if err != nil {
+		// FIXME: anything better than panicing here?
+		panic(err)
+	}
+	if aggregate == nil {
+		aggregate = make(Signature, SignatureSize)
+	}
+	aggregate.MarshallTo(buffer[index:])
+	return c.ByteSize()
+}
+
 // Payload returns the certificate payload, encoded into bytes.
 // The payload contains the common fields of the messages added to the certificate.
 func (c *Certificate) Payload() []byte {
 	c.Marshall()
-	payloadSize := 10
-	if c.Type == BLOCK_CERT {
-		payloadSize += BlockIDSize + 8
-	}
-	return c.marshalled[2:payloadSize]
+	return c.marshalled[2:c.payloadSize()]
 }
 
 // CertificateFromBytes parses a certificate from a byte array.
@@ -182,8 +367,8 @@ func CertificateFromBytes(buffer []byte) *Certificate {
 	certificate := new(Certificate)
 	certificate.marshalled = buffer
 	// 1. Payload portion
-	certificate.Type = int16(buffer[1])                    // 1 byte
-	certificate.Epoch = int64(encoding.Uint64(buffer[2:])) // 8 bytes
+	certificate.Type = int16(buffer[1] &^ aggregateCertFlag) // 1 byte
+	certificate.Epoch = int64(encoding.Uint64(buffer[2:]))   // 8 bytes
 	payloadSize := 10
 	if certificate.Type == BLOCK_CERT {
 		certificate.Height = int64(encoding.Uint64(buffer[payloadSize:]))
@@ -191,21 +376,46 @@ func CertificateFromBytes(buffer []byte) *Certificate {
 		certificate.blockID = BlockIDFromBytes(buffer[payloadSize:]) // BlockIDSize
 		payloadSize += BlockIDSize
 	}
+	certificate.Signatures = make(map[int]Signature)
+	if buffer[1]&aggregateCertFlag != 0 {
+		certificate.aggregated = true
+		size := int(encoding.Uint16(buffer[payloadSize:]))
+		index := payloadSize + 2
+		for i := 0; i < size*8; i++ {
+			if buffer[index+i/8]&(1<<(i%8)) != 0 {
+				certificate.aggregateSigners = append(certificate.aggregateSigners, i)
+			}
+		}
+		index += size
+		// An aggregate with no signers is not verified, thus not retained
+		if len(certificate.aggregateSigners) > 0 {
+			certificate.aggregate = SignatureFromBytes(buffer[index:])
+		}
+		certificate.marshalled = buffer[:index+SignatureSize]
+		return certificate
+	}
 	// 2. Number of signatures * MessageSignatureSize bytes
 	numSignatures := (len(buffer) - payloadSize) / MessageSignatureSize
-	certificate.Signatures = make(map[int]Signature)
 	for count := 0; count < numSignatures; count++ {
 		index := payloadSize + count*MessageSignatureSize
 		sender := int(encoding.Uint16(buffer[index:]))
+		if _, ok := certificate.Signatures[sender]; ok {
+			certificate.duplicateSigners = true
+		}
 		certificate.Signatures[sender] = SignatureFromBytes(buffer[index+2:])
 	}
 	return certificate
 }
 
+// ReconstructMessage reconstructs the message certified by a signer, nil if
+// its signature, or the one of the proposer of a block, is aggregated.
 func (c *Certificate) ReconstructMessage(sender int, proposer int) *Message {
 	var message *Message
 	if signature, ok := c.Signatures[sender]; ok {
 		if c.Type == BLOCK_CERT {
+			if _, ok := c.Signatures[proposer]; !ok {
+				return nil
+			}
 			message = NewVoteMessage(c.Epoch, c.BlockID(), c.Height, int16(sender), int16(proposer))
 			message.Signature2 = c.Signatures[proposer]
 		}
@@ -219,12 +429,20 @@ func (c *Certificate) ReconstructMessage(sender int, proposer int) *Message {
 }
 
 // ReconstructMessages reconstructs the messages aggregated by the certificate.
+// Messages whose signatures are aggregated cannot be reconstructed.
 // FIXME: the reconstructed messages have fields shared with this certificate.
 func (c *Certificate) ReconstructMessages(proposer int) []*Message {
+	if _, ok := c.Signatures[proposer]; c.Type == BLOCK_CERT && !ok {
+		return nil
+	}
 	messages := make([]*Message, len(c.Signatures))
 	i := 0
 	var message *Message
-	for sender, signature := range c.Signatures {
+	for _, sender := range c.Signers() {
+		signature, ok := c.Signatures[sender]
+		if !ok {
+			continue
+		}
 		if c.Type == BLOCK_CERT {
 			message = NewVoteMessage(c.Epoch, c.BlockID(), c.Height, int16(sender), int16(proposer))
 			message.Signature2 = c.Signatures[proposer]
@@ -240,13 +458,18 @@ func (c *Certificate) ReconstructMessages(proposer int) []*Message {
 	return messages
 }
 
+// GetCryptoSignatures returns the signatures of the certificate, the
+// aggregate signature of the aggregated signers being a single one.
 func (c *Certificate) GetCryptoSignatures() []*crypto.Signature {
-	n := len(c.Signatures)
-	signatures := make([]*crypto.Signature, n)
-	i := 0
-	for sender, signature := range c.Signatures {
-		signatures[i] = crypto.NewSignature(sender, c.Payload(), signature)
-		i++
+	signatures := make([]*crypto.Signature, 0, len(c.Signatures)+1)
+	if len(c.aggregateSigners) > 0 {
+		signatures = append(signatures, crypto.NewAggregateSignature(
+			c.aggregateSigners, c.Payload(), c.aggregate))
+	}
+	for _, sender := range c.Signers() {
+		if signature, ok := c.Signatures[sender]; ok {
+			signatures = append(signatures, crypto.NewSignature(sender, c.Payload(), signature))
+		}
 	}
 	return signatures
 }
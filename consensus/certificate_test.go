@@ -339,3 +339,173 @@ func TestSignatureGetCryptoSignatures(t *testing.T) {
 		}
 	}
 }
+
+func testBLSKeys(n int) ([]crypto.PrivateKey, []crypto.PublicKey) {
+	var privateKeys []crypto.PrivateKey
+	var publicKeys []crypto.PublicKey
+	for i := 0; i < n; i++ {
+		key := crypto.GenerateBLSPrivateKey()
+		privateKeys = append(privateKeys, key)
+		publicKeys = append(publicKeys, key.PubKey())
+	}
+	return privateKeys, publicKeys
+}
+
+func testVerifyCertificate(c *Certificate, keys []crypto.PublicKey) bool {
+	for _, sig := range c.GetCryptoSignatures() {
+		if !sig.Verify(keys) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAggregateCertificate(t *testing.T) {
+	e := int64(5)
+	block := NewBlock(testRandValue(1024), nil)
+	privateKeys, publicKeys := testBLSKeys(12)
+	c := testSignedCertificate(e, block, privateKeys[:10])
+	size := c.ByteSize()
+	if !c.Aggregate() || !c.Aggregated() {
+		t.Fatal("Failed to aggregate certificate")
+	}
+	if c.ByteSize() != 10+BlockIDSize+8+2+2+SignatureSize {
+		t.Error("Unexpected aggregate certificate size", c.ByteSize(), "individual", size)
+	}
+
+	quitEpoch := NewQuitEpochMessage(e, c)
+	cc := MessageFromBytes(quitEpoch.Marshall()).Certificate
+	if !cc.Aggregated() || !cc.Equal(c) || cc.SignatureCount() != 10 {
+		t.Error("Expected unmarshalled aggregate certificate, got", cc)
+	}
+	if sigs := cc.GetCryptoSignatures(); len(sigs) != 1 || len(sigs[0].Signers) != 10 {
+		t.Error("Expected a single aggregate signature, got", sigs)
+	}
+	if !testVerifyCertificate(cc, publicKeys) {
+		t.Error("Failed to verify aggregate certificate")
+	}
+	if len(cc.ReconstructMessages(0)) != 0 {
+		t.Error("Unexpected messages reconstructed from aggregate certificate")
+	}
+
+	// Signatures added to an aggregate certificate are aggregated with it
+	vote := NewVoteMessage(e, block.BlockID(), block.Height, 3, 0)
+	vote.Sign(privateKeys[3])
+	if cc.AddSignature(vote.Signature, vote.Sender) {
+		t.Error("Unexpected signature added twice")
+	}
+	vote = NewVoteMessage(e, block.BlockID(), block.Height, 11, 0)
+	vote.Sign(privateKeys[11])
+	if !cc.AddSignature(vote.Signature, vote.Sender) || cc.SignatureCount() != 11 {
+		t.Error("Failed to add signature to aggregate certificate")
+	}
+	if len(cc.GetCryptoSignatures()) != 2 || !testVerifyCertificate(cc, publicKeys) {
+		t.Error("Failed to verify aggregate certificate with individual signature")
+	}
+	b := make([]byte, cc.ByteSize())
+	cc.MarshallTo(b)
+	cc = CertificateFromBytes(b)
+	if cc.SignatureCount() != 11 || !testVerifyCertificate(cc, publicKeys) {
+		t.Error("Failed to verify re-aggregated certificate", cc)
+	}
+	signers := cc.Signers()
+	if len(signers) != 11 || signers[10] != 11 {
+		t.Error("Unexpected signers", signers)
+	}
+	// Tampering the signers invalidates the aggregate signature
+	b[len(b)-SignatureSize-1] ^= 0x01
+	if testVerifyCertificate(CertificateFromBytes(b), publicKeys) {
+		t.Error("Unexpected to verify aggregate certificate with other signers")
+	}
+
+	// Signatures other than BLS cannot be aggregated
+	ed := testBlockCertificate(e, block, 4)
+	if ed.Aggregate() || ed.Aggregated() {
+		t.Error("Unexpected aggregation of ed25519 certificate")
+	}
+}
+
+// Compares the size and verification cost of certificates of 100 signers,
+// with ed25519 signatures and with a BLS aggregate signature.
+func BenchmarkCertificateVerify(b *testing.B) {
+	block := NewBlock(testRandValue(1024), nil)
+	ed25519Keys := testGetKeys(100)
+	blsKeys, blsPublicKeys := testBLSKeys(100)
+	var ed25519PublicKeys []crypto.PublicKey
+	for _, key := range ed25519Keys {
+		ed25519PublicKeys = append(ed25519PublicKeys, key.PubKey())
+	}
+	aggregate := testSignedCertificate(MIN_EPOCH, block, blsKeys)
+	aggregate.Aggregate()
+	certificates := []struct {
+		name string
+		c    *Certificate
+		keys []crypto.PublicKey
+	}{
+		{"ed25519", testSignedCertificate(MIN_EPOCH, block, ed25519Keys), ed25519PublicKeys},
+		{"bls", testSignedCertificate(MIN_EPOCH, block, blsKeys), blsPublicKeys},
+		{"bls-aggregate", aggregate, blsPublicKeys},
+	}
+	for _, cert := range certificates {
+		c := CertificateFromBytes(cert.c.Marshall())
+		b.Run(cert.name, func(b *testing.B) {
+			b.ReportMetric(float64(c.ByteSize()), "bytes/cert")
+			for i := 0; i < b.N; i++ {
+				if !testVerifyCertificate(c, cert.keys) {
+					b.Fatal("Failed to verify certificate")
+				}
+			}
+		})
+	}
+}
+
+func TestCertificateValidate(t *testing.T) {
+	e := int64(4)
+	block := NewBlock(testRandValue(1024), nil)
+	rule := NewQuorumRule(5, nil)
+	c := testBlockCertificate(e, block, 3)
+	if err := c.Validate(5, rule); err != nil {
+		t.Error("Unexpected invalid certificate:", err)
+	}
+	if err := testBlockCertificate(e, block, 2).Validate(5, rule); err == nil {
+		t.Error("Expected certificate without quorum to be invalid")
+	}
+	if err := c.Validate(2, NewQuorumRule(2, nil)); err == nil {
+		t.Error("Expected certificate with signer out of range to be invalid")
+	}
+
+	// A certificate repeating a signer has no quorum
+	b := c.Marshall()
+	b = append(b, b[len(b)-MessageSignatureSize:]...)
+	encoding.PutUint16(b[len(b)-2*MessageSignatureSize:], 1)
+	if err := CertificateFromBytes(b).Validate(5, rule); err == nil {
+		t.Error("Expected certificate with duplicated signers to be invalid")
+	}
+	b = append([]byte{}, c.Marshall()...)
+	b[1] = 7
+	if err := CertificateFromBytes(b).Validate(5, rule); err == nil {
+		t.Error("Expected certificate with invalid type to be invalid")
+	}
+
+	validators := func(epoch int64) *ValidatorSet {
+		return NewValidatorSet(epoch, 5, nil, nil)
+	}
+	proposal := NewProposeMessage(e+1, NewBlock(testRandValue(10), block), c, 1)
+	if err := proposal.ValidateCertificate(validators); err != nil {
+		t.Error("Unexpected invalid proposal certificate:", err)
+	}
+	proposal.Certificate = testBlockCertificate(e, block, 1)
+	if err := proposal.ValidateCertificate(validators); err == nil {
+		t.Error("Expected proposal with sub-quorum certificate to be invalid")
+	}
+	silence := NewSilenceCertificate(e)
+	for _, s := range testBlockCertificate(e, block, 3).Signatures {
+		silence.AddSignature(s, silence.SignatureCount())
+	}
+	if err := NewQuitEpochMessage(e, silence).ValidateCertificate(validators); err != nil {
+		t.Error("Unexpected invalid silence certificate:", err)
+	}
+	if err := NewCertificateMessage(e, silence).ValidateCertificate(validators); err == nil {
+		t.Error("Expected certificate message with silence certificate to be invalid")
+	}
+}
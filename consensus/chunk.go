@@ -0,0 +1,158 @@
+package consensus
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	"dslab.inf.usi.ch/tendermint/erasure"
+	"github.com/tendermint/tendermint/crypto/merkle"
+)
+
+// Chunk is an erasure-coded fragment of a block value, with the proof that
+// it belongs to the chunks committed by a Merkle root. The leaves of the
+// Merkle tree cover the data of the chunks and the size of the coded value.
+//
+// A value is coded into a chunk per member of the validator set, any
+// DataChunks of which reconstruct the value. Reconstructed values are
+// authenticated by the payload hash of the signed block header.
+type Chunk struct {
+	Root  []byte   // Merkle root of the chunks
+	Index int      // Index of the chunk
+	Total int      // Total number of chunks
+	Size  int      // Size of the coded value
+	Proof [][]byte // Merkle proof, from the sibling to a child of the root
+	Data  []byte   // Chunk data
+
+	marshalled []byte
+}
+
+// DataChunks returns the number of chunks reconstructing a value coded into
+// total chunks, the number of chunks held by a majority of the processes.
+func DataChunks(total int) int {
+	return total - (total-1)/2
+}
+
+// EncodeChunks codes a value into the provided number of chunks.
+func EncodeChunks(value []byte, total int) []*Chunk {
+	coder, err := erasure.NewCoder(DataChunks(total), total)
+	if err != nil {
+		// FIXME: anything better than panicing here?
+		panic(err)
+	}
+	shards := coder.Encode(value)
+	leaves := make([][]byte, total)
+	for i, shard := range shards {
+		leaves[i] = chunkLeaf(len(value), shard)
+	}
+	root, proofs := merkle.ProofsFromByteSlices(leaves)
+	chunks := make([]*Chunk, total)
+	for i, shard := range shards {
+		chunks[i] = &Chunk{
+			Root:  root,
+			Index: i,
+			Total: total,
+			Size:  len(value),
+			Proof: proofs[i].Aunts,
+			Data:  shard,
+		}
+	}
+	return chunks
+}
+
+// DecodeChunks reconstructs a value from chunks with the same root.
+func DecodeChunks(chunks []*Chunk) ([]byte, error) {
+	if len(chunks) == 0 {
+		return nil, erasure.ErrTooFewShards
+	}
+	total, size := chunks[0].Total, chunks[0].Size
+	coder, err := erasure.NewCoder(DataChunks(total), total)
+	if err != nil {
+		return nil, err
+	}
+	shards := make([][]byte, total)
+	for _, chunk := range chunks {
+		if chunk.Total != total || chunk.Size != size || !bytes.Equal(chunk.Root, chunks[0].Root) {
+			return nil, fmt.Errorf("chunk %v does not belong to the coded value", chunk.Index)
+		}
+		shards[chunk.Index] = chunk.Data
+	}
+	return coder.Decode(shards, size)
+}
+
+// Verify checks the Merkle proof of the chunk, which covers its data and the
+// size of the coded value.
+func (c *Chunk) Verify() bool {
+	if c.Index < 0 || c.Index >= c.Total || len(c.Root) != sha256.Size {
+		return false
+	}
+	leaf := chunkLeaf(c.Size, c.Data)
+	proof := &merkle.Proof{
+		Total: int64(c.Total),
+		Index: int64(c.Index),
+		// The hash of a tree with a single leaf is the leaf hash
+		LeafHash: merkle.HashFromByteSlices([][]byte{leaf}),
+		Aunts:    c.Proof,
+	}
+	return proof.Verify(c.Root, leaf) == nil
+}
+
+// Leaf of the Merkle tree of a chunk: [Size 4][Data]
+func chunkLeaf(size int, data []byte) []byte {
+	leaf := make([]byte, 4+len(data))
+	encoding.PutUint32(leaf, uint32(size))
+	copy(leaf[4:], data)
+	return leaf
+}
+
+// ByteSize returns the size of the encoded chunk.
+// Layout: [Root][Index 2][Total 2][Size 4][aunts 1][Proof][data size 4][Data]
+func (c *Chunk) ByteSize() int {
+	return sha256.Size + 13 + len(c.Proof)*sha256.Size + len(c.Data)
+}
+
+// Marshall returns the encoded chunk.
+func (c *Chunk) Marshall() []byte {
+	if c.marshalled == nil {
+		c.marshalled = make([]byte, c.ByteSize())
+		c.MarshallTo(c.marshalled)
+	}
+	return c.marshalled
+}
+
+// MarshallTo encodes the chunk into the buffer, returns the encoded size.
+func (c *Chunk) MarshallTo(buffer []byte) int {
+	index := copy(buffer, c.Root[:sha256.Size])
+	encoding.PutUint16(buffer[index:], uint16(c.Index))
+	encoding.PutUint16(buffer[index+2:], uint16(c.Total))
+	encoding.PutUint32(buffer[index+4:], uint32(c.Size))
+	buffer[index+8] = byte(len(c.Proof))
+	index += 9
+	for _, aunt := range c.Proof {
+		index += copy(buffer[index:], aunt[:sha256.Size])
+	}
+	encoding.PutUint32(buffer[index:], uint32(len(c.Data)))
+	index += 4
+	index += copy(buffer[index:], c.Data)
+	return index
+}
+
+// ChunkFromBytes decodes a chunk from a buffer, which is retained.
+func ChunkFromBytes(buffer []byte) *Chunk {
+	c := &Chunk{Root: buffer[:sha256.Size]}
+	index := sha256.Size
+	c.Index = int(encoding.Uint16(buffer[index:]))
+	c.Total = int(encoding.Uint16(buffer[index+2:]))
+	c.Size = int(encoding.Uint32(buffer[index+4:]))
+	aunts := int(buffer[index+8])
+	index += 9
+	for i := 0; i < aunts; i++ {
+		c.Proof = append(c.Proof, buffer[index:index+sha256.Size])
+		index += sha256.Size
+	}
+	n := int(encoding.Uint32(buffer[index:]))
+	index += 4
+	c.Data = buffer[index : index+n]
+	c.marshalled = buffer[:index+n]
+	return c
+}
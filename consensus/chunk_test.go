@@ -0,0 +1,142 @@
+package consensus
+
+import (
+	"bytes"
+	"testing"
+
+	"dslab.inf.usi.ch/tendermint/crypto"
+)
+
+func TestChunks(t *testing.T) {
+	value := testRandValue(1000)
+	chunks := EncodeChunks(value, 5)
+	if len(chunks) != 5 || DataChunks(5) != 3 || DataChunks(4) != 3 {
+		t.Fatal("Expected 5 chunks, 3 of which reconstruct the value")
+	}
+	for _, chunk := range chunks {
+		if !chunk.Verify() {
+			t.Errorf("Chunk %v has an invalid proof", chunk.Index)
+		}
+	}
+	decoded, err := DecodeChunks([]*Chunk{chunks[4], chunks[1], chunks[2]})
+	if err != nil || !bytes.Equal(decoded, value) {
+		t.Error("Failed to reconstruct value:", err)
+	}
+	if _, err := DecodeChunks(chunks[:2]); err == nil {
+		t.Error("Expected 2 chunks not to reconstruct the value")
+	}
+	// Chunks of different values are not combined
+	other := EncodeChunks(testRandValue(1000), 5)
+	if _, err := DecodeChunks([]*Chunk{chunks[0], chunks[1], other[2]}); err == nil {
+		t.Error("Expected chunks with different roots not to be decoded together")
+	}
+	tampered := *chunks[3]
+	tampered.Data = other[3].Data
+	if tampered.Verify() {
+		t.Error("Expected tampered chunk to be invalid")
+	}
+}
+
+func TestChunkMessageMarshalling(t *testing.T) {
+	block := NewBlock(testRandValue(1000), NewBlock(testRandValue(10), nil))
+	chunk := EncodeChunks(block.Value, 4)[2]
+	m := NewChunkMessage(3, block.Header(), chunk, 1)
+	if err := testMarshalling(m); err != nil {
+		t.Error(err)
+	}
+	mm := MessageFromBytes(m.Marshall())
+	c := mm.Chunk
+	if c.Index != 2 || c.Total != 4 || c.Size != 1000 || !bytes.Equal(c.Data, chunk.Data) || !c.Verify() {
+		t.Error("Unexpected unmarshalled chunk", c.Index, c.Total, c.Size)
+	}
+	if !mm.Block.BlockID().Equal(block.BlockID()) || mm.Block.HasPayload() {
+		t.Error("Expected chunk message to carry the block header")
+	}
+}
+
+func TestChunkSize(t *testing.T) {
+	chunks := EncodeChunks(testRandValue(1000), 5)
+	tampered := *chunks[2]
+	tampered.Size = 999
+	if tampered.Verify() {
+		t.Error("Expected chunk with tampered size to be invalid")
+	}
+}
+
+func TestChunkReconstruction(t *testing.T) {
+	p := NewTestProcess(1, 4)
+	c := NewFastAlterBFT(MIN_EPOCH, p, true)
+	c.ErasureCoding = true
+	c.Start(nil, false)
+	block := &Block{Value: testRandValue(1000), Epoch: MIN_EPOCH}
+	header := block.Header()
+	forged := (&Block{Value: testRandValue(1000), Epoch: MIN_EPOCH}).Header()
+	chunks := EncodeChunks(block.Value, 4)
+	// The chunk of process 1 is sent by the proposer and re-broadcast
+	c.ProcessMessage(NewChunkMessage(MIN_EPOCH, header, chunks[1], 0))
+	if len(p.state.outgoing) != 1 || p.state.outgoing[0].Message.Type != CHUNK {
+		t.Error("Expected the chunk sent by the proposer to be re-broadcast")
+	}
+	// A chunk relayed with a forged header does not prevent reconstruction
+	c.ProcessMessage(NewChunkMessage(MIN_EPOCH, header, chunks[2], 2))
+	c.ProcessMessage(NewChunkMessage(MIN_EPOCH, forged, chunks[3], 3))
+	if !c.hasPayload(block.BlockID()) {
+		t.Error("Expected the value to be reconstructed from the chunks")
+	}
+	if c.hasPayload(forged.BlockID()) {
+		t.Error("Expected forged header not to be completed")
+	}
+}
+
+func TestChunkReconstructionWithProposal(t *testing.T) {
+	p := NewTestProcess(1, 5)
+	c := NewFastAlterBFT(MIN_EPOCH, p, true)
+	c.ErasureCoding = true
+	c.Start(nil, false)
+	block := &Block{Value: testRandValue(1000), Epoch: MIN_EPOCH}
+	header := block.Header()
+	forged := (&Block{Value: testRandValue(1000), Epoch: MIN_EPOCH}).Header()
+	chunks := EncodeChunks(block.Value, 5)
+	// Relayed headers are not trusted, the value is reconstructed once the
+	// header is proposed
+	for i := 2; i < 5; i++ {
+		c.ProcessMessage(NewChunkMessage(MIN_EPOCH, forged, chunks[i], i))
+	}
+	if c.hasPayload(forged.BlockID()) || len(c.chunks[string(chunks[2].Root)]) != 3 {
+		t.Error("Expected chunks to be retained until the header is received")
+	}
+	c.ProcessMessage(testReceivedProposal(header))
+	if !c.hasPayload(block.BlockID()) {
+		t.Error("Expected the value to be reconstructed once the header is proposed")
+	}
+}
+
+func TestChunkDecidedBlockRequest(t *testing.T) {
+	p := NewTestProcess(1, 4)
+	c := NewFastAlterBFT(MIN_EPOCH, p, true)
+	c.ErasureCoding = true
+	c.Start(nil, false)
+	header := (&Block{Value: testRandValue(1000), Epoch: MIN_EPOCH}).Header()
+	c.ProcessMessage(testReceivedProposal(header))
+	// The value of the decided block is requested from the signers of the
+	// certificate if its chunks are not received
+	cert := NewBlockCertificate(MIN_EPOCH, header.BlockID(), header.Height)
+	for _, id := range []int{0, 1, 2} {
+		cert.AddSignature(testRandValue(SignatureSize), id)
+	}
+	c.lockedCertificate = cert
+	c.decide(false)
+	out := p.state.outgoing[len(p.state.outgoing)-1]
+	if out.Message.Type != BLOCK_REQUEST || !out.Message.BlockID.Equal(header.BlockID()) ||
+		len(out.To) != 2 || out.To[0] != 0 || out.To[1] != 2 {
+		t.Error("Expected the decided block to be requested from the signers, got", out.Message, out.To)
+	}
+}
+
+// testReceivedProposal returns the proposal of a block by process 0, as
+// received from the network.
+func testReceivedProposal(block *Block) *Message {
+	proposal := NewProposeMessage(block.Epoch, block, nil, 0)
+	proposal.Sign(crypto.GeneratePrivateKey())
+	return MessageFromBytes(proposal.Marshall())
+}
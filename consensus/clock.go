@@ -0,0 +1,149 @@
+package consensus
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time and timers, so that the time observed by
+// a TimeoutTicker can be driven by tests and simulations.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTimer returns a stopped timer.
+	NewTimer() Timer
+}
+
+// Timer sends the time on its channel when it expires.
+type Timer interface {
+	// C returns the channel on which the time is sent when the timer expires.
+	C() <-chan time.Time
+
+	// ResetAt sets the timer to expire at the deadline, discarding any
+	// previous expiration not yet received.
+	ResetAt(deadline time.Time)
+
+	// Stop prevents the timer from expiring.
+	Stop()
+}
+
+// SystemClock is the Clock of the system, based on the time package.
+type SystemClock struct{}
+
+// Now returns the current local time.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer returns a stopped timer based on time.Timer.
+func (SystemClock) NewTimer() Timer {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	return &systemTimer{timer}
+}
+
+type systemTimer struct {
+	timer *time.Timer
+}
+
+func (t *systemTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t *systemTimer) ResetAt(deadline time.Time) {
+	t.Stop()
+	t.timer.Reset(time.Until(deadline))
+}
+
+func (t *systemTimer) Stop() {
+	if !t.timer.Stop() {
+		select {
+		case <-t.timer.C:
+		default: // Should not block
+		}
+	}
+}
+
+// ManualClock is a Clock whose time only advances when Advance is invoked.
+// Its timers expire while the clock advances. It can be used by any routine.
+type ManualClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+}
+
+// NewManualClock returns a manual clock set to the provided time.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *ManualClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// NewTimer returns a stopped timer driven by the clock.
+func (c *ManualClock) NewTimer() Timer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	timer := &manualTimer{clock: c, channel: make(chan time.Time, 1)}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+// Advance advances the clock by the provided duration, expiring the timers
+// whose deadline is reached.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	for _, timer := range c.timers {
+		timer.expire(c.now)
+	}
+}
+
+type manualTimer struct {
+	clock    *ManualClock
+	channel  chan time.Time
+	deadline time.Time
+	active   bool
+}
+
+func (t *manualTimer) C() <-chan time.Time {
+	return t.channel
+}
+
+func (t *manualTimer) ResetAt(deadline time.Time) {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	t.drain()
+	t.deadline = deadline
+	t.active = true
+	t.expire(t.clock.now)
+}
+
+func (t *manualTimer) Stop() {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	t.drain()
+	t.active = false
+}
+
+// Sends the time on the channel if the deadline is reached.
+// The clock must be locked.
+func (t *manualTimer) expire(now time.Time) {
+	if t.active && !now.Before(t.deadline) {
+		t.active = false
+		t.channel <- now
+	}
+}
+
+func (t *manualTimer) drain() {
+	select {
+	case <-t.channel:
+	default:
+	}
+}
@@ -0,0 +1,81 @@
+package consensus
+
+import (
+	"encoding/hex"
+	"time"
+)
+
+// Event types.
+const (
+	PROPOSAL_RECEIVED_EVENT   = "proposal-received"
+	VOTED_EVENT               = "voted"
+	CERTIFICATE_EVENT         = "certificate"
+	SILENCE_CERTIFICATE_EVENT = "silence-certificate"
+	EQUIVOCATION_EVENT        = "equivocation"
+	DECIDED_EVENT             = "decided"
+	EPOCH_FINISHED_EVENT      = "epoch-finished"
+	TIMEOUT_EVENT             = "timeout"
+)
+
+// Names of the timeout types, reported by timeout events.
+var timeoutNames = map[int16]string{
+	TimeoutPropose:      "propose",
+	TimeoutEquivocation: "equivocation",
+	TimeoutQuitEpoch:    "quit-epoch",
+	TimeoutEpochChange:  "epoch-change",
+	TimeoutAttack:       "attack",
+	TimeoutPrevote:      "prevote",
+	TimeoutPrecommit:    "precommit",
+	TimeoutBlockRequest: "block-request",
+}
+
+// Event is a phase transition of an epoch of consensus, reported by the
+// consensus instances to their process.
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+
+	// Process reporting the event, and epoch of the event
+	Process int   `json:"process"`
+	Epoch   int64 `json:"epoch"`
+
+	// Block proposed, voted, certified or decided, if any. Epochs are
+	// finished with the block of the locked certificate, if any.
+	Height  int64   `json:"height"`
+	BlockID BlockID `json:"block,omitempty"`
+
+	// Type of the fired timeout
+	Timeout string `json:"timeout,omitempty"`
+
+	// Whether a certificate of the block of the epoch was observed before
+	// the epoch change and the block decided, when the epoch is finished
+	Locked  bool `json:"locked,omitempty"`
+	Decided bool `json:"decided,omitempty"`
+
+	// Whether the block was decided on the fast path, with the votes of all
+	// the processes
+	FastPath bool `json:"fast,omitempty"`
+}
+
+// NewTimeoutEvent creates the event of a fired timeout.
+func NewTimeoutEvent(timeout *Timeout) *Event {
+	return &Event{
+		Type:    TIMEOUT_EVENT,
+		Epoch:   timeout.Epoch,
+		Timeout: timeoutNames[timeout.Type],
+	}
+}
+
+// EventSink receives the events of consensus instances.
+type EventSink interface {
+	// Emit reports an event. The process and time of the event are set by
+	// the process.
+	Emit(event *Event)
+}
+
+// MarshalText encodes a block ID in hexadecimal.
+func (b BlockID) MarshalText() ([]byte, error) {
+	text := make([]byte, hex.EncodedLen(len(b)))
+	hex.Encode(text, b)
+	return text, nil
+}
@@ -0,0 +1,30 @@
+package consensus
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEventMarshalling(t *testing.T) {
+	event := &Event{
+		Type:    DECIDED_EVENT,
+		Epoch:   3,
+		Height:  2,
+		BlockID: BlockID{0xab, 0x01},
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"block":"ab01"`) {
+		t.Errorf("Expected hexadecimal block ID, got %s", data)
+	}
+	if strings.Contains(string(data), "timeout") || strings.Contains(string(data), "locked") {
+		t.Errorf("Expected unset fields to be omitted, got %s", data)
+	}
+	event = NewTimeoutEvent(&Timeout{Type: TimeoutPrecommit, Epoch: 4})
+	if event.Type != TIMEOUT_EVENT || event.Epoch != 4 || event.Timeout != "precommit" {
+		t.Errorf("Unexpected timeout event %+v", event)
+	}
+}
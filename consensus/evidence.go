@@ -0,0 +1,147 @@
+package consensus
+
+import (
+	"fmt"
+
+	"dslab.inf.usi.ch/tendermint/crypto"
+)
+
+// Evidence proves that the proposer of an epoch has equivocated, namely that
+// it has signed two proposals for different blocks in the same epoch.
+//
+// A proposer's signature on a proposal covers the epoch, the height and the
+// ID of the proposed block, the same payload of the VOTE messages, so that it
+// can be extracted from the block certificates of the epoch.
+type Evidence struct {
+	Epoch    int64
+	Proposer int
+
+	// The two conflicting proposals
+	Heights    [2]int64
+	BlockIDs   [2]BlockID
+	Signatures [2]Signature
+
+	// Unexported byte version
+	marshalled []byte
+}
+
+// NewEvidence creates the evidence that a proposer has equivocated from two
+// block certificates of an epoch. It returns nil if the certificates do not
+// carry the signature of the proposer or if they certify the same block.
+func NewEvidence(c1, c2 *Certificate, proposer int) *Evidence {
+	if c1.Type != BLOCK_CERT || c2.Type != BLOCK_CERT ||
+		c1.Epoch != c2.Epoch || c1.BlockID().Equal(c2.BlockID()) {
+		return nil
+	}
+	s1, ok1 := c1.Signatures[proposer]
+	s2, ok2 := c2.Signatures[proposer]
+	if !ok1 || !ok2 {
+		return nil
+	}
+	return &Evidence{
+		Epoch:      c1.Epoch,
+		Proposer:   proposer,
+		Heights:    [2]int64{c1.Height, c2.Height},
+		BlockIDs:   [2]BlockID{c1.BlockID(), c2.BlockID()},
+		Signatures: [2]Signature{s1, s2},
+	}
+}
+
+// Equal checks whether two evidences refer to the same equivocation.
+func (e *Evidence) Equal(ee *Evidence) bool {
+	if e == nil || ee == nil {
+		return e == ee
+	}
+	return e.Epoch == ee.Epoch && e.Proposer == ee.Proposer
+}
+
+// Payload returns the payload signed by the proposer in one of the proposals.
+func (e *Evidence) Payload(i int) []byte {
+	payload := make([]byte, 16+BlockIDSize)
+	encoding.PutUint64(payload[0:], uint64(e.Epoch))
+	encoding.PutUint64(payload[8:], uint64(e.Heights[i]))
+	e.BlockIDs[i].MarshallTo(payload[16:])
+	return payload
+}
+
+// GetCryptoSignatures returns the signatures of the two proposals.
+func (e *Evidence) GetCryptoSignatures() []*crypto.Signature {
+	return []*crypto.Signature{
+		crypto.NewSignature(e.Proposer, e.Payload(0), e.Signatures[0]),
+		crypto.NewSignature(e.Proposer, e.Payload(1), e.Signatures[1]),
+	}
+}
+
+// Verify checks that the evidence proves an equivocation, namely that the
+// proposals are for different blocks and are both signed by the proposer of
+// the epoch. As votes sign the same payload of proposals, the evidence of
+// other processes only proves that they voted for different blocks.
+func (e *Evidence) Verify(keys []crypto.PublicKey, proposer int) bool {
+	if e.BlockIDs[0].Equal(e.BlockIDs[1]) || e.Proposer != proposer ||
+		e.Proposer < 0 || e.Proposer >= len(keys) || keys[e.Proposer] == nil {
+		return false
+	}
+	for _, sig := range e.GetCryptoSignatures() {
+		if !keys[e.Proposer].VerifySignature(sig.Payload, sig.Signature) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns string representation of an evidence.
+func (e *Evidence) String() string {
+	return fmt.Sprintf("Epoch: %v\nProposer: %v\nBlockIDs:%v %v\n", e.Epoch, e.Proposer, e.BlockIDs[0], e.BlockIDs[1])
+}
+
+// ByteSize returns the size of the bytes encoded version of the evidence.
+func (e *Evidence) ByteSize() int {
+	return 10 + 2*(8+BlockIDSize+SignatureSize)
+}
+
+// Marshall serialises the evidence to an array of bytes.
+func (e *Evidence) Marshall() []byte {
+	if e.marshalled == nil {
+		e.marshalled = make([]byte, e.ByteSize())
+		e.MarshallTo(e.marshalled)
+	}
+	return e.marshalled
+}
+
+// MarshallTo encodes the evidence into bytes and writes them to a buffer.
+// The buffer is assumed to have enough space to store the encoded evidence.
+func (e *Evidence) MarshallTo(buffer []byte) int {
+	if e.marshalled != nil {
+		return copy(buffer, e.marshalled)
+	}
+	encoding.PutUint64(buffer[0:], uint64(e.Epoch))
+	encoding.PutUint16(buffer[8:], uint16(e.Proposer))
+	index := 10
+	for i := 0; i < 2; i++ {
+		encoding.PutUint64(buffer[index:], uint64(e.Heights[i]))
+		index += 8
+		index += e.BlockIDs[i].MarshallTo(buffer[index:])
+		index += e.Signatures[i].MarshallTo(buffer[index:])
+	}
+	return index
+}
+
+// EvidenceFromBytes parses an evidence from a byte array.
+// The provided byte array is retained and should not be externally re-used.
+func EvidenceFromBytes(buffer []byte) *Evidence {
+	e := &Evidence{
+		Epoch:    int64(encoding.Uint64(buffer[0:])),
+		Proposer: int(encoding.Uint16(buffer[8:])),
+	}
+	index := 10
+	for i := 0; i < 2; i++ {
+		e.Heights[i] = int64(encoding.Uint64(buffer[index:]))
+		index += 8
+		e.BlockIDs[i] = BlockIDFromBytes(buffer[index:])
+		index += BlockIDSize
+		e.Signatures[i] = SignatureFromBytes(buffer[index:])
+		index += SignatureSize
+	}
+	e.marshalled = buffer[:index]
+	return e
+}
@@ -0,0 +1,75 @@
+package consensus
+
+import (
+	"testing"
+
+	"dslab.inf.usi.ch/tendermint/crypto"
+)
+
+// testSignedCertificate returns a block certificate signed by the provided keys.
+func testSignedCertificate(epoch int64, block *Block, keys []crypto.PrivateKey) *Certificate {
+	c := NewBlockCertificate(epoch, block.BlockID(), block.Height)
+	for i, key := range keys {
+		vote := NewVoteMessage(epoch, block.BlockID(), block.Height, int16(i), int16(i))
+		vote.Sign(key)
+		c.AddSignature(vote.Signature, i)
+	}
+	return c
+}
+
+func TestEvidence(t *testing.T) {
+	keys := testGetKeys(3)
+	var publicKeys []crypto.PublicKey
+	for _, key := range keys {
+		publicKeys = append(publicKeys, key.PubKey())
+	}
+	e := int64(5)
+	b0 := NewBlock(testRandValue(32), nil)
+	b1 := NewBlock(testRandValue(32), nil)
+	c0 := testSignedCertificate(e, b0, keys)
+	c1 := testSignedCertificate(e, b1, keys)
+
+	if NewEvidence(c0, c0, 1) != nil {
+		t.Error("Expected no evidence from certificates for the same block")
+	}
+	if NewEvidence(c0, c1, 3) != nil {
+		t.Error("Expected no evidence without the proposer signatures")
+	}
+	evidence := NewEvidence(c0, c1, 1)
+	if evidence == nil || evidence.Epoch != e || evidence.Proposer != 1 {
+		t.Fatal("Unexpected evidence", evidence)
+	}
+	if !evidence.Verify(publicKeys, 1) {
+		t.Error("Expected evidence to be valid")
+	}
+
+	m := NewEvidenceMessage(evidence)
+	m.Sign(keys[0])
+	if err := testMarshalling(m); err != nil {
+		t.Error(err)
+	}
+	mm := MessageFromBytes(m.Marshall())
+	if !mm.Evidence.Equal(evidence) || !mm.Evidence.Verify(publicKeys, 1) {
+		t.Error("Expected unmarshalled evidence to be valid", mm.Evidence)
+	}
+	if len(mm.GetCryptoSignatures()) != 2 {
+		t.Error("Expected the two proposer signatures, got", len(mm.GetCryptoSignatures()))
+	}
+
+	// Evidence with a signature of another process
+	forged := NewEvidence(c0, c1, 1)
+	forged.Signatures[1] = c1.Signatures[2]
+	if forged.Verify(publicKeys, 1) {
+		t.Error("Expected evidence with forged signature to be invalid")
+	}
+	forged = NewEvidence(c0, c1, 1)
+	forged.BlockIDs[1] = forged.BlockIDs[0]
+	if forged.Verify(publicKeys, 1) {
+		t.Error("Expected evidence for the same block to be invalid")
+	}
+	// Votes of another process for different blocks
+	votes := NewEvidence(c0, c1, 2)
+	if votes == nil || votes.Verify(publicKeys, 1) {
+		t.Error("Expected evidence signed by a process other than the proposer to be invalid")
+	}
+}
@@ -24,6 +24,22 @@ type FastAlterBFT struct {
 
 	fastAlterEnabled bool
 
+	// If set to true, the proposer broadcasts the header of its block and
+	// then disseminates the value in a PAYLOAD message. Processes vote for
+	// valid headers, but commit a block only once they have its value and
+	// the values of its uncommitted ancestors.
+	SeparateDissemination bool
+
+	// If set to true, the proposer broadcasts the header of its block and
+	// sends each member of the validator set a distinct erasure-coded chunk
+	// of the value, which the member re-broadcasts. Processes reconstruct
+	// the value from the chunks of a majority of the members.
+	ErasureCoding bool
+
+	// If set to true, the certificates sent by the process have the
+	// aggregate encoding, with a single BLS signature of their signers.
+	AggregateCertificates bool
+
 	lockedCertificate     *Certificate
 	sentLockedCertificate bool
 
@@ -42,6 +58,28 @@ type FastAlterBFT struct {
 
 	hasVoted bool
 	decision BlockID
+
+	// Certificate of the decision, whose signers are asked for the decided
+	// block if the proposal was not received.
+	decisionCertificate *Certificate
+	decisionBlock       *Block
+	// Whether the decision was taken with the votes of all processes
+	decisionFastPath bool
+	// Blocks requested to commit the decision
+	requests *blockRequests
+
+	// Evidence that the proposer has equivocated, reported once
+	evidence *Evidence
+
+	// Payloads received before the header of their block, by block ID
+	payloads map[string]*Block
+
+	// Chunks received by Merkle root, and whether the chunk of this process
+	// has been re-broadcast
+	chunks      map[string][]*Chunk
+	echoedChunk bool
+	// Headers of the chunks sent by the proposer, by Merkle root
+	chunkHeaders map[string]*Block
 }
 
 // NewConsensus creates a consensus instance for the provided epoch.
@@ -67,6 +105,10 @@ func (c *FastAlterBFT) Init() {
 	c.scheduledTimeouts = make([]bool, TimeoutEpochChange+1)
 	c.hasVoted = false
 	c.sentLockedCertificate = false
+	c.requests = newBlockRequests()
+	c.payloads = make(map[string]*Block)
+	c.chunks = make(map[string][]*Chunk)
+	c.chunkHeaders = make(map[string]*Block)
 }
 
 // Start this epoch of consensus
@@ -75,7 +117,7 @@ func (c *FastAlterBFT) Start(lockedCertificate *Certificate, sentLockedCertifica
 	c.sentLockedCertificate = sentLockedCertificate
 	c.epochPhase = Ready
 	if c.Process.Proposer(c.Epoch) == c.Process.ID() {
-		if c.Epoch == MIN_EPOCH || c.lockedCertificate.Epoch == c.Epoch-1 {
+		if c.Epoch == MIN_EPOCH || (c.lockedCertificate != nil && c.lockedCertificate.Epoch == c.Epoch-1) {
 			c.broadcastProposal()
 		} else {
 			c.scheduleTimeout(TimeoutEpochChange)
@@ -106,6 +148,15 @@ func (c *FastAlterBFT) GetEpoch() int64 {
 	return c.Epoch
 }
 
+// Status returns the phase of this epoch and its locked certificate.
+func (c *FastAlterBFT) Status() *EpochStatus {
+	return &EpochStatus{
+		Epoch:  c.Epoch,
+		Phase:  phaseNames[c.epochPhase],
+		Locked: NewCertificateStatus(c.lockedCertificate),
+	}
+}
+
 // ProcessMessage processes a consensus message.
 //
 // Contract: message belongs to this epoch of consensus.
@@ -115,6 +166,15 @@ func (c *FastAlterBFT) ProcessMessage(message *Message) {
 		c.messages = append(c.messages, message)
 		return
 	}
+	// Payloads are still needed to commit the blocks of finished epochs
+	switch message.Type {
+	case PAYLOAD:
+		c.processPayload(message)
+		return
+	case CHUNK:
+		c.processChunk(message)
+		return
+	}
 	if c.epochPhase == Finished {
 		return
 	}
@@ -129,12 +189,17 @@ func (c *FastAlterBFT) ProcessMessage(message *Message) {
 		c.processQuitEpoch(message)
 	case CERTIFICATE:
 		c.processCertificate(message)
+	case BLOCK_RESPONSE:
+		c.processBlockResponse(message)
 	}
 }
 
 func (c *FastAlterBFT) processProposal(proposal *Message) {
 	// Check if proposal has already been processed!
 	if c.Proposals.Has(proposal.Block.BlockID()) {
+		if proposal.Block.HasPayload() && c.attachPayload(proposal.Block) {
+			c.tryToCommit()
+		}
 		return
 	}
 	if c.checkProposalValidity(proposal) == false {
@@ -147,9 +212,20 @@ func (c *FastAlterBFT) processProposal(proposal *Message) {
 		fmt.Printf("P%v proposal could not be added to the blockchain in epoch %v\n", c.Process.ID(), c.Epoch)
 		return
 	}
+	if payload, ok := c.payloads[string(proposal.Block.BlockID())]; ok {
+		delete(c.payloads, string(proposal.Block.BlockID()))
+		proposal.Block.SetPayload(payload.Value)
+	}
 	// Save the proposal
 	if proposal.Epoch == c.Epoch {
 		c.Proposals.Add(proposal)
+		c.Process.Emit(&Event{
+			Type:    PROPOSAL_RECEIVED_EVENT,
+			Epoch:   c.Epoch,
+			Height:  proposal.Block.Height,
+			BlockID: proposal.Block.BlockID(),
+		})
+		c.reconstructValues()
 		c.tryToVote()
 	}
 	// maybe this block vas missing
@@ -160,9 +236,12 @@ func (c *FastAlterBFT) processProposal(proposal *Message) {
 func (c *FastAlterBFT) checkProposalValidity(proposal *Message) bool {
 	// Check if the new proposal is valid!
 	isFromProposer := proposal.Sender == c.Process.Proposer(proposal.Epoch)
+	// The leader schedule relies on the epochs of committed blocks
+	isFromEpoch := proposal.Block.Epoch == proposal.Epoch
 	correspondToCertificate := (proposal.Certificate == nil && proposal.Block.Height == MIN_HEIGHT) ||
 		(proposal.Certificate != nil && proposal.Block.PrevBlockID.Equal(proposal.Certificate.BlockID()))
-	isValidProposal := isFromProposer && correspondToCertificate
+	isValidProposal := isFromProposer && isFromEpoch && correspondToCertificate &&
+		validValue(c.Process, proposal)
 	return isValidProposal
 }
 
@@ -176,7 +255,12 @@ func (c *FastAlterBFT) tryToVote() {
 	shouldVote := proposal.Block.BlockID().Equal(c.lockedCertificate.BlockID()) || proposal.Certificate.RanksHigherOrEqual(c.lockedCertificate)
 
 	if shouldVote {
-		fmt.Printf("Honest process %v voted for %v in epoch %v.\n", c.Process.ID(), proposal.Block.BlockID()[0:4], c.Epoch)
+		// The lock is updated before voting, so that it is recorded first
+		if proposal.Certificate.RanksHigherOrEqual(c.lockedCertificate) {
+			c.sentLockedCertificate = false
+			c.lockedCertificate = proposal.Certificate
+			c.Process.Lock(c.Epoch, c.lockedCertificate)
+		}
 		proposal.setFwdSender(c.Process.ID())
 		c.Process.Forward(proposal)
 		proposerVote := NewVoteMessage(proposal.Epoch, proposal.Block.BlockID(), proposal.Block.Height, int16(proposal.Sender), int16(proposal.Sender))
@@ -187,10 +271,12 @@ func (c *FastAlterBFT) tryToVote() {
 		vote.Signature2 = proposal.Signature
 		c.Process.Broadcast(vote)
 		c.hasVoted = true
-		if proposal.Certificate.RanksHigherOrEqual(c.lockedCertificate) {
-			c.sentLockedCertificate = false
-			c.lockedCertificate = proposal.Certificate
-		}
+		c.Process.Emit(&Event{
+			Type:    VOTED_EVENT,
+			Epoch:   c.Epoch,
+			Height:  vote.Height,
+			BlockID: vote.BlockID,
+		})
 	}
 }
 
@@ -202,23 +288,33 @@ func (c *FastAlterBFT) checkEquivocation() {
 	vote1 := c.Votes.certificates[0].ReconstructMessage(proposerID, proposerID)
 	vote2 := c.Votes.certificates[1].ReconstructMessage(proposerID, proposerID)
 
+	if c.evidence == nil {
+		c.evidence = NewEvidence(c.Votes.certificates[0], c.Votes.certificates[1], proposerID)
+		if c.evidence != nil {
+			c.Process.ReportEvidence(c.evidence)
+			c.Process.Emit(&Event{Type: EQUIVOCATION_EVENT, Epoch: c.Epoch})
+		}
+	}
+
 	if c.epochPhase == Ready {
 		c.epochPhase = EpochChange
-		fmt.Printf("Process %v epoch %v nolock+nodec+equiv value %v\n", c.Process.ID(), c.Epoch, c.lockedCertificate.BlockID()[0:4])
 		if c.fastAlterEnabled {
 			c.scheduleTimeout(TimeoutQuitEpoch)
 		} else {
-			fmt.Printf("Process %v epoch %v nolock+nodecision\n", c.Process.ID(), c.Epoch)
+			c.emitEpochFinished(false, false)
 			c.Process.Finish(c.Epoch, c.lockedCertificate, c.sentLockedCertificate)
 		}
 	}
 	if c.epochPhase == Locked { // process received Ce(Bk) before this one
-		fmt.Printf("Process %v epoch %v lock+nodec+equiv value %v\n", c.Process.ID(), c.Epoch, c.lockedCertificate.BlockID()[0:4])
 		c.epochPhase = Finished
+		c.emitEpochFinished(true, false)
 	}
 
-	c.Process.Forward(vote1)
-	c.Process.Forward(vote2)
+	// Votes whose signatures are aggregated cannot be forwarded
+	if vote1 != nil && vote2 != nil {
+		c.Process.Forward(vote1)
+		c.Process.Forward(vote2)
+	}
 
 }
 
@@ -237,16 +333,13 @@ func (c *FastAlterBFT) processVote(vote *Message) {
 
 		c.checkEquivocation()
 
-		if blockCert.SignatureCount() > c.Process.NumProcesses()/2 {
+		if c.Process.Validators(c.Epoch).IsQuorum(blockCert) {
 			c.processBlockCertificate(blockCert)
 		}
 
 		// Fast path commit
-		if c.fastAlterEnabled && blockCert.SignatureCount() == c.Process.NumProcesses() && c.epochPhase == Locked {
-			// decision
-			c.decision = c.lockedCertificate.BlockID()
-			c.epochPhase = Commit
-			c.tryToCommit()
+		if c.fastAlterEnabled && c.Process.Validators(c.Epoch).IsUnanimous(blockCert) && c.epochPhase == Locked {
+			c.decide(true)
 		}
 	}
 }
@@ -259,15 +352,22 @@ func (c *FastAlterBFT) processBlockCertificate(cert *Certificate) {
 	if cert.RanksHigherOrEqual(c.lockedCertificate) {
 		c.lockedCertificate = cert
 		c.sentLockedCertificate = false
+		c.Process.Lock(c.Epoch, c.lockedCertificate)
 	}
 	if cert.Epoch == c.Epoch {
+		c.Process.Emit(&Event{
+			Type:    CERTIFICATE_EVENT,
+			Epoch:   c.Epoch,
+			Height:  cert.Height,
+			BlockID: cert.BlockID(),
+		})
 		if c.epochPhase == Ready {
 			c.epochPhase = Locked
 			c.scheduleTimeout(TimeoutEquivocation)
 		}
 		if c.epochPhase == EpochChange {
 			c.epochPhase = Finished
-			fmt.Printf("Process %v epoch %v nolock+block value %v\n", c.Process.ID(), c.Epoch, c.lockedCertificate.BlockID()[0:4])
+			c.emitEpochFinished(false, false)
 		}
 		// Whenever we receive Ce(Bk) in epoch e we can finish epoch e and start epoch e+1
 		c.broadcastQuitEpoch(cert)
@@ -285,13 +385,16 @@ func (c *FastAlterBFT) processSilence(silence *Message) {
 		if !ok {
 			return
 		}
-		if c.SilenceCertificate.SignatureCount() > c.Process.NumProcesses()/2 {
+		if c.Process.Validators(c.Epoch).IsQuorum(c.SilenceCertificate) {
 			c.processSilenceCertificate(c.SilenceCertificate)
 		}
 	}
 }
 
 func (c *FastAlterBFT) processSilenceCertificate(cert *Certificate) {
+	if c.epochPhase == Ready || c.epochPhase == Locked {
+		c.Process.Emit(&Event{Type: SILENCE_CERTIFICATE_EVENT, Epoch: c.Epoch})
+	}
 	if c.epochPhase == Ready { // this is the first certificate process has received
 		c.epochPhase = EpochChange
 		//fmt.Printf("Process %v in epoch %v didn't lock!\n", c.Process.ID(), c.Epoch)
@@ -299,7 +402,7 @@ func (c *FastAlterBFT) processSilenceCertificate(cert *Certificate) {
 		if c.fastAlterEnabled {
 			c.scheduleTimeout(TimeoutQuitEpoch)
 		} else {
-			fmt.Printf("Process %v epoch %v nolock+nodecision\n", c.Process.ID(), c.Epoch)
+			c.emitEpochFinished(false, false)
 			c.Process.Finish(c.Epoch, c.lockedCertificate, c.sentLockedCertificate)
 		}
 		//c.Process.Decide(c.Epoch, nil)
@@ -307,7 +410,7 @@ func (c *FastAlterBFT) processSilenceCertificate(cert *Certificate) {
 	}
 	if c.epochPhase == Locked {
 		c.epochPhase = Finished
-		fmt.Printf("Process %v epoch %v lock+nodec+silence value %v\n", c.Process.ID(), c.Epoch, c.lockedCertificate.BlockID()[0:4])
+		c.emitEpochFinished(true, false)
 		//c.Process.Decide(c.Epoch, nil)
 		return
 	}
@@ -315,22 +418,51 @@ func (c *FastAlterBFT) processSilenceCertificate(cert *Certificate) {
 
 func (c *FastAlterBFT) processQuitEpoch(quitEpoch *Message) {
 	cert := quitEpoch.Certificate
+	if cert.Aggregated() {
+		c.processAggregatedCertificate(cert)
+	}
 	messages := cert.ReconstructMessages(c.Process.Proposer(c.Epoch))
 	for _, m := range messages {
 		c.ProcessMessage(m)
 	}
 }
 
-func (c *FastAlterBFT) processCertificate(certificate *Message) {
-	if c.Process.ID() != c.Process.Proposer(c.Epoch) {
-		panic(fmt.Errorf("Non proposer %v process received certificate message in epoch %v!", c.Process.ID(), c.Epoch))
+// processAggregatedCertificate processes a certificate with aggregated
+// signatures, from which the certified messages cannot be reconstructed.
+func (c *FastAlterBFT) processAggregatedCertificate(cert *Certificate) {
+	if cert.Epoch != c.Epoch || !c.Process.Validators(c.Epoch).IsQuorum(cert) {
+		return
+	}
+	switch cert.Type {
+	case SILENCE_CERT:
+		if c.epochPhase == Ready || c.epochPhase == Locked {
+			c.processSilenceCertificate(cert)
+		}
+	case BLOCK_CERT:
+		if c.epochPhase == Commit {
+			return
+		}
+		if c.Votes.Get(c.Epoch, cert.BlockID(), cert.Height) == nil {
+			c.Votes.Add(cert)
+			c.checkEquivocation()
+		}
+		c.processBlockCertificate(cert)
+		// Fast path commit
+		if c.fastAlterEnabled && c.Process.Validators(c.Epoch).IsUnanimous(cert) && c.epochPhase == Locked {
+			c.decide(true)
+		}
 	}
-	if certificate.Type == SILENCE_CERT {
-		panic(fmt.Errorf("Process received invalid certificate!"))
+}
+
+func (c *FastAlterBFT) processCertificate(certificate *Message) {
+	// Certificates are sent to the proposer, possibly by Byzantine processes
+	if c.Process.ID() != c.Process.Proposer(c.Epoch) || certificate.Certificate.Type != BLOCK_CERT {
+		return
 	}
 	cert := certificate.Certificate
 	if cert.RanksHigherOrEqual(c.lockedCertificate) {
 		c.lockedCertificate = cert
+		c.Process.Lock(c.Epoch, c.lockedCertificate)
 		// check if this is the certificate the proposer was waiting for
 		if c.scheduledTimeouts[TimeoutEpochChange] && c.lockedCertificate.Epoch == c.Epoch-1 {
 			c.scheduledTimeouts[TimeoutEpochChange] = false
@@ -355,6 +487,8 @@ func (c *FastAlterBFT) ProcessTimeout(timeout *Timeout) {
 		c.processTimeoutQuitEpoch()
 	case TimeoutEpochChange:
 		c.processTimeoutEpochChange()
+	case TimeoutBlockRequest:
+		c.requests.retry(c.Process, c.Epoch)
 	}
 }
 
@@ -369,37 +503,235 @@ func (c *FastAlterBFT) processTimeoutEquivocation() {
 
 	c.scheduledTimeouts[TimeoutEquivocation] = false
 	if c.epochPhase == Locked {
-		// decision
-		fmt.Println("no-fast-path-decision")
-		c.decision = c.lockedCertificate.BlockID()
-		c.epochPhase = Commit
-		c.tryToCommit()
+		c.decide(false)
 	}
 }
 
+// decide on the block of the locked certificate and try to commit it, on the
+// fast path with the votes of all processes or after the equivocation timeout.
+func (c *FastAlterBFT) decide(fastPath bool) {
+	c.decision = c.lockedCertificate.BlockID()
+	c.decisionFastPath = fastPath
+	c.decisionCertificate = c.lockedCertificate
+	c.epochPhase = Commit
+	c.tryToCommit()
+}
+
 func (c *FastAlterBFT) tryToCommit() {
 	if c.decision == nil || c.epochPhase != Commit {
 		return
 	}
 
-	proposal := c.Proposals.Get(c.decision)
-	if proposal == nil {
+	block := c.decidedBlock()
+	if payload, ok := c.payloads[string(c.decision)]; ok && block == nil {
+		delete(c.payloads, string(c.decision))
+		if c.Process.AddBlock(payload) {
+			c.decisionBlock = payload
+			block = payload
+		}
+	}
+	// The proposal was not received, the processes that voted for it have it
+	if block == nil {
+		c.requestBlock(c.decisionCertificate.Height, c.decision, c.decisionCertificate.Signers()...)
 		return
 	}
 
-	if c.Process.ExtendValidChain(proposal.Block) {
-		fmt.Printf("Process %v epoch %v lock+decision value %v\n", c.Process.ID(), c.Epoch, c.decision[0:4])
+	if c.Process.ExtendValidChain(block) {
+		// The value of the decided block is disseminated by the proposer, and
+		// requested from the processes that voted for it in case the
+		// dissemination fails. The values of ancestors are requested from
+		// the other processes.
+		if missing := block.missingPayload(); missing == block {
+			c.requestBlock(block.Height, block.BlockID(), c.decisionCertificate.Signers()...)
+			return
+		} else if missing != nil {
+			c.requestBlock(missing.Height, missing.BlockID())
+			return
+		}
 		c.epochPhase = Finished
-		c.Process.Decide(c.Epoch, proposal.Block)
+		c.Process.Emit(&Event{
+			Type:    DECIDED_EVENT,
+			Epoch:   c.Epoch,
+			Height:  block.Height,
+			BlockID: block.BlockID(),
+
+			FastPath: c.decisionFastPath,
+		})
+		c.emitEpochFinished(true, true)
+		c.Process.Decide(c.Epoch, block)
+	} else if height, blockID := c.Process.MissingAncestor(block); blockID != nil {
+		c.requestBlock(height, blockID)
 	}
 
 }
 
+// requestBlock requests a block missing to commit the decision from the
+// provided processes, or from every other member if none is provided.
+// A block is requested again from every other member if not received.
+func (c *FastAlterBFT) requestBlock(height int64, blockID BlockID, ids ...int) {
+	c.requests.send(c.Process, c.Epoch, height, blockID, ids...)
+}
+
+// processBlockResponse processes a block requested to commit the decision.
+func (c *FastAlterBFT) processBlockResponse(response *Message) {
+	block := response.Block
+	if !c.requests.received(block.BlockID()) {
+		return
+	}
+	if !c.Process.AddBlock(block) {
+		// The block header is already in the blockchain
+		c.attachPayload(block)
+	} else if block.BlockID().Equal(c.decision) {
+		c.decisionBlock = block
+	}
+	c.tryToCommit()
+}
+
+// processPayload processes the value of a block proposed in this epoch.
+func (c *FastAlterBFT) processPayload(payload *Message) {
+	block := payload.Block
+	if block.Epoch != c.Epoch || payload.Sender != c.Process.Proposer(c.Epoch) {
+		return
+	}
+	c.addPayload(block)
+}
+
+// processChunk processes a chunk of the value of a block proposed in this
+// epoch. The chunk sent by the proposer to this process is re-broadcast.
+//
+// The headers relayed with the chunks of other processes are not trusted, so
+// that a byzantine process cannot prevent a value from being reconstructed:
+// values are matched against the headers of the chunks sent by the proposer
+// and of the proposals.
+func (c *FastAlterBFT) processChunk(message *Message) {
+	header, chunk := message.Block, message.Chunk
+	validators := c.Process.Validators(c.Epoch)
+	proposer := c.Process.Proposer(c.Epoch)
+	if header.Epoch != c.Epoch || chunk.Total != validators.Size() ||
+		(message.Sender != proposer && message.Sender != validators.Member(chunk.Index)) ||
+		!chunk.Verify() {
+		return
+	}
+	root := string(chunk.Root)
+	if message.Sender == proposer {
+		if validators.Member(chunk.Index) == c.Process.ID() && !c.echoedChunk {
+			c.echoedChunk = true
+			c.Process.Broadcast(NewChunkMessage(c.Epoch, header, chunk, c.Process.ID()))
+		}
+		if _, ok := c.chunkHeaders[root]; !ok {
+			c.chunkHeaders[root] = header
+		}
+	}
+	if header, ok := c.chunkHeaders[root]; ok && (header.HasPayload() || c.hasPayload(header.BlockID())) {
+		return
+	}
+	chunks := c.chunks[root]
+	for _, cc := range chunks {
+		// Chunks committed by the same root have the same value size
+		if cc.Index == chunk.Index || cc.Size != chunk.Size {
+			return
+		}
+	}
+	c.chunks[root] = append(chunks, chunk)
+	c.reconstructValue(root)
+}
+
+// reconstructValues tries to reconstruct the values of every Merkle root,
+// e.g., once the header of a proposal is received.
+func (c *FastAlterBFT) reconstructValues() {
+	for root := range c.chunks {
+		c.reconstructValue(root)
+	}
+}
+
+// reconstructValue decodes the value committed by a Merkle root, if enough
+// chunks are received, and completes with it the header of the chunks sent
+// by the proposer or the header of a proposal. The chunks are retained until
+// the value completes a header.
+func (c *FastAlterBFT) reconstructValue(root string) {
+	chunks := c.chunks[root]
+	if len(chunks) == 0 || len(chunks) < DataChunks(chunks[0].Total) {
+		return
+	}
+	var headers []*Block
+	if header, ok := c.chunkHeaders[root]; ok && !header.HasPayload() {
+		headers = append(headers, header)
+	}
+	for _, proposal := range c.Proposals.proposals {
+		if !proposal.Block.HasPayload() {
+			headers = append(headers, proposal.Block)
+		}
+	}
+	if len(headers) == 0 {
+		// The value is reconstructed once a header is received
+		return
+	}
+	value, err := DecodeChunks(chunks)
+	if err == nil {
+		for _, header := range headers {
+			if header.SetPayload(value) {
+				delete(c.chunks, root)
+				c.chunkHeaders[root] = header
+				c.addPayload(header)
+				return
+			}
+		}
+	}
+	fmt.Printf("P%v could not reconstruct value in epoch %v\n", c.Process.ID(), c.Epoch)
+}
+
+// addPayload completes the header of a block proposed in this epoch with its
+// value, or stores the value until the header is received.
+func (c *FastAlterBFT) addPayload(block *Block) {
+	if !c.attachPayload(block) && !c.Proposals.Has(block.BlockID()) {
+		// The header has not been received yet
+		c.payloads[string(block.BlockID())] = block
+	}
+	c.tryToCommit()
+}
+
+// hasPayload returns whether the value of a block proposed in this epoch is
+// known.
+func (c *FastAlterBFT) hasPayload(blockID BlockID) bool {
+	if _, ok := c.payloads[string(blockID)]; ok {
+		return true
+	}
+	proposal := c.Proposals.Get(blockID)
+	return proposal != nil && proposal.Block.HasPayload()
+}
+
+// attachPayload sets the value of a known block header, either proposed in
+// this epoch or an ancestor of the decided block. Returns false if no block
+// header was completed.
+func (c *FastAlterBFT) attachPayload(block *Block) bool {
+	var header *Block
+	if proposal := c.Proposals.Get(block.BlockID()); proposal != nil {
+		header = proposal.Block
+	} else if decided := c.decidedBlock(); decided != nil {
+		header = decided.ancestor(block.BlockID())
+	}
+	if header == nil || header.HasPayload() {
+		return false
+	}
+	return header.SetPayload(block.Value)
+}
+
+// decidedBlock returns the decided block, nil if unknown.
+func (c *FastAlterBFT) decidedBlock() *Block {
+	if c.decision == nil {
+		return nil
+	}
+	if proposal := c.Proposals.Get(c.decision); proposal != nil {
+		return proposal.Block
+	}
+	return c.decisionBlock
+}
+
 func (c *FastAlterBFT) processTimeoutQuitEpoch() {
 	c.scheduledTimeouts[TimeoutQuitEpoch] = false
 	if c.epochPhase == EpochChange {
 		c.epochPhase = Finished
-		fmt.Printf("Process %v epoch %v nolock+nodecision\n", c.Process.ID(), c.Epoch)
+		c.emitEpochFinished(false, false)
 		c.Process.Finish(c.Epoch, c.lockedCertificate, c.sentLockedCertificate)
 	}
 }
@@ -426,17 +758,50 @@ func (c *FastAlterBFT) broadcastProposal() {
 	block := &Block{
 		Value:       value,
 		Height:      height,
+		Epoch:       c.Epoch,
 		PrevBlockID: prevBlockID,
 	}
 	proposal := &Message{
 		Type:        PROPOSE,
 		Epoch:       c.Epoch,
 		Block:       block,
-		Certificate: c.lockedCertificate,
+		Certificate: c.aggregate(c.lockedCertificate),
 		Sender:      c.Process.ID(),
 		SenderFwd:   c.Process.ID(),
 	}
+	if c.ErasureCoding {
+		c.broadcastChunks(proposal, block)
+		return
+	}
+	if c.SeparateDissemination {
+		proposal.Block = block.Header()
+		c.Process.Broadcast(proposal)
+		c.Process.Broadcast(NewPayloadMessage(c.Epoch, block, c.Process.ID()))
+		return
+	}
+	c.Process.Broadcast(proposal)
+}
+
+// broadcastChunks broadcasts the header of the proposed block and sends each
+// member of the validator set its chunk of the value.
+func (c *FastAlterBFT) broadcastChunks(proposal *Message, block *Block) {
+	header := block.Header()
+	// Computed before the header is shared by messages marshalled concurrently
+	header.BlockID()
+	proposal.Block = header
 	c.Process.Broadcast(proposal)
+	validators := c.Process.Validators(c.Epoch)
+	for i, chunk := range EncodeChunks(block.Value, validators.Size()) {
+		message := NewChunkMessage(c.Epoch, header, chunk, c.Process.ID())
+		if id := validators.Member(i); id == c.Process.ID() {
+			c.Process.Broadcast(message)
+		} else {
+			c.Process.Send(message, id)
+		}
+	}
+	c.echoedChunk = true
+	// The proposer does not need to reconstruct its own value
+	c.addPayload(block)
 }
 
 func (c *FastAlterBFT) broadcastVote(voteType int16, block *Block) {
@@ -466,7 +831,7 @@ func (c *FastAlterBFT) broadcastQuitEpoch(certificate *Certificate) {
 	quitEpoch := &Message{
 		Type:        QUIT_EPOCH,
 		Epoch:       certificate.Epoch,
-		Certificate: certificate,
+		Certificate: c.aggregate(certificate),
 		Sender:      c.Process.ID(),
 	}
 	c.Process.Broadcast(quitEpoch)
@@ -476,12 +841,36 @@ func (c *FastAlterBFT) sendCertificateToLeader() {
 	certMsg := &Message{
 		Type:        CERTIFICATE,
 		Epoch:       c.Epoch,
-		Certificate: c.lockedCertificate,
+		Certificate: c.aggregate(c.lockedCertificate),
 		Sender:      c.Process.ID(),
 	}
 	c.Process.Send(certMsg, c.Process.Proposer(c.Epoch))
 }
 
+// Reports that the epoch is finished, with the block of the locked
+// certificate.
+func (c *FastAlterBFT) emitEpochFinished(locked bool, decided bool) {
+	event := &Event{
+		Type:    EPOCH_FINISHED_EVENT,
+		Epoch:   c.Epoch,
+		Locked:  locked,
+		Decided: decided,
+	}
+	if c.lockedCertificate != nil {
+		event.Height = c.lockedCertificate.Height
+		event.BlockID = c.lockedCertificate.BlockID()
+	}
+	c.Process.Emit(event)
+}
+
+// Selects the aggregate encoding of a certificate sent by the process.
+func (c *FastAlterBFT) aggregate(cert *Certificate) *Certificate {
+	if c.AggregateCertificates && cert != nil {
+		cert.Aggregate()
+	}
+	return cert
+}
+
 // Schedule a timeout for the epoch phase, if not already scheduled.
 func (c *FastAlterBFT) scheduleTimeout(timeoutType int16) {
 	if !c.scheduledTimeouts[timeoutType] {
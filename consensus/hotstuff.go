@@ -0,0 +1,468 @@
+package consensus
+
+import "fmt"
+
+// HotStuffState is the state of chained HotStuff that a process carries
+// across epochs, shared by its HotStuff instances.
+//
+// Every proposal carries the certificate of the block it extends. Processes
+// keep the highest certificate they know, extended by the proposals of
+// correct leaders, and lock on the second block of the highest two-chain of
+// certified blocks. A block is committed when it heads a three-chain of
+// certified blocks proposed in consecutive epochs.
+type HotStuffState struct {
+	// Highest certificate known
+	High *Certificate
+
+	// Certificate of the locked block
+	Locked *Certificate
+
+	// Last epoch in which the process voted
+	lastVoted int64
+
+	// Proposed blocks and the certificates they carry, by block ID
+	blocks  map[string]*Block
+	justify map[string]*Certificate
+
+	// Last block committed, and the block to commit whose ancestors are
+	// being requested
+	committed *Block
+	pending   *Block
+}
+
+// NewHotStuffState creates the state of a process that has not started any
+// epoch of HotStuff.
+func NewHotStuffState() *HotStuffState {
+	return &HotStuffState{
+		lastVoted: MIN_EPOCH - 1,
+		blocks:    make(map[string]*Block),
+		justify:   make(map[string]*Certificate),
+	}
+}
+
+// updateHigh records a certificate, if higher than the highest known.
+func (s *HotStuffState) updateHigh(cert *Certificate) {
+	if cert != nil && !s.High.RanksHigherOrEqual(cert) {
+		s.High = cert
+	}
+}
+
+// addProposal records a proposed block with the certificate it carries.
+// Returns whether the lock has been updated and the block to commit, if any.
+//
+// Proposals may be received out of order, so that the chains of the blocks
+// extending the proposed one are also checked.
+func (s *HotStuffState) addProposal(block *Block, cert *Certificate) (bool, *Block) {
+	s.blocks[string(block.BlockID())] = block
+	s.justify[string(block.BlockID())] = cert
+	s.updateHigh(cert)
+	locked := false
+	var commit *Block
+	for _, justify := range s.justify {
+		cert1, cert0 := s.chain(justify)
+		if cert1 != nil && !s.Locked.RanksHigherOrEqual(cert1) {
+			s.Locked = cert1
+			locked = true
+		}
+		if cert0 == nil {
+			continue
+		}
+		b := s.blocks[string(cert0.BlockID())]
+		if b != nil && (commit == nil || b.Height > commit.Height) {
+			commit = b
+		}
+	}
+	if commit == nil || (s.committed != nil && commit.Height <= s.committed.Height) {
+		return locked, nil
+	}
+	return locked, commit
+}
+
+// chain returns the certificates of the two-chain extended by the block
+// certified by a certificate, nil if unknown, and the certificate of the
+// head of the three-chain, nil if unknown or not in consecutive epochs.
+func (s *HotStuffState) chain(cert *Certificate) (*Certificate, *Certificate) {
+	if cert == nil {
+		return nil, nil
+	}
+	cert1 := s.justify[string(cert.BlockID())]
+	if cert1 == nil {
+		return nil, nil
+	}
+	cert0 := s.justify[string(cert1.BlockID())]
+	if cert0 == nil || cert.Epoch != cert1.Epoch+1 || cert1.Epoch != cert0.Epoch+1 {
+		return cert1, nil
+	}
+	return cert1, cert0
+}
+
+// prune forgets the blocks below a committed block.
+func (s *HotStuffState) prune(committed *Block) {
+	s.committed = committed
+	for id, block := range s.blocks {
+		if block.Height < committed.Height {
+			delete(s.blocks, id)
+			delete(s.justify, id)
+		}
+	}
+}
+
+// HotStuff implements an epoch of chained HotStuff, in which the proposer
+// extends the highest certificate it knows and processes send their votes
+// to the proposer of the next epoch, which certifies the block with votes
+// of more than two thirds of the voting power.
+//
+// A process moves to the next epoch after voting or, if no valid proposal is
+// received in time, sending the highest certificate it knows to the next
+// proposer. The next proposer waits for the certificate of the block of
+// this epoch, or for the certificates of the other processes if it has not
+// voted. Processes call Decide with the epoch of the committed block.
+type HotStuff struct {
+	Epoch   int64   // Consensus epoch identifier
+	Process Process // Auxiliary methods implementation
+
+	state *HotStuffState
+
+	epochPhase int
+
+	// Whether the process has moved to the next epoch, and the proposal of
+	// the epoch, if received
+	finished bool
+	proposal *Message
+	proposed bool
+
+	// Votes received as proposer of the next epoch, and their certificate
+	Votes       *CertificateSet
+	certificate *Certificate
+
+	// Blocks requested to commit
+	requests *blockRequests
+
+	// Pending messages
+	messages []*Message
+}
+
+// NewHotStuff creates an instance of HotStuff for the provided epoch, with
+// the state of the process.
+func NewHotStuff(epoch int64, process Process, state *HotStuffState) *HotStuff {
+	return &HotStuff{
+		Epoch:      epoch,
+		Process:    process,
+		state:      state,
+		epochPhase: Inactive,
+		Votes:      NewCertificateSet(),
+		requests:   newBlockRequests(),
+	}
+}
+
+// Start this epoch of consensus with the highest certificate known by the
+// process. If the certificate has not been sent to the proposer, through
+// votes or the certificate itself, it is sent.
+func (c *HotStuff) Start(highCertificate *Certificate, sentHighCertificate bool) {
+	c.epochPhase = Ready
+	c.state.updateHigh(highCertificate)
+	if c.Process.Proposer(c.Epoch) == c.Process.ID() {
+		if c.Epoch == MIN_EPOCH || (c.state.High != nil && c.state.High.Epoch == c.Epoch-1) {
+			c.broadcastProposal()
+		} else {
+			c.scheduleTimeout(TimeoutEpochChange)
+		}
+	} else if !sentHighCertificate && c.state.High != nil {
+		certMsg := &Message{
+			Type:        CERTIFICATE,
+			Epoch:       c.Epoch,
+			Certificate: c.state.High,
+			Sender:      c.Process.ID(),
+		}
+		c.Process.Send(certMsg, c.Process.Proposer(c.Epoch))
+	}
+	c.scheduleTimeout(TimeoutPropose)
+	// Process messages that process received before starting an epoch.
+	for _, m := range c.messages {
+		c.ProcessMessage(m)
+	}
+	c.messages = nil
+}
+
+// Started informs whether this epoch has been started.
+func (c *HotStuff) Started() bool {
+	return c.epochPhase > Inactive
+}
+
+// Stop this instance of consensus.
+func (c *HotStuff) Stop() {
+	c.epochPhase = Finished
+}
+
+func (c *HotStuff) GetEpoch() int64 {
+	return c.Epoch
+}
+
+// Status returns the phase of this epoch and the certificate of the locked
+// block of the process.
+func (c *HotStuff) Status() *EpochStatus {
+	return &EpochStatus{
+		Epoch:  c.Epoch,
+		Phase:  phaseNames[c.epochPhase],
+		Locked: NewCertificateStatus(c.state.Locked),
+	}
+}
+
+// ProcessMessage processes a consensus message.
+//
+// Contract: message belongs to this epoch of consensus.
+func (c *HotStuff) ProcessMessage(message *Message) {
+	if c.epochPhase == Inactive {
+		c.messages = append(c.messages, message)
+		return
+	}
+	if c.epochPhase == Finished {
+		return
+	}
+	switch message.Type {
+	case PROPOSE:
+		c.processProposal(message)
+	case VOTE:
+		c.processVote(message)
+	case CERTIFICATE:
+		c.processCertificate(message)
+	case BLOCK_RESPONSE:
+		c.processBlockResponse(message)
+	}
+}
+
+func (c *HotStuff) processProposal(proposal *Message) {
+	if c.proposal != nil || !c.checkProposalValidity(proposal) {
+		return
+	}
+	if !c.Process.AddBlock(proposal.Block) {
+		fmt.Printf("P%v proposal could not be added to the blockchain in epoch %v\n", c.Process.ID(), c.Epoch)
+		return
+	}
+	c.proposal = proposal
+	c.Process.Emit(&Event{
+		Type:    PROPOSAL_RECEIVED_EVENT,
+		Epoch:   c.Epoch,
+		Height:  proposal.Block.Height,
+		BlockID: proposal.Block.BlockID(),
+	})
+	locked, commit := c.state.addProposal(proposal.Block, proposal.Certificate)
+	if locked {
+		c.Process.Lock(c.Epoch, c.state.Locked)
+	}
+	if commit != nil {
+		c.state.pending = commit
+		c.tryToCommit()
+	}
+	if c.finished || c.Epoch <= c.state.lastVoted {
+		return
+	}
+	if c.Process.Proposer(c.Epoch) != c.Process.ID() {
+		if !c.shouldVote(proposal) {
+			return
+		}
+		c.state.lastVoted = c.Epoch
+		vote := NewVoteMessage(c.Epoch, proposal.Block.BlockID(), proposal.Block.Height,
+			int16(c.Process.ID()), int16(proposal.Sender))
+		vote.Signature2 = proposal.Signature
+		c.Process.Send(vote, c.Process.Proposer(c.Epoch+1))
+		c.Process.Emit(&Event{
+			Type:    VOTED_EVENT,
+			Epoch:   c.Epoch,
+			Height:  vote.Height,
+			BlockID: vote.BlockID,
+		})
+	}
+	// The next proposer waits for the certificate of the proposal
+	if c.Process.Proposer(c.Epoch+1) != c.Process.ID() {
+		c.finish(true)
+	}
+}
+
+func (c *HotStuff) checkProposalValidity(proposal *Message) bool {
+	isFromProposer := proposal.Sender == c.Process.Proposer(proposal.Epoch)
+	isFromEpoch := proposal.Block.Epoch == proposal.Epoch && proposal.Block.HasPayload()
+	if !validValue(c.Process, proposal) {
+		return false
+	}
+	cert := proposal.Certificate
+	if cert == nil {
+		return isFromProposer && isFromEpoch && proposal.Block.Height == MIN_HEIGHT
+	}
+	extendsCertificate := cert.Type == BLOCK_CERT && cert.Epoch < proposal.Epoch &&
+		proposal.Block.Height == cert.Height+1 && proposal.Block.PrevBlockID.Equal(cert.BlockID())
+	return isFromProposer && isFromEpoch && extendsCertificate &&
+		c.Process.Validators(cert.Epoch).IsByzantineQuorum(cert)
+}
+
+// shouldVote returns whether a valid proposal is safe to vote for: it
+// extends the locked block, or a block certified after it, and the process
+// knows the certificate carried by the block it extends.
+func (c *HotStuff) shouldVote(proposal *Message) bool {
+	cert := proposal.Certificate
+	if cert != nil && c.state.blocks[string(cert.BlockID())] == nil {
+		return false
+	}
+	return cert.RanksHigherOrEqual(c.state.Locked)
+}
+
+// processVote processes a vote for the proposal of this epoch, sent to the
+// proposer of the next epoch.
+func (c *HotStuff) processVote(vote *Message) {
+	if c.certificate != nil || c.Process.Proposer(c.Epoch+1) != c.Process.ID() ||
+		vote.Sender2 != c.Process.Proposer(c.Epoch) {
+		return
+	}
+	blockCert := c.Votes.Get(c.Epoch, vote.BlockID, vote.Height)
+	if blockCert == nil {
+		blockCert = NewBlockCertificate(c.Epoch, vote.BlockID, vote.Height)
+		c.Votes.Add(blockCert)
+		blockCert.AddSignature(vote.Signature2, vote.Sender2)
+	}
+	if !blockCert.AddSignature(vote.Signature, vote.Sender) {
+		return
+	}
+	if c.Process.Validators(c.Epoch).IsByzantineQuorum(blockCert) {
+		c.certificate = blockCert
+		c.state.updateHigh(blockCert)
+		c.Process.Emit(&Event{
+			Type:    CERTIFICATE_EVENT,
+			Epoch:   c.Epoch,
+			Height:  blockCert.Height,
+			BlockID: blockCert.BlockID(),
+		})
+		c.finish(true)
+	}
+}
+
+// processCertificate processes the highest certificate of a process that
+// has not voted in the previous epoch, sent to the proposer of this epoch.
+func (c *HotStuff) processCertificate(message *Message) {
+	cert := message.Certificate
+	if c.proposed || c.Process.Proposer(c.Epoch) != c.Process.ID() ||
+		cert.Epoch >= c.Epoch || !c.Process.Validators(cert.Epoch).IsByzantineQuorum(cert) {
+		return
+	}
+	c.state.updateHigh(cert)
+	if c.state.High.Epoch == c.Epoch-1 {
+		c.broadcastProposal()
+	}
+}
+
+// ProcessTimeout processes a timeout.
+//
+// Contract: timeout belongs to this instance (height) of consensus.
+func (c *HotStuff) ProcessTimeout(timeout *Timeout) {
+	if c.epochPhase == Finished {
+		return
+	}
+	switch timeout.Type {
+	case TimeoutPropose:
+		// No certificate sent to the next proposer
+		c.finish(false)
+	case TimeoutEpochChange:
+		c.broadcastProposal()
+	case TimeoutBlockRequest:
+		c.requests.retry(c.Process, c.Epoch)
+	}
+}
+
+// finish moves the process to the next epoch, once.
+func (c *HotStuff) finish(sentHighCertificate bool) {
+	if c.finished {
+		return
+	}
+	c.finished = true
+	event := &Event{Type: EPOCH_FINISHED_EVENT, Epoch: c.Epoch}
+	if high := c.state.High; high != nil {
+		event.Height = high.Height
+		event.BlockID = high.BlockID()
+	}
+	c.Process.Emit(event)
+	c.Process.Finish(c.Epoch, c.state.High, sentHighCertificate)
+}
+
+func (c *HotStuff) broadcastProposal() {
+	if c.proposed || c.finished {
+		return
+	}
+	value := c.Process.GetValue()
+	if value == nil {
+		fmt.Printf("Generator returned nil in epoch %v\n", c.Epoch)
+		return
+	}
+	c.proposed = true
+	high := c.state.High
+	var height int64 = MIN_HEIGHT
+	if high != nil {
+		height = high.Height + 1
+	}
+	block := &Block{
+		Value:       value,
+		Height:      height,
+		Epoch:       c.Epoch,
+		PrevBlockID: high.BlockID(),
+	}
+	proposal := &Message{
+		Type:        PROPOSE,
+		Epoch:       c.Epoch,
+		Block:       block,
+		Certificate: high,
+		Sender:      c.Process.ID(),
+		SenderFwd:   c.Process.ID(),
+	}
+	c.Process.Broadcast(proposal)
+}
+
+// tryToCommit decides the pending block once its ancestors are known.
+func (c *HotStuff) tryToCommit() {
+	block := c.state.pending
+	if block == nil {
+		return
+	}
+	if c.Process.ExtendValidChain(block) {
+		c.state.pending = nil
+		c.state.prune(block)
+		c.Process.Emit(&Event{
+			Type:    DECIDED_EVENT,
+			Epoch:   block.Epoch,
+			Height:  block.Height,
+			BlockID: block.BlockID(),
+		})
+		c.Process.Decide(block.Epoch, block)
+	} else if height, blockID := c.Process.MissingAncestor(block); blockID != nil {
+		c.requestBlock(height, blockID)
+	}
+}
+
+// requestBlock requests a block missing to commit from the other members.
+// A block is requested again if not received.
+func (c *HotStuff) requestBlock(height int64, blockID BlockID) {
+	c.requests.send(c.Process, c.Epoch, height, blockID)
+}
+
+// processBlockResponse processes a block requested to commit.
+func (c *HotStuff) processBlockResponse(response *Message) {
+	block := response.Block
+	if !c.requests.received(block.BlockID()) {
+		return
+	}
+	c.Process.AddBlock(block)
+	c.tryToCommit()
+}
+
+// Schedule a timeout of the epoch. The propose timeout bounds the time to
+// receive the proposal, sent once the proposer has gathered the votes for
+// the previous proposal: the proposer of the previous epoch enters this
+// epoch up to a proposal delay before the processes that voted.
+func (c *HotStuff) scheduleTimeout(timeoutType int16) {
+	duration := c.Process.TimeoutEpochChange(c.Epoch)
+	if timeoutType == TimeoutPropose {
+		duration = 2 * c.Process.TimeoutPropose(c.Epoch)
+	}
+	c.Process.Schedule(&Timeout{
+		Type:     timeoutType,
+		Epoch:    c.Epoch,
+		Duration: duration,
+	})
+}
@@ -0,0 +1,216 @@
+package consensus
+
+import (
+	"crypto/sha256"
+	"sort"
+)
+
+// Leader policies, selected by name.
+const (
+	ROUND_ROBIN   = "round-robin"
+	SEEDED_RANDOM = "random"
+	REPUTATION    = "reputation"
+)
+
+// LeaderPolicy defines the proposer of each epoch of consensus, among the
+// members of the validator set of the epoch.
+//
+// The schedule is derived from committed blocks only. The proposer of epoch e
+// depends on the blocks proposed up to epoch e-lag, where lag is the maximum
+// number of active epochs. A process starts epoch e only after deciding in an
+// epoch not before e-lag, namely after committing every block proposed up to
+// e-lag, so that all processes derive the same schedule.
+type LeaderPolicy interface {
+	// Leader returns the proposer of an epoch.
+	Leader(epoch int64) int
+
+	// Commit informs the policy of a committed block.
+	// Contract: blocks are committed in height order.
+	Commit(block *Block)
+}
+
+// NewLeaderPolicy creates the leader policy with the provided name, nil if
+// there is no such policy. The round-robin policy is the default one.
+func NewLeaderPolicy(name string, membership *Membership, lag int64) LeaderPolicy {
+	// The schedule of an epoch cannot rely on the epoch itself
+	if lag < 1 {
+		lag = 1
+	}
+	window := int64(membership.At(MIN_EPOCH).Size())
+	switch name {
+	case ROUND_ROBIN, "":
+		return &roundRobin{membership: membership}
+	case SEEDED_RANDOM:
+		return &seededRandom{
+			membership: membership,
+			history:    newCommittedHistory(lag, 1),
+		}
+	case REPUTATION:
+		return &reputation{
+			membership: membership,
+			window:     window,
+			history:    newCommittedHistory(lag, window),
+			leaders:    make(map[int64]int),
+			tentative:  make(map[int64]int),
+		}
+	}
+	return nil
+}
+
+// roundRobin rotates the proposer among all members: epoch modulo n.
+type roundRobin struct {
+	membership *Membership
+}
+
+func (p *roundRobin) Leader(epoch int64) int {
+	members := p.membership.At(epoch)
+	return members.Member(int(epoch % int64(members.Size())))
+}
+
+func (p *roundRobin) Commit(block *Block) {}
+
+// seededRandom draws the proposer of an epoch pseudo-randomly, seeded by the
+// last block committed from the epochs the schedule can rely on.
+type seededRandom struct {
+	membership *Membership
+	history    *committedHistory
+}
+
+func (p *seededRandom) Leader(epoch int64) int {
+	buffer := make([]byte, BlockIDSize+8)
+	if i := p.history.last(epoch); i >= 0 {
+		p.history.blockIDs[i].MarshallTo(buffer)
+	}
+	encoding.PutUint64(buffer[BlockIDSize:], uint64(epoch))
+	hash := sha256.Sum256(buffer)
+	members := p.membership.At(epoch)
+	return members.Member(int(encoding.Uint64(hash[:]) % uint64(members.Size())))
+}
+
+func (p *seededRandom) Commit(block *Block) {
+	p.history.add(block)
+}
+
+// reputation rotates the proposer among members, skipping the ones that
+// were the proposer of a failed epoch in the last window epochs the schedule
+// can rely on. An epoch fails when no block proposed in it is committed, as
+// its proposer did not propose (silence) or equivocated.
+//
+// The proposer of an epoch depends on the proposers of the failed epochs in
+// its window, hence proposers are memoized, so that a sequence of failed
+// epochs is not traversed once per path through their windows.
+type reputation struct {
+	membership *Membership
+	window     int64
+	history    *committedHistory
+
+	// Proposers of epochs computed from a complete history
+	leaders map[int64]int
+	// Proposers of epochs computed from an incomplete history, which may
+	// change with further commits
+	tentative map[int64]int
+}
+
+func (p *reputation) Leader(epoch int64) int {
+	if leader, ok := p.leaders[epoch]; ok {
+		return leader
+	}
+	if leader, ok := p.tentative[epoch]; ok {
+		return leader
+	}
+	to := epoch - p.history.lag
+	from := to - p.window + 1
+	if from < p.history.horizon {
+		from = p.history.horizon
+	}
+	excluded := make(map[int]bool)
+	i := p.history.last(epoch)
+	for e := to; e >= from; e-- {
+		if i >= 0 && p.history.epochs[i] == e {
+			i--
+			continue
+		}
+		excluded[p.Leader(e)] = true
+	}
+	members := p.membership.At(epoch)
+	size := int64(members.Size())
+	leader := members.Member(int(epoch % size))
+	for k := int64(0); k < size; k++ {
+		candidate := members.Member(int((epoch + k) % size))
+		if !excluded[candidate] {
+			leader = candidate
+			break
+		}
+	}
+	if p.history.complete(epoch) {
+		p.leaders[epoch] = leader
+		delete(p.leaders, epoch-2*(p.history.lag+p.window))
+	} else {
+		p.tentative[epoch] = leader
+	}
+	return leader
+}
+
+func (p *reputation) Commit(block *Block) {
+	p.history.add(block)
+	if len(p.tentative) > 0 {
+		p.tentative = make(map[int64]int)
+	}
+}
+
+// committedHistory records the epochs in which recent committed blocks were
+// proposed, in height order.
+type committedHistory struct {
+	lag  int64
+	size int
+
+	// Epochs before the horizon are unknown, as their blocks were either
+	// discarded or committed before the history was created.
+	horizon  int64
+	height   int64
+	epochs   []int64
+	blockIDs []BlockID
+}
+
+// newCommittedHistory creates a history with enough blocks to derive the
+// schedule of the active epochs over a window of epochs.
+func newCommittedHistory(lag int64, window int64) *committedHistory {
+	return &committedHistory{
+		lag:     lag,
+		size:    int(2 * (lag + window)),
+		horizon: MIN_EPOCH,
+		height:  MIN_HEIGHT - 1,
+	}
+}
+
+func (h *committedHistory) add(block *Block) {
+	// Blocks committed before this one are unknown, e.g., after a recovery
+	if block.Height != h.height+1 {
+		h.horizon = block.Epoch
+		h.epochs = nil
+		h.blockIDs = nil
+	}
+	h.height = block.Height
+	h.epochs = append(h.epochs, block.Epoch)
+	h.blockIDs = append(h.blockIDs, block.BlockID())
+	if len(h.epochs) > h.size {
+		h.horizon = h.epochs[0] + 1
+		h.epochs = h.epochs[1:]
+		h.blockIDs = h.blockIDs[1:]
+	}
+}
+
+// last returns the index of the last block the schedule of an epoch can rely
+// on, namely proposed up to epoch-lag, -1 if there is none.
+func (h *committedHistory) last(epoch int64) int {
+	return sort.Search(len(h.epochs), func(i int) bool {
+		return h.epochs[i] > epoch-h.lag
+	}) - 1
+}
+
+// complete returns whether every block the schedule of an epoch can rely on
+// is known, so that the schedule does not change with further commits.
+func (h *committedHistory) complete(epoch int64) bool {
+	return epoch-h.lag < MIN_EPOCH ||
+		(len(h.epochs) > 0 && h.epochs[len(h.epochs)-1] >= epoch-h.lag)
+}
@@ -0,0 +1,109 @@
+package consensus
+
+import (
+	"testing"
+	"time"
+)
+
+// testEpochChain returns a chain of blocks proposed in the provided epochs.
+func testEpochChain(prev *Block, epochs ...int64) []*Block {
+	var blocks []*Block
+	for _, e := range epochs {
+		prev = NewBlock(testRandValue(16), prev)
+		prev.Epoch = e
+		blocks = append(blocks, prev)
+	}
+	return blocks
+}
+
+// testCommit commits a chain of blocks to a policy, returning the last block.
+func testCommit(policy LeaderPolicy, blocks []*Block) *Block {
+	for _, b := range blocks {
+		policy.Commit(b)
+	}
+	return blocks[len(blocks)-1]
+}
+
+func TestLeaderPolicyRoundRobin(t *testing.T) {
+	policy := NewLeaderPolicy("", testMembership(4), 2)
+	for e := int64(0); e < 8; e++ {
+		if policy.Leader(e) != int(e%4) {
+			t.Errorf("Leader(%v) returned %v expected %v", e, policy.Leader(e), e%4)
+		}
+	}
+	if NewLeaderPolicy("unknown", testMembership(4), 2) != nil {
+		t.Error("Expected no policy for unknown name")
+	}
+}
+
+func TestLeaderPolicySeededRandom(t *testing.T) {
+	p1 := NewLeaderPolicy(SEEDED_RANDOM, testMembership(4), 2)
+	p2 := NewLeaderPolicy(SEEDED_RANDOM, testMembership(4), 2)
+	chain := testEpochChain(nil, 0, 1, 2)
+	testCommit(p1, chain)
+	leader := p1.Leader(4)
+	// Blocks proposed after epoch 4-lag do not change the schedule
+	testCommit(p1, testEpochChain(chain[2], 3, 4))
+	if p1.Leader(4) != leader {
+		t.Error("Leader(4) changed with blocks of later epochs")
+	}
+	// Processes with the same committed chain derive the same schedule
+	testCommit(p2, chain)
+	for e := int64(0); e < 5; e++ {
+		if p1.Leader(e) != p2.Leader(e) {
+			t.Errorf("Leader(%v) differs: %v %v", e, p1.Leader(e), p2.Leader(e))
+		}
+	}
+}
+
+func TestLeaderPolicyReputation(t *testing.T) {
+	policy := NewLeaderPolicy(REPUTATION, testMembership(4), 1)
+	// Epoch 2 fails, its proposer is excluded
+	b := testCommit(policy, testEpochChain(nil, 0, 1, 3))
+	if policy.Leader(2) != 2 {
+		t.Error("Leader(2) returned", policy.Leader(2), "expected 2")
+	}
+	if policy.Leader(4) != 0 {
+		t.Error("Leader(4) returned", policy.Leader(4), "expected 0")
+	}
+	b = testCommit(policy, testEpochChain(b, 4, 5))
+	if policy.Leader(6) != 3 {
+		t.Error("Leader(6) returned", policy.Leader(6), "expected 3 skipping 2")
+	}
+	// Once epoch 2 is out of the window, its proposer is scheduled again
+	b = testCommit(policy, testEpochChain(b, 6, 7, 8, 9))
+	if policy.Leader(10) != 2 {
+		t.Error("Leader(10) returned", policy.Leader(10), "expected 2")
+	}
+
+	// A recovered process ignores the epochs before its last commit
+	recovered := NewLeaderPolicy(REPUTATION, testMembership(4), 1)
+	b = testEpochChain(b, 12)[0]
+	recovered.Commit(b)
+	if recovered.Leader(12) != 0 || recovered.Leader(14) != 2 {
+		t.Error("Leader(12) and Leader(14) returned", recovered.Leader(12), recovered.Leader(14), "expected 0 and 2")
+	}
+}
+
+func TestLeaderPolicyReputationFailedEpochs(t *testing.T) {
+	policy := NewLeaderPolicy(REPUTATION, testMembership(4), 1)
+	b := testCommit(policy, testEpochChain(nil, 0, 1))
+	// Epochs 2 to 99 fail, each with window failed epochs in its window
+	done := make(chan int)
+	go func() {
+		done <- policy.Leader(100)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Leader(100) not returned within a second")
+	}
+	// Schedules computed before a commit are not kept after it
+	if policy.Leader(6) != 2 {
+		t.Error("Leader(6) returned", policy.Leader(6), "expected 2 as every member is excluded")
+	}
+	testCommit(policy, testEpochChain(b, 3))
+	if policy.Leader(6) != 3 {
+		t.Error("Leader(6) returned", policy.Leader(6), "expected 3 skipping 0, 1 and 2")
+	}
+}
@@ -3,6 +3,7 @@ package consensus
 import (
 	"encoding/binary"
 	"fmt"
+	"time"
 
 	"dslab.inf.usi.ch/tendermint/crypto"
 )
@@ -17,6 +18,16 @@ const (
 
 	DELTA_REQUEST
 	DELTA_RESPONSE
+
+	BLOCK_REQUEST
+	BLOCK_RESPONSE
+
+	EVIDENCE
+
+	PAYLOAD
+	CHUNK
+
+	PRECOMMIT
 )
 
 // Code of consensus marshalled messages.
@@ -37,6 +48,8 @@ type Message struct {
 	Block       *Block
 	BlockID     BlockID
 	Certificate *Certificate
+	Evidence    *Evidence
+	Chunk       *Chunk
 
 	Sender     int
 	Signature  Signature
@@ -47,6 +60,9 @@ type Message struct {
 	// and it is set when a process forward the proposal message.
 	SenderFwd int
 
+	// Local time at which the message was received, it is not marshalled.
+	Received time.Time
+
 	// Unexported byte version
 	marshalled []byte
 	// Message payload
@@ -84,6 +100,19 @@ func NewVoteMessage(e int64, id BlockID, height int64, sender int16, sender2 int
 	}
 }
 
+// NewPrecommitMessage precommits a block in an epoch, or nil if the block ID
+// is nil. The type of the message is signed, so that precommits cannot be
+// confused with votes for the same block.
+func NewPrecommitMessage(e int64, id BlockID, height int64, sender int) *Message {
+	return &Message{
+		Type:    PRECOMMIT,
+		Epoch:   e,
+		BlockID: id,
+		Height:  height,
+		Sender:  sender,
+	}
+}
+
 func NewQuitEpochMessage(e int64, c *Certificate) *Message {
 	return &Message{
 		Type:        QUIT_EPOCH,
@@ -116,6 +145,85 @@ func NewDeltaResponseMessage(payload []byte, sender int) *Message {
 	}
 }
 
+// NewBlockRequestMessage requests the block with the provided height and ID.
+// Block requests are signed, so that only members of the validator set can
+// request blocks, which are sent to the sender of the request. Block
+// responses are not signed, as the requested block is authenticated by its ID.
+func NewBlockRequestMessage(e int64, height int64, id BlockID, sender int) *Message {
+	return &Message{
+		Type:    BLOCK_REQUEST,
+		Epoch:   e,
+		Height:  height,
+		BlockID: id,
+		Sender:  sender,
+	}
+}
+
+// NewBlockResponseMessage responds to a block request sent in an epoch.
+func NewBlockResponseMessage(e int64, b *Block, sender int) *Message {
+	return &Message{
+		Type:   BLOCK_RESPONSE,
+		Epoch:  e,
+		Height: b.Height,
+		Block:  b,
+		Sender: sender,
+	}
+}
+
+// NewEvidenceMessage disseminates the evidence that the proposer of an epoch
+// has equivocated. The message is not signed, the evidence carries the
+// proposer's signatures.
+func NewEvidenceMessage(e *Evidence) *Message {
+	return &Message{
+		Type:     EVIDENCE,
+		Epoch:    e.Epoch,
+		Evidence: e,
+	}
+}
+
+// NewPayloadMessage disseminates the value of a block whose header was
+// proposed in an epoch. The message is not signed, as the value is
+// authenticated by the payload hash of the signed header.
+func NewPayloadMessage(e int64, b *Block, sender int) *Message {
+	return &Message{
+		Type:   PAYLOAD,
+		Epoch:  e,
+		Height: b.Height,
+		Block:  b,
+		Sender: sender,
+	}
+}
+
+// NewChunkMessage disseminates a chunk of the value of a block whose header
+// was proposed in an epoch. The message is not signed, the chunk carries its
+// Merkle proof and reconstructed values are checked against the header.
+func NewChunkMessage(e int64, header *Block, chunk *Chunk, sender int) *Message {
+	return &Message{
+		Type:   CHUNK,
+		Epoch:  e,
+		Height: header.Height,
+		Block:  header,
+		Chunk:  chunk,
+		Sender: sender,
+	}
+}
+
+// IsControlMessage checks whether a marshalled message is a control message.
+// Control messages are small messages, e.g., votes, that the synchrony
+// assumptions require to be timely, and are delivered with priority over
+// bulk messages, e.g., proposals and blocks. Messages other than consensus
+// ones are control messages.
+func IsControlMessage(buffer []byte) bool {
+	if len(buffer) < 2 || buffer[0] != MessageCode {
+		return true
+	}
+	switch int16(buffer[1]) {
+	case SILENCE, VOTE, QUIT_EPOCH, CERTIFICATE, EVIDENCE, PRECOMMIT:
+		return true
+	}
+	return false
+}
+
 // MessageFromBytes parses a message from a byte array.
 // The provided byte array is retained and should not be externally re-used.
 func MessageFromBytes(buffer []byte) *Message {
@@ -129,6 +237,8 @@ func MessageFromBytes(buffer []byte) *Message {
 	var block *Block = nil
 	var blockID BlockID = nil
 	var certificate *Certificate = nil
+	var evidence *Evidence = nil
+	var chunk *Chunk = nil
 	var payload []byte
 	var sender2 int16
 	var signature2 Signature
@@ -136,9 +246,15 @@ func MessageFromBytes(buffer []byte) *Message {
 	case PROPOSE:
 		n := int32(encoding.Uint32(buffer[index:]))
 		index += 4
+		isHeader := buffer[index] == 1
+		index += 1
 		end := index + int(n)
 		blockHeightIndex := index
-		block = BlockFromBytes(buffer[index:end])
+		if isHeader {
+			block = BlockHeaderFromBytes(buffer[index:end])
+		} else {
+			block = BlockFromBytes(buffer[index:end])
+		}
 		index = end
 		if buffer[index] == 1 {
 			index += 1
@@ -165,6 +281,15 @@ func MessageFromBytes(buffer []byte) *Message {
 		index += SignatureSize
 	case SILENCE:
 		payload = buffer[2:index]
+	case PRECOMMIT:
+		isNil := buffer[index] == 0
+		index += 1
+		if !isNil {
+			height = int64(encoding.Uint64(buffer[index:]))
+			blockID = BlockIDFromBytes(buffer[index+8:])
+		}
+		index += 8 + BlockIDSize
+		payload = buffer[1:index]
 	case QUIT_EPOCH:
 		certificate = CertificateFromBytes(buffer[2:])
 		epoch = certificate.Epoch
@@ -174,9 +299,34 @@ func MessageFromBytes(buffer []byte) *Message {
 	case DELTA_REQUEST, DELTA_RESPONSE:
 		payload = buffer[2 : len(buffer)-2]
 		index = len(buffer) - 2
+	case BLOCK_REQUEST:
+		height = int64(encoding.Uint64(buffer[index:]))
+		index += 8
+		blockID = BlockIDFromBytes(buffer[index:])
+		index += BlockIDSize
+		payload = buffer[1:index]
+	case BLOCK_RESPONSE, PAYLOAD:
+		n := int32(encoding.Uint32(buffer[index:]))
+		index += 4
+		end := index + int(n)
+		block = BlockFromBytes(buffer[index:end])
+		height = block.Height
+		index = end
+	case EVIDENCE:
+		evidence = EvidenceFromBytes(buffer[index:])
+		index += evidence.ByteSize()
+	case CHUNK:
+		n := int32(encoding.Uint32(buffer[index:]))
+		index += 4
+		end := index + int(n)
+		block = BlockHeaderFromBytes(buffer[index:end])
+		height = block.Height
+		index = end
+		chunk = ChunkFromBytes(buffer[index:])
+		index += chunk.ByteSize()
 	}
 	var sender int16
-	if mType != QUIT_EPOCH && mType != CERTIFICATE {
+	if mType != QUIT_EPOCH && mType != CERTIFICATE && mType != EVIDENCE {
 		sender = int16(encoding.Uint16(buffer[index:]))
 		index += 2
 	}
@@ -186,7 +336,8 @@ func MessageFromBytes(buffer []byte) *Message {
 		index += 2
 	}
 	var signature Signature
-	if mType != QUIT_EPOCH && mType != CERTIFICATE && mType != DELTA_REQUEST && mType != DELTA_RESPONSE {
+	if mType != QUIT_EPOCH && mType != CERTIFICATE && mType != DELTA_REQUEST && mType != DELTA_RESPONSE &&
+		mType != BLOCK_RESPONSE && mType != EVIDENCE && mType != PAYLOAD && mType != CHUNK {
 		signature = SignatureFromBytes(buffer[index:])
 	}
 
@@ -197,6 +348,8 @@ func MessageFromBytes(buffer []byte) *Message {
 		Block:       block,
 		BlockID:     blockID,
 		Certificate: certificate,
+		Evidence:    evidence,
+		Chunk:       chunk,
 
 		Sender:     int(sender),
 		Signature:  signature,
@@ -220,12 +373,14 @@ func (m *Message) ByteSize() int {
 	switch m.Type {
 	case PROPOSE:
 		if m.Certificate == nil {
-			return 19 + m.Block.ByteSize() + SignatureSize
+			return 20 + m.Block.ByteSize() + SignatureSize
 		} else {
-			return 19 + m.Block.ByteSize() + m.Certificate.ByteSize() + SignatureSize
+			return 20 + m.Block.ByteSize() + m.Certificate.ByteSize() + SignatureSize
 		}
 	case SILENCE:
 		return 12 + SignatureSize
+	case PRECOMMIT:
+		return 21 + BlockIDSize + SignatureSize
 	case VOTE:
 		return 22 + BlockIDSize + 2*SignatureSize
 	case QUIT_EPOCH:
@@ -234,6 +389,14 @@ func (m *Message) ByteSize() int {
 		return 2 + m.Certificate.ByteSize() + 8
 	case DELTA_REQUEST, DELTA_RESPONSE:
 		return 2 + len(m.payload) + 2
+	case BLOCK_REQUEST:
+		return 20 + BlockIDSize + SignatureSize
+	case BLOCK_RESPONSE, PAYLOAD:
+		return 16 + m.Block.ByteSize()
+	case EVIDENCE:
+		return 10 + m.Evidence.ByteSize()
+	case CHUNK:
+		return 16 + m.Block.ByteSize() + m.Chunk.ByteSize()
 	default:
 		return 0
 	}
@@ -257,8 +420,10 @@ func (m *Message) Marshall() []byte {
 }
 
 // This message is only called before process forwards the proposal message.
+// The encoding is the received one, the value of the block may have been
+// attached to its header since.
 func (m *Message) setFwdSender(sender int) {
-	index := m.ByteSize() - SignatureSize - 2
+	index := len(m.marshalled) - SignatureSize - 2
 	encoding.PutUint16(m.marshalled[index:], uint16(sender))
 }
 
@@ -277,6 +442,12 @@ func (m *Message) MarshallTo(buffer []byte) {
 		blockSize := m.Block.ByteSize()
 		encoding.PutUint32(buffer[index:], uint32(blockSize))
 		index += 4
+		if m.Block.HasPayload() {
+			buffer[index] = 0
+		} else {
+			buffer[index] = 1
+		}
+		index += 1
 		n = m.Block.MarshallTo(buffer[index:])
 		index += n
 		if m.Certificate != nil {
@@ -313,6 +484,16 @@ func (m *Message) MarshallTo(buffer []byte) {
 		//m.BlockID.MarshallTo(m.payload[8:])
 	case SILENCE:
 		m.payload = buffer[2:index]
+	case PRECOMMIT:
+		// payload is type+epoch+flag+height+blockID, zeroed if nil
+		buffer[index] = 0
+		if m.BlockID != nil {
+			buffer[index] = 1
+			encoding.PutUint64(buffer[index+1:], uint64(m.Height))
+			m.BlockID.MarshallTo(buffer[index+9:])
+		}
+		index += 9 + BlockIDSize
+		m.payload = buffer[1:index]
 	case QUIT_EPOCH:
 		n = m.Certificate.MarshallTo(buffer[2:])
 	case CERTIFICATE:
@@ -321,8 +502,28 @@ func (m *Message) MarshallTo(buffer []byte) {
 	case DELTA_REQUEST, DELTA_RESPONSE:
 		copy(buffer[2:], m.payload)
 		index = 2 + len(m.payload)
+	case BLOCK_REQUEST:
+		encoding.PutUint64(buffer[index:], uint64(m.Height))
+		index += 8
+		n = m.BlockID.MarshallTo(buffer[index:])
+		index += n
+		// payload is type+epoch+height+blockID
+		m.payload = buffer[1:index]
+	case BLOCK_RESPONSE, PAYLOAD:
+		encoding.PutUint32(buffer[index:], uint32(m.Block.ByteSize()))
+		index += 4
+		n = m.Block.MarshallTo(buffer[index:])
+		index += n
+	case EVIDENCE:
+		n = m.Evidence.MarshallTo(buffer[index:])
+		index += n
+	case CHUNK:
+		encoding.PutUint32(buffer[index:], uint32(m.Block.ByteSize()))
+		index += 4
+		index += m.Block.MarshallTo(buffer[index:])
+		index += m.Chunk.MarshallTo(buffer[index:])
 	}
-	if m.Type != QUIT_EPOCH && m.Type != CERTIFICATE {
+	if m.Type != QUIT_EPOCH && m.Type != CERTIFICATE && m.Type != EVIDENCE {
 		encoding.PutUint16(buffer[index:], uint16(m.Sender))
 		index += 2
 	}
@@ -335,7 +536,9 @@ func (m *Message) MarshallTo(buffer []byte) {
 // The message is first encoded into bytes, from which the signature is computed.
 // The computed signature bytes then becomes the suffix of the byte-encoded message.
 func (m *Message) Sign(key crypto.PrivateKey) {
-	if m.Type == QUIT_EPOCH || m.Type == CERTIFICATE || m.Type == DELTA_REQUEST || m.Type == DELTA_RESPONSE {
+	if m.Type == QUIT_EPOCH || m.Type == CERTIFICATE || m.Type == DELTA_REQUEST || m.Type == DELTA_RESPONSE ||
+		m.Type == BLOCK_RESPONSE || m.Type == EVIDENCE || m.Type == PAYLOAD ||
+		m.Type == CHUNK {
 		return
 	}
 	message := m.Marshall() // [message bytes : message signature]
@@ -345,6 +548,23 @@ func (m *Message) Sign(key crypto.PrivateKey) {
 	m.Signature.MarshallTo(message[sigIndex:])
 }
 
+// ValidateCertificate checks the certificate attached to a message, if any,
+// with the validator set of the epoch of the certificate. Proposals may not
+// carry a certificate, QUIT_EPOCH and CERTIFICATE messages must, the latter
+// a block certificate.
+func (m *Message) ValidateCertificate(validators func(epoch int64) *ValidatorSet) error {
+	if m.Certificate == nil {
+		if m.Type == QUIT_EPOCH || m.Type == CERTIFICATE {
+			return fmt.Errorf("missing certificate")
+		}
+		return nil
+	}
+	if m.Type == CERTIFICATE && m.Certificate.Type != BLOCK_CERT {
+		return fmt.Errorf("invalid certificate type %v", m.Certificate.Type)
+	}
+	return validators(m.Certificate.Epoch).ValidateCertificate(m.Certificate)
+}
+
 // VerifySignature verifies the message signature with the provided public key.
 func (m *Message) VerifySignature(key crypto.PublicKey) bool {
 	return key.VerifySignature(m.Payload(), m.Signature)
@@ -362,5 +582,8 @@ func (m *Message) GetCryptoSignatures() []*crypto.Signature {
 	if m.Certificate != nil {
 		sigs = append(sigs, m.Certificate.GetCryptoSignatures()...)
 	}
+	if m.Evidence != nil {
+		sigs = append(sigs, m.Evidence.GetCryptoSignatures()...)
+	}
 	return sigs
 }
@@ -118,6 +118,23 @@ func TestMessageMarshalling(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+	vote := m
+	// Precommits, for a block or nil
+	m = NewPrecommitMessage(MIN_EPOCH, b0.BlockID(), b0.Height, 1)
+	m.Sign(keys[1])
+	err = testMarshalling(m)
+	if err != nil {
+		t.Error(err)
+	}
+	if bytes.Equal(m.Payload(), vote.Payload()) {
+		t.Error("Expected precommit payload to differ from vote payload")
+	}
+	m = NewPrecommitMessage(MIN_EPOCH, nil, 0, 1)
+	m.Sign(keys[1])
+	err = testMarshalling(m)
+	if err != nil {
+		t.Error(err)
+	}
 	// Test 4: Quit epoch
 	//A - silence certificate
 	sc := testSilenceCertificate(MIN_EPOCH)
@@ -156,6 +173,55 @@ func TestMessageMarshalling(t *testing.T) {
 		t.Error(err)
 	}
 
+	m = NewBlockRequestMessage(MIN_EPOCH+3, b1.Height, b1.BlockID(), 1)
+	m.Sign(keys[1])
+	err = testMarshalling(m)
+	if err != nil {
+		t.Error(err)
+	}
+	// Block requests are signed by their sender
+	mm := MessageFromBytes(m.Marshall())
+	if !mm.VerifySignature(keys[1].PubKey()) || mm.VerifySignature(keys[2].PubKey()) {
+		t.Error("Expected block request to be signed by its sender")
+	}
+	m = NewBlockResponseMessage(MIN_EPOCH+3, b1, 2)
+	m.Sign(keys[2])
+	err = testMarshalling(m)
+	if err != nil {
+		t.Error(err)
+	}
+	if m.Signature != nil || len(m.GetCryptoSignatures()) != 0 {
+		t.Error("Expected block response to be unsigned")
+	}
+
+}
+
+func TestIsControlMessage(t *testing.T) {
+	b := NewBlock(testRandValue(64), nil)
+	control := []*Message{
+		NewSilenceMessage(MIN_EPOCH, 0),
+		NewVoteMessage(MIN_EPOCH, b.BlockID(), b.Height, 1, 0),
+		NewPrecommitMessage(MIN_EPOCH, b.BlockID(), b.Height, 1),
+		NewQuitEpochMessage(MIN_EPOCH, testSilenceCertificate(MIN_EPOCH)),
+	}
+	for _, m := range control {
+		if !IsControlMessage(m.Marshall()) {
+			t.Error("Expected control message", m)
+		}
+	}
+	bulk := []*Message{
+		NewProposeMessage(MIN_EPOCH, b, nil, 0),
+		NewBlockResponseMessage(MIN_EPOCH, b, 1),
+		NewPayloadMessage(MIN_EPOCH, b, 0),
+	}
+	for _, m := range bulk {
+		if IsControlMessage(m.Marshall()) {
+			t.Error("Unexpected control message", m)
+		}
+	}
+	if !IsControlMessage([]byte{MessageCode + 1, PROPOSE}) {
+		t.Error("Expected messages other than consensus ones to be control messages")
+	}
 }
 
 func TestMessageSignatures(t *testing.T) {
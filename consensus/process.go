@@ -12,13 +12,17 @@ type Process interface {
 	// NumProcesses returns the total number of processes.
 	NumProcesses() int
 
+	// Validators returns the validator set in effect in an epoch.
+	Validators(epoch int64) *ValidatorSet
+
 	// Broadcast a consensus message.
 	Broadcast(message *Message)
 
 	// Forward a consensus message.
 	Forward(message *Message)
 
-	// Send a consensus message to a set of peers.
+	// Send a consensus message to a set of peers. Messages of other
+	// processes are sent as signed by their sender.
 	Send(message *Message, ids ...int)
 
 	// Schedule a consensus timeout.
@@ -36,6 +40,10 @@ type Process interface {
 	// Extend check if bb extend b.
 	ExtendValidChain(b *Block) bool
 
+	// MissingAncestor returns the height and ID of the first ancestor of a
+	// block missing in the blockchain, or a nil ID if none is missing.
+	MissingAncestor(b *Block) (int64, BlockID)
+
 	// IsEquivocatedBlock check if block is equivocated block.
 	IsEquivocatedBlock(block *Block) bool
 
@@ -45,6 +53,12 @@ type Process interface {
 	// Finish an epoch of consensus.
 	Finish(epoch int64, lockedCertificate *Certificate, sentLockedCertificate bool)
 
+	// Lock informs that an epoch of consensus has locked a certificate.
+	Lock(epoch int64, lockedCertificate *Certificate)
+
+	// ReportEvidence reports that the proposer of an epoch has equivocated.
+	ReportEvidence(evidence *Evidence)
+
 	// TimeoutPropose returns the timeout duration within which proposer should propose.
 	TimeoutPropose(epoch int64) time.Duration
 
@@ -56,4 +70,7 @@ type Process interface {
 
 	// TimeoutEpochChange returns the timeout duration of timeout needed to learn the highest locked certificate.
 	TimeoutEpochChange(epoch int64) time.Duration
+
+	// EventSink receives the events of the epochs of consensus.
+	EventSink
 }
@@ -11,10 +11,15 @@ type ProcessState struct {
 	validCertificate  *Certificate
 	lockedCertificate *Certificate
 	blockchain        []*Block
+
+	// Messages sent, with their receivers, and events emitted
+	outgoing []*Outgoing
+	events   []*Event
 }
 
 func testProcessState(sendQueue []*Message, timeoutQueue []*Timeout, decision *Block, validCertificate *Certificate, lockedCertificate *Certificate, blockchain []*Block) *ProcessState {
-	return &ProcessState{sendQueue, timeoutQueue, decision, validCertificate, lockedCertificate, blockchain}
+	return &ProcessState{sendQueue: sendQueue, timeoutQueue: timeoutQueue, decision: decision,
+		validCertificate: validCertificate, lockedCertificate: lockedCertificate, blockchain: blockchain}
 }
 
 func NewProcessState() *ProcessState {
@@ -43,16 +48,26 @@ func (p *TestProcess) NumProcesses() int {
 	return p.num
 }
 
+func (p *TestProcess) Validators(epoch int64) *ValidatorSet {
+	return NewValidatorSet(MIN_EPOCH, p.num, nil, nil)
+}
+
 func (p *TestProcess) Broadcast(message *Message) {
 	p.state.sendQueue = append(p.state.sendQueue, message)
+	p.state.outgoing = append(p.state.outgoing, &Outgoing{Message: message})
 }
 
 func (p *TestProcess) Forward(message *Message) {
 	p.state.sendQueue = append(p.state.sendQueue, message)
+	p.state.outgoing = append(p.state.outgoing, &Outgoing{Message: message, Forwarded: true})
 }
 
 func (p *TestProcess) Send(message *Message, ids ...int) {
 	p.state.sendQueue = append(p.state.sendQueue, message)
+	if ids == nil {
+		ids = []int{}
+	}
+	p.state.outgoing = append(p.state.outgoing, &Outgoing{Message: message, To: ids})
 }
 
 func (p *TestProcess) Schedule(timeout *Timeout) {
@@ -76,6 +91,13 @@ func (p *TestProcess) ExtendValidChain(b *Block) bool {
 	return true
 }
 
+func (p *TestProcess) MissingAncestor(b *Block) (int64, BlockID) {
+	return 0, nil
+}
+
+func (p *TestProcess) ReportEvidence(evidence *Evidence) {
+}
+
 func (p *TestProcess) IsEquivocatedBlock(block *Block) bool {
 	return false
 }
@@ -85,11 +107,18 @@ func (p *TestProcess) Decide(epoch int64, block *Block) {
 }
 
 // Finish an epoch of consensus.
-func (p *TestProcess) Finish(epoch int64, validCertificate *Certificate, lockedCertificate *Certificate, oldCertificate *Certificate) {
-	p.state.validCertificate = validCertificate
+func (p *TestProcess) Finish(epoch int64, lockedCertificate *Certificate, sentLockedCertificate bool) {
+	p.state.lockedCertificate = lockedCertificate
+}
+
+func (p *TestProcess) Lock(epoch int64, lockedCertificate *Certificate) {
 	p.state.lockedCertificate = lockedCertificate
 }
 
+func (p *TestProcess) Emit(event *Event) {
+	p.state.events = append(p.state.events, event)
+}
+
 func (p *TestProcess) TimeoutPropose(epoch int64) time.Duration {
 	// TODO:
 	return time.Second
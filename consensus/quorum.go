@@ -0,0 +1,57 @@
+package consensus
+
+// QuorumRule defines whether the processes that signed a certificate hold
+// enough voting power for the certificate to be complete.
+type QuorumRule interface {
+	// VotingPower returns the voting power of a process.
+	VotingPower(id int) int64
+
+	// IsQuorum returns whether the signers of a certificate hold more than
+	// half of the total voting power.
+	IsQuorum(c *Certificate) bool
+
+	// IsUnanimous returns whether the signers of a certificate hold all the
+	// voting power, as required by the fast path.
+	IsUnanimous(c *Certificate) bool
+}
+
+// NewQuorumRule creates a stake-weighted quorum rule with the provided voting
+// power per process. If votingPower is empty, every process has voting power
+// 1, so that quorums are majorities of processes. Voting power is capped to
+// MaxVotingPower, so that the total voting power does not overflow.
+func NewQuorumRule(numProcesses int, votingPower []int64) QuorumRule {
+	r := &weightedQuorum{
+		votingPower: make([]int64, numProcesses),
+	}
+	for id := range r.votingPower {
+		r.votingPower[id] = 1
+		if len(votingPower) > 0 {
+			r.votingPower[id] = votingPower[id]
+		}
+		if r.votingPower[id] > MaxVotingPower {
+			r.votingPower[id] = MaxVotingPower
+		}
+		r.total += r.votingPower[id]
+	}
+	return r
+}
+
+type weightedQuorum struct {
+	votingPower []int64
+	total       int64
+}
+
+func (r *weightedQuorum) VotingPower(id int) int64 {
+	if id < 0 || id >= len(r.votingPower) {
+		return 0
+	}
+	return r.votingPower[id]
+}
+
+func (r *weightedQuorum) IsQuorum(c *Certificate) bool {
+	return 2*c.Weight(r) > r.total
+}
+
+func (r *weightedQuorum) IsUnanimous(c *Certificate) bool {
+	return c.Weight(r) == r.total
+}
@@ -0,0 +1,45 @@
+package consensus
+
+import "testing"
+
+func testQuorumCertificate(signers ...int) *Certificate {
+	c := NewBlockCertificate(0, NewBlock([]byte("b"), nil).BlockID(), MIN_HEIGHT)
+	for _, s := range signers {
+		c.AddSignature(make([]byte, SignatureSize), s)
+	}
+	return c
+}
+
+func TestQuorumRuleEqualPower(t *testing.T) {
+	rule := NewQuorumRule(4, nil)
+	if rule.IsQuorum(testQuorumCertificate(0, 1)) {
+		t.Error("Expected 2 out of 4 processes not to be a quorum")
+	}
+	if !rule.IsQuorum(testQuorumCertificate(0, 1, 2)) {
+		t.Error("Expected 3 out of 4 processes to be a quorum")
+	}
+	if rule.IsUnanimous(testQuorumCertificate(0, 1, 2)) {
+		t.Error("Expected 3 out of 4 processes not to be unanimous")
+	}
+	if !rule.IsUnanimous(testQuorumCertificate(0, 1, 2, 3)) {
+		t.Error("Expected 4 out of 4 processes to be unanimous")
+	}
+}
+
+func TestQuorumRuleWeighted(t *testing.T) {
+	rule := NewQuorumRule(4, []int64{7, 1, 1, 1})
+	c := testQuorumCertificate(1, 2, 3)
+	if rule.IsQuorum(c) || c.Weight(rule) != 3 {
+		t.Error("Expected weight 3 not to be a quorum, got", c.Weight(rule))
+	}
+	c.AddSignature(make([]byte, SignatureSize), 0)
+	if !rule.IsUnanimous(c) || c.Weight(rule) != 10 {
+		t.Error("Expected weight 10 to be unanimous, got", c.Weight(rule))
+	}
+	if !rule.IsQuorum(testQuorumCertificate(0)) {
+		t.Error("Expected process 0 alone to be a quorum")
+	}
+	if rule.VotingPower(4) != 0 {
+		t.Error("Expected no voting power for unknown process, got", rule.VotingPower(4))
+	}
+}
@@ -0,0 +1,165 @@
+package consensus
+
+import (
+	"bytes"
+	"fmt"
+
+	"dslab.inf.usi.ch/tendermint/crypto"
+)
+
+// Values starting with this prefix are reconfiguration transactions.
+// The first 4 bytes do not match the ID of any workload generator, so that
+// reconfigurations are not accounted as submitted values.
+var reconfigurationPrefix = []byte("\xff\xff\xff\xffRECONFIGURATION")
+
+// ValidatorUpdate changes the voting power or the key of a process.
+type ValidatorUpdate struct {
+	ID int
+
+	// A zero voting power removes the process from the validator set.
+	VotingPower int64
+
+	// If nil, the process keeps its current key.
+	PublicKey crypto.PublicKey
+}
+
+// MaxVotingPower is the maximum voting power of a process, so that the total
+// voting power of a validator set, multiplied by the quorum rules, does not
+// overflow.
+const MaxVotingPower = int64(1) << 40
+
+// Reconfiguration is a transaction that changes the validator set.
+//
+// A reconfiguration takes effect once committed in a block, in the epoch
+// defined by Membership. Reconfigurations are authorised by the operator of
+// the validator set, whose key signs the sequence number and the updates.
+// Sequence numbers increase with the reconfigurations applied to a validator
+// set, so that a committed reconfiguration cannot be replayed.
+type Reconfiguration struct {
+	Sequence uint64
+	Updates  []ValidatorUpdate
+
+	// Signature of the operator, nil if not signed
+	Signature []byte
+}
+
+// String returns string representation of a reconfiguration.
+func (r *Reconfiguration) String() string {
+	s := fmt.Sprintf("Reconfiguration %v:", r.Sequence)
+	for _, u := range r.Updates {
+		s += fmt.Sprintf(" %v(%v)", u.ID, u.VotingPower)
+	}
+	return s
+}
+
+// ByteSize returns the size of the bytes encoded version of the reconfiguration.
+// Layout: [prefix][Sequence 8][updates 2][Updates][signature size 1][Signature]
+func (r *Reconfiguration) ByteSize() int {
+	return r.payloadSize() + 1 + len(r.Signature)
+}
+
+// Size of the encoding signed by the operator, without the signature.
+func (r *Reconfiguration) payloadSize() int {
+	size := len(reconfigurationPrefix) + 10
+	for _, u := range r.Updates {
+		size += 11
+		if u.PublicKey != nil {
+			size += len(u.PublicKey.Bytes())
+		}
+	}
+	return size
+}
+
+// Payload returns the encoding of the reconfiguration signed by the operator.
+func (r *Reconfiguration) Payload() []byte {
+	return r.Marshall()[:r.payloadSize()]
+}
+
+// Sign the reconfiguration with the private key of the operator.
+func (r *Reconfiguration) Sign(key crypto.PrivateKey) {
+	r.Signature = nil
+	r.Signature, _ = key.Sign(r.Payload())
+}
+
+// Verify checks that the reconfiguration is signed by the operator and that
+// its updates are within bounds. Reconfigurations are not authorised if there
+// is no operator.
+func (r *Reconfiguration) Verify(operator crypto.PublicKey) bool {
+	if operator == nil || len(r.Signature) == 0 || !operator.VerifySignature(r.Payload(), r.Signature) {
+		return false
+	}
+	for _, u := range r.Updates {
+		if u.VotingPower < 0 || u.VotingPower > MaxVotingPower {
+			return false
+		}
+	}
+	return true
+}
+
+// Marshall serialises the reconfiguration to a value to be proposed.
+func (r *Reconfiguration) Marshall() []byte {
+	buffer := make([]byte, r.ByteSize())
+	index := copy(buffer, reconfigurationPrefix)
+	encoding.PutUint64(buffer[index:], r.Sequence)
+	encoding.PutUint16(buffer[index+8:], uint16(len(r.Updates)))
+	index += 10
+	for _, u := range r.Updates {
+		encoding.PutUint16(buffer[index:], uint16(u.ID))
+		encoding.PutUint64(buffer[index+2:], uint64(u.VotingPower))
+		index += 11
+		if u.PublicKey != nil {
+			buffer[index-1] = byte(len(u.PublicKey.Bytes()))
+			index += copy(buffer[index:], u.PublicKey.Bytes())
+		}
+	}
+	buffer[index] = byte(len(r.Signature))
+	copy(buffer[index+1:], r.Signature)
+	return buffer
+}
+
+// ReconfigurationFromValue parses a reconfiguration from a committed value.
+// It returns nil if the value is not a valid reconfiguration transaction.
+func ReconfigurationFromValue(value []byte) *Reconfiguration {
+	if !bytes.HasPrefix(value, reconfigurationPrefix) ||
+		len(value) < len(reconfigurationPrefix)+10 {
+		return nil
+	}
+	index := len(reconfigurationPrefix)
+	sequence := encoding.Uint64(value[index:])
+	count := int(encoding.Uint16(value[index+8:]))
+	index += 10
+	r := &Reconfiguration{Sequence: sequence, Updates: make([]ValidatorUpdate, count)}
+	for i := range r.Updates {
+		if len(value) < index+11 {
+			return nil
+		}
+		r.Updates[i].ID = int(encoding.Uint16(value[index:]))
+		r.Updates[i].VotingPower = int64(encoding.Uint64(value[index+2:]))
+		keySize := int(value[index+10])
+		index += 11
+		if keySize > 0 {
+			if len(value) < index+keySize {
+				return nil
+			}
+			r.Updates[i].PublicKey = crypto.PublicKeyFromBytes(value[index : index+keySize])
+			if r.Updates[i].PublicKey == nil {
+				return nil
+			}
+			index += keySize
+		}
+	}
+	if len(value) < index+1 || len(value) != index+1+int(value[index]) {
+		return nil
+	}
+	if size := int(value[index]); size > 0 {
+		r.Signature = value[index+1 : index+1+size]
+	}
+	return r
+}
+
+// validValue returns whether the value of a proposed block, if known, can be
+// proposed in the epoch of the proposal, see ValidatorSet.ValidValue.
+func validValue(process Process, proposal *Message) bool {
+	block := proposal.Block
+	return !block.HasPayload() || process.Validators(proposal.Epoch).ValidValue(block.Value)
+}
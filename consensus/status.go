@@ -0,0 +1,59 @@
+package consensus
+
+// Names of the phases of an epoch, reported by its status.
+var phaseNames = map[int]string{
+	Inactive:    "inactive",
+	Ready:       "ready",
+	Locked:      "locked",
+	Commit:      "commit",
+	EpochChange: "epoch-change",
+	Finished:    "finished",
+}
+
+// Names of the types of certificates, reported by their status.
+var certificateNames = map[int16]string{
+	INVALID_CERT: "invalid",
+	SILENCE_CERT: "silence",
+	BLOCK_CERT:   "block",
+}
+
+// EpochStatus is a snapshot of the state of an epoch of consensus, used to
+// inspect running processes.
+type EpochStatus struct {
+	Epoch int64  `json:"epoch"`
+	Phase string `json:"phase"`
+	// Step of the round, with the tendermint model
+	Step string `json:"step,omitempty"`
+
+	Locked *CertificateStatus `json:"locked,omitempty"`
+}
+
+// CertificateStatus summarizes a certificate.
+type CertificateStatus struct {
+	Type    string  `json:"type"`
+	Epoch   int64   `json:"epoch"`
+	Height  int64   `json:"height"`
+	BlockID BlockID `json:"block,omitempty"`
+	Signers []int   `json:"signers"`
+}
+
+// NewCertificateStatus summarizes a certificate, which can be nil.
+func NewCertificateStatus(cert *Certificate) *CertificateStatus {
+	if cert == nil {
+		return nil
+	}
+	return &CertificateStatus{
+		Type:    certificateNames[cert.Type],
+		Epoch:   cert.Epoch,
+		Height:  cert.Height,
+		BlockID: cert.BlockID(),
+		Signers: cert.Signers(),
+	}
+}
+
+// Inspectable is implemented by the consensus instances reporting their
+// status.
+type Inspectable interface {
+	// Status returns a snapshot of the state of the instance.
+	Status() *EpochStatus
+}
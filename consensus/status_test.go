@@ -0,0 +1,28 @@
+package consensus
+
+import (
+	"testing"
+)
+
+func TestEpochStatus(t *testing.T) {
+	b := NewBlock(testRandValue(16), nil)
+	c := NewFastAlterBFT(MIN_EPOCH, nil, false)
+	status := c.Status()
+	if status.Epoch != MIN_EPOCH || status.Phase != "inactive" || status.Locked != nil {
+		t.Errorf("Unexpected status of an inactive epoch %+v", status)
+	}
+	c.epochPhase = Locked
+	c.lockedCertificate = testBlockCertificate(MIN_EPOCH, b, 3)
+	status = c.Status()
+	if status.Phase != "locked" || status.Locked == nil {
+		t.Fatalf("Unexpected status of a locked epoch %+v", status)
+	}
+	locked := status.Locked
+	if locked.Type != "block" || locked.Epoch != MIN_EPOCH || locked.Height != b.Height ||
+		!locked.BlockID.Equal(b.BlockID()) || len(locked.Signers) != 3 {
+		t.Errorf("Unexpected status of the locked certificate %+v", locked)
+	}
+	if NewCertificateStatus(testSilenceCertificate(MIN_EPOCH)).Type != "silence" {
+		t.Error("Expected status of a silence certificate")
+	}
+}
@@ -0,0 +1,523 @@
+package consensus
+
+import "fmt"
+
+// Timeouts of Tendermint, after the prevotes and precommits of more than two
+// thirds of the voting power are received.
+const (
+	TimeoutPrevote = TimeoutAttack + 1 + iota
+	TimeoutPrecommit
+)
+
+// Steps of an epoch (round) of Tendermint.
+const (
+	stepPropose = iota
+	stepPrevote
+	stepPrecommit
+)
+
+// Names of the steps, reported by the status of an epoch.
+var stepNames = map[int]string{
+	stepPropose:   "propose",
+	stepPrevote:   "prevote",
+	stepPrecommit: "precommit",
+}
+
+// TendermintState is the state of Tendermint that a process carries across
+// epochs, shared by its Tendermint instances.
+//
+// Each epoch is a round of the height following the last decided block. A
+// process locks on a block when it precommits it, and only prevotes blocks
+// conflicting with its lock if they are justified by prevotes of a later
+// epoch. The last block with prevotes of more than two thirds of the voting
+// power (a polka) is the valid block, proposed again by the next proposers.
+type TendermintState struct {
+	// Last block decided
+	Decided *Block
+
+	// Polkas of the locked and the valid blocks at the current height
+	Locked *Certificate
+	Valid  *Certificate
+
+	// Blocks proposed at the current height, by block ID
+	blocks map[string]*Block
+}
+
+// NewTendermintState creates the state of a process that has not decided any
+// block.
+func NewTendermintState() *TendermintState {
+	return &TendermintState{
+		blocks: make(map[string]*Block),
+	}
+}
+
+// height returns the height of the blocks proposed at the current height.
+func (s *TendermintState) height() int64 {
+	if s.Decided == nil {
+		return MIN_HEIGHT
+	}
+	return s.Decided.Height + 1
+}
+
+// addBlock records a proposed block, returning the one already recorded with
+// the same ID, if any.
+func (s *TendermintState) addBlock(block *Block) *Block {
+	if b := s.blocks[string(block.BlockID())]; b != nil {
+		return b
+	}
+	s.blocks[string(block.BlockID())] = block
+	return block
+}
+
+// decide moves to the height following a decided block, resetting the lock.
+func (s *TendermintState) decide(block *Block) {
+	s.Decided = block
+	s.Locked = nil
+	s.Valid = nil
+	for id, b := range s.blocks {
+		if b.Height <= block.Height {
+			delete(s.blocks, id)
+		}
+	}
+}
+
+// Tendermint implements an epoch of Tendermint, a round of the current height
+// in which processes prevote the proposal, precommit it once it gathers a
+// polka and decide it on precommits of more than two thirds of the voting
+// power. Prevotes for a block are votes carrying the proposer's signature,
+// so that polkas are block certificates; nil prevotes are silence messages.
+//
+// A process moves to the next epoch when it decides or, after the precommit
+// timeout, when it has received precommits of more than two thirds of the
+// voting power. It keeps processing precommits of the epoch to decide.
+type Tendermint struct {
+	Epoch   int64   // Consensus epoch identifier
+	Process Process // Auxiliary methods implementation
+
+	state *TendermintState
+
+	epochPhase int
+	step       int
+
+	// Whether the process has moved to the next epoch, and the proposal of
+	// the epoch, if valid
+	finished bool
+	proposal *Message
+	proposed bool
+
+	// Prevotes for blocks, including the proposer's, and of any kind
+	Prevotes    *CertificateSet
+	anyPrevotes *Certificate
+	nilPrevotes *Certificate
+	polka       bool
+
+	// Precommits for blocks and of any kind
+	Precommits    *CertificateSet
+	anyPrecommits *Certificate
+
+	scheduledTimeouts map[int16]bool
+
+	// Decided block, possibly waiting for its ancestors, and the blocks
+	// requested to decide it
+	decision *Certificate
+	requests *blockRequests
+
+	// Pending messages
+	messages []*Message
+}
+
+// NewTendermint creates an instance of Tendermint for the provided epoch,
+// with the state of the process.
+func NewTendermint(epoch int64, process Process, state *TendermintState) *Tendermint {
+	return &Tendermint{
+		Epoch:             epoch,
+		Process:           process,
+		state:             state,
+		epochPhase:        Inactive,
+		Prevotes:          NewCertificateSet(),
+		anyPrevotes:       NewSilenceCertificate(epoch),
+		nilPrevotes:       NewSilenceCertificate(epoch),
+		Precommits:        NewCertificateSet(),
+		anyPrecommits:     NewSilenceCertificate(epoch),
+		scheduledTimeouts: make(map[int16]bool),
+		requests:          newBlockRequests(),
+	}
+}
+
+// Start this epoch of consensus. The locked certificate is ignored, as it
+// is carried by the state of the process.
+func (c *Tendermint) Start(lockedCertificate *Certificate, sentLockedCertificate bool) {
+	c.epochPhase = Ready
+	c.step = stepPropose
+	if c.Process.Proposer(c.Epoch) == c.Process.ID() {
+		c.broadcastProposal()
+	}
+	c.scheduleTimeout(TimeoutPropose)
+	// Process messages that process received before starting an epoch.
+	for _, m := range c.messages {
+		c.ProcessMessage(m)
+	}
+	c.messages = nil
+}
+
+// Started informs whether this epoch has been started.
+func (c *Tendermint) Started() bool {
+	return c.epochPhase > Inactive
+}
+
+// Stop this instance of consensus.
+func (c *Tendermint) Stop() {
+	c.epochPhase = Finished
+}
+
+func (c *Tendermint) GetEpoch() int64 {
+	return c.Epoch
+}
+
+// Status returns the phase and step of this epoch and the polka on which
+// the process is locked.
+func (c *Tendermint) Status() *EpochStatus {
+	return &EpochStatus{
+		Epoch:  c.Epoch,
+		Phase:  phaseNames[c.epochPhase],
+		Step:   stepNames[c.step],
+		Locked: NewCertificateStatus(c.state.Locked),
+	}
+}
+
+// ProcessMessage processes a consensus message.
+//
+// Contract: message belongs to this epoch of consensus.
+func (c *Tendermint) ProcessMessage(message *Message) {
+	if c.epochPhase == Inactive {
+		c.messages = append(c.messages, message)
+		return
+	}
+	if c.epochPhase == Finished {
+		return
+	}
+	switch message.Type {
+	case PRECOMMIT:
+		c.processPrecommit(message)
+		return
+	case BLOCK_RESPONSE:
+		c.processBlockResponse(message)
+		return
+	}
+	// Only precommits are processed after moving to the next epoch
+	if c.finished {
+		return
+	}
+	switch message.Type {
+	case PROPOSE:
+		c.processProposal(message)
+	case VOTE:
+		c.processPrevote(message)
+	case SILENCE:
+		c.nilPrevotes.AddSignature(message.Signature, message.Sender)
+		c.anyPrevotes.AddSignature(message.Signature, message.Sender)
+		c.checkPrevotes()
+	}
+}
+
+func (c *Tendermint) processProposal(proposal *Message) {
+	if c.proposal != nil || !c.checkProposalValidity(proposal) {
+		return
+	}
+	block := c.state.addBlock(proposal.Block)
+	// Blocks proposed again are already in the blockchain
+	c.Process.AddBlock(block)
+	c.proposal = proposal
+	c.Process.Emit(&Event{
+		Type:    PROPOSAL_RECEIVED_EVENT,
+		Epoch:   c.Epoch,
+		Height:  block.Height,
+		BlockID: block.BlockID(),
+	})
+	c.addPrevote(proposal.Block.BlockID(), block.Height, proposal.Sender, proposal.Signature)
+	if c.step == stepPropose {
+		c.step = stepPrevote
+		if proposal.Sender != c.Process.ID() {
+			c.prevote(proposal)
+		}
+	}
+	c.checkPrevotes()
+	c.checkPrecommits()
+}
+
+// checkProposalValidity checks that a proposal is sent by the proposer and
+// extends the last decided block. A block proposed in a previous epoch must
+// be justified by a polka of an epoch not earlier than the block.
+func (c *Tendermint) checkProposalValidity(proposal *Message) bool {
+	block := proposal.Block
+	isFromProposer := proposal.Sender == c.Process.Proposer(proposal.Epoch)
+	extendsDecided := block.HasPayload() && block.Height == c.state.height() &&
+		block.Extend(c.state.Decided) && validValue(c.Process, proposal)
+	cert := proposal.Certificate
+	if cert == nil {
+		return isFromProposer && extendsDecided && block.Epoch == proposal.Epoch
+	}
+	isJustified := cert.Type == BLOCK_CERT && cert.BlockID().Equal(block.BlockID()) &&
+		cert.Epoch < proposal.Epoch && cert.Epoch >= block.Epoch &&
+		c.Process.Validators(cert.Epoch).IsByzantineQuorum(cert)
+	return isFromProposer && extendsDecided && isJustified
+}
+
+// prevote the proposal if it extends the locked block or is justified by a
+// polka not earlier than the lock, nil otherwise.
+func (c *Tendermint) prevote(proposal *Message) {
+	locked := c.state.Locked
+	cert := proposal.Certificate
+	if locked == nil || locked.BlockID().Equal(proposal.Block.BlockID()) ||
+		(cert != nil && cert.Epoch >= locked.Epoch) {
+		vote := NewVoteMessage(c.Epoch, proposal.Block.BlockID(), proposal.Block.Height,
+			int16(c.Process.ID()), int16(proposal.Sender))
+		vote.Signature2 = proposal.Signature
+		c.Process.Broadcast(vote)
+		c.Process.Emit(&Event{
+			Type:    VOTED_EVENT,
+			Epoch:   c.Epoch,
+			Height:  vote.Height,
+			BlockID: vote.BlockID,
+		})
+	} else {
+		c.Process.Broadcast(NewSilenceMessage(c.Epoch, int16(c.Process.ID())))
+	}
+}
+
+// processPrevote processes a prevote for a block, carrying the signature of
+// the proposal, which counts as the proposer's prevote.
+func (c *Tendermint) processPrevote(vote *Message) {
+	if vote.Sender2 != c.Process.Proposer(c.Epoch) {
+		return
+	}
+	c.addPrevote(vote.BlockID, vote.Height, vote.Sender2, vote.Signature2)
+	c.addPrevote(vote.BlockID, vote.Height, vote.Sender, vote.Signature)
+	c.checkPrevotes()
+}
+
+func (c *Tendermint) addPrevote(blockID BlockID, height int64, sender int, signature Signature) {
+	blockCert := c.Prevotes.Get(c.Epoch, blockID, height)
+	if blockCert == nil {
+		blockCert = NewBlockCertificate(c.Epoch, blockID, height)
+		c.Prevotes.Add(blockCert)
+	}
+	blockCert.AddSignature(signature, sender)
+	c.anyPrevotes.AddSignature(signature, sender)
+}
+
+// checkPrevotes precommits the proposal once it gathers a polka, nil if
+// enough processes prevote nil, and schedules the prevote timeout once
+// enough processes prevote.
+func (c *Tendermint) checkPrevotes() {
+	validators := c.Process.Validators(c.Epoch)
+	if c.step == stepPrevote && validators.IsByzantineQuorum(c.anyPrevotes) {
+		c.scheduleTimeout(TimeoutPrevote)
+	}
+	if c.proposal != nil && !c.polka && c.step >= stepPrevote {
+		polka := c.Prevotes.Get(c.Epoch, c.proposal.Block.BlockID(), c.proposal.Block.Height)
+		if polka != nil && validators.IsByzantineQuorum(polka) {
+			c.polka = true
+			c.Process.Emit(&Event{
+				Type:    CERTIFICATE_EVENT,
+				Epoch:   c.Epoch,
+				Height:  polka.Height,
+				BlockID: polka.BlockID(),
+			})
+			if c.step == stepPrevote {
+				c.state.Locked = polka
+				c.Process.Lock(c.Epoch, polka)
+				c.precommit(polka.BlockID(), polka.Height)
+			}
+			c.state.Valid = polka
+		}
+	}
+	if c.step == stepPrevote && validators.IsByzantineQuorum(c.nilPrevotes) {
+		c.precommit(nil, 0)
+	}
+}
+
+func (c *Tendermint) precommit(blockID BlockID, height int64) {
+	c.step = stepPrecommit
+	c.Process.Broadcast(NewPrecommitMessage(c.Epoch, blockID, height, c.Process.ID()))
+}
+
+func (c *Tendermint) processPrecommit(precommit *Message) {
+	if precommit.BlockID != nil {
+		blockCert := c.Precommits.Get(c.Epoch, precommit.BlockID, precommit.Height)
+		if blockCert == nil {
+			blockCert = NewBlockCertificate(c.Epoch, precommit.BlockID, precommit.Height)
+			c.Precommits.Add(blockCert)
+		}
+		blockCert.AddSignature(precommit.Signature, precommit.Sender)
+	}
+	c.anyPrecommits.AddSignature(precommit.Signature, precommit.Sender)
+	c.checkPrecommits()
+}
+
+// checkPrecommits decides a block once it gathers precommits of more than
+// two thirds of the voting power, and schedules the precommit timeout once
+// enough processes precommit.
+func (c *Tendermint) checkPrecommits() {
+	validators := c.Process.Validators(c.Epoch)
+	if !c.finished && validators.IsByzantineQuorum(c.anyPrecommits) {
+		c.scheduleTimeout(TimeoutPrecommit)
+	}
+	if c.decision != nil {
+		return
+	}
+	for _, blockCert := range c.Precommits.certificates {
+		if validators.IsByzantineQuorum(blockCert) {
+			c.decision = blockCert
+			c.tryToDecide()
+			return
+		}
+	}
+}
+
+// ProcessTimeout processes a timeout.
+//
+// Contract: timeout belongs to this instance (height) of consensus.
+func (c *Tendermint) ProcessTimeout(timeout *Timeout) {
+	if c.epochPhase == Finished || c.finished {
+		return
+	}
+	switch timeout.Type {
+	case TimeoutPropose:
+		if c.step == stepPropose {
+			c.step = stepPrevote
+			c.Process.Broadcast(NewSilenceMessage(c.Epoch, int16(c.Process.ID())))
+		}
+	case TimeoutPrevote:
+		if c.step == stepPrevote {
+			c.precommit(nil, 0)
+		}
+	case TimeoutPrecommit:
+		c.finish(false)
+	case TimeoutBlockRequest:
+		c.requests.retry(c.Process, c.Epoch)
+	}
+}
+
+// finish moves the process to the next epoch, once.
+func (c *Tendermint) finish(decided bool) {
+	if c.finished {
+		return
+	}
+	c.finished = true
+	event := &Event{
+		Type:    EPOCH_FINISHED_EVENT,
+		Epoch:   c.Epoch,
+		Locked:  c.polka,
+		Decided: decided,
+	}
+	if locked := c.state.Locked; locked != nil {
+		event.Height = locked.Height
+		event.BlockID = locked.BlockID()
+	}
+	c.Process.Emit(event)
+	c.Process.Finish(c.Epoch, c.state.Locked, true)
+}
+
+func (c *Tendermint) broadcastProposal() {
+	if c.proposed {
+		return
+	}
+	var block *Block
+	valid := c.state.Valid
+	if valid != nil {
+		block = c.state.blocks[string(valid.BlockID())]
+	}
+	if block == nil {
+		value := c.Process.GetValue()
+		if value == nil {
+			fmt.Printf("Generator returned nil in epoch %v\n", c.Epoch)
+			return
+		}
+		block = &Block{
+			Value:  value,
+			Height: c.state.height(),
+			Epoch:  c.Epoch,
+		}
+		if c.state.Decided != nil {
+			block.PrevBlockID = c.state.Decided.BlockID()
+		}
+		valid = nil
+	}
+	c.proposed = true
+	proposal := &Message{
+		Type:        PROPOSE,
+		Epoch:       c.Epoch,
+		Block:       block,
+		Certificate: valid,
+		Sender:      c.Process.ID(),
+		SenderFwd:   c.Process.ID(),
+	}
+	c.Process.Broadcast(proposal)
+}
+
+// tryToDecide decides the block with precommits of more than two thirds of
+// the voting power once it and its ancestors are known. Blocks of heights
+// already decided are ignored.
+func (c *Tendermint) tryToDecide() {
+	cert := c.decision
+	if cert == nil || cert.Height < c.state.height() {
+		return
+	}
+	block := c.state.blocks[string(cert.BlockID())]
+	if block == nil {
+		c.requestBlock(cert.Height, cert.BlockID())
+		return
+	}
+	if c.Process.ExtendValidChain(block) {
+		c.state.decide(block)
+		c.Process.Emit(&Event{
+			Type:    DECIDED_EVENT,
+			Epoch:   c.Epoch,
+			Height:  block.Height,
+			BlockID: block.BlockID(),
+		})
+		c.Process.Decide(c.Epoch, block)
+		c.finish(true)
+	} else if height, blockID := c.Process.MissingAncestor(block); blockID != nil {
+		c.requestBlock(height, blockID)
+	}
+}
+
+// requestBlock requests a block missing to decide from the other members.
+// A block is requested again if not received.
+func (c *Tendermint) requestBlock(height int64, blockID BlockID) {
+	c.requests.send(c.Process, c.Epoch, height, blockID)
+}
+
+// processBlockResponse processes a block requested to decide.
+func (c *Tendermint) processBlockResponse(response *Message) {
+	block := response.Block
+	if !c.requests.received(block.BlockID()) {
+		return
+	}
+	if block.BlockID().Equal(c.decision.BlockID()) {
+		block = c.state.addBlock(block)
+	}
+	c.Process.AddBlock(block)
+	c.tryToDecide()
+}
+
+// Schedule a timeout of the epoch, once. The propose timeout bounds the time
+// to receive the proposal, the others the time to receive the prevotes and
+// precommits of the other processes.
+func (c *Tendermint) scheduleTimeout(timeoutType int16) {
+	if c.scheduledTimeouts[timeoutType] {
+		return
+	}
+	c.scheduledTimeouts[timeoutType] = true
+	duration := c.Process.TimeoutEpochChange(c.Epoch)
+	if timeoutType == TimeoutPropose {
+		duration = c.Process.TimeoutPropose(c.Epoch)
+	}
+	c.Process.Schedule(&Timeout{
+		Type:     timeoutType,
+		Epoch:    c.Epoch,
+		Duration: duration,
+	})
+}
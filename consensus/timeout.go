@@ -1,7 +1,9 @@
 package consensus
 
 import (
-	"log"
+	"container/heap"
+	"context"
+	"sync"
 	"time"
 )
 
@@ -21,87 +23,255 @@ type Timeout struct {
 	deadline time.Time
 }
 
+// TimeoutHandle refers to a timeout scheduled in a TimeoutQueue.
+type TimeoutHandle struct {
+	Timeout *Timeout
+
+	queue *TimeoutQueue
+	seq   uint64
+	// Position in the queue, -1 once triggered or canceled
+	index int
+}
+
+// Cancel removes the timeout from its queue, so that it is not triggered.
+// Returns false if the timeout was already triggered or canceled.
+func (h *TimeoutHandle) Cancel() bool {
+	if h.queue.lock != nil {
+		h.queue.lock.Lock()
+		defer h.queue.lock.Unlock()
+	}
+	return h.queue.remove(h)
+}
+
+// TimeoutQueue is a min-heap of scheduled timeouts, ordered by deadline and
+// then by the order in which they were scheduled.
+//
+// Timeouts are triggered by the owner of the queue, which provides the
+// current time, so that it can be driven by a real or a virtual clock.
+type TimeoutQueue struct {
+	handles []*TimeoutHandle
+	seq     uint64
+	// Lock held by handles when canceling timeouts, if set
+	lock sync.Locker
+}
+
+// NewTimeoutQueue returns an empty queue.
+func NewTimeoutQueue() *TimeoutQueue {
+	return &TimeoutQueue{}
+}
+
+// Len returns the number of scheduled timeouts.
+func (q *TimeoutQueue) Len() int {
+	return len(q.handles)
+}
+
+// Push schedules a timeout to be triggered at the provided deadline.
+func (q *TimeoutQueue) Push(timeout *Timeout, deadline time.Time) *TimeoutHandle {
+	timeout.deadline = deadline
+	q.seq++
+	h := &TimeoutHandle{Timeout: timeout, queue: q, seq: q.seq}
+	heap.Push((*timeoutHeap)(q), h)
+	return h
+}
+
+// Next returns the deadline of the next timeout to trigger, if any.
+func (q *TimeoutQueue) Next() (time.Time, bool) {
+	if len(q.handles) == 0 {
+		return time.Time{}, false
+	}
+	return q.handles[0].Timeout.deadline, true
+}
+
+// Expired removes and returns the timeouts whose deadline is reached, in
+// the order they are triggered.
+func (q *TimeoutQueue) Expired(now time.Time) []*Timeout {
+	var expired []*Timeout
+	for len(q.handles) > 0 && !q.handles[0].Timeout.deadline.After(now) {
+		h := heap.Pop((*timeoutHeap)(q)).(*TimeoutHandle)
+		expired = append(expired, h.Timeout)
+	}
+	return expired
+}
+
+// CancelEpochs cancels the timeouts of epochs up to the provided one.
+// Returns the number of canceled timeouts.
+func (q *TimeoutQueue) CancelEpochs(epoch int64) int {
+	var canceled []*TimeoutHandle
+	for _, h := range q.handles {
+		if h.Timeout.Epoch <= epoch {
+			canceled = append(canceled, h)
+		}
+	}
+	for _, h := range canceled {
+		q.remove(h)
+	}
+	return len(canceled)
+}
+
+func (q *TimeoutQueue) remove(h *TimeoutHandle) bool {
+	if h.index < 0 {
+		return false
+	}
+	heap.Remove((*timeoutHeap)(q), h.index)
+	return true
+}
+
+// timeoutHeap implements heap.Interface for TimeoutQueue.
+type timeoutHeap TimeoutQueue
+
+func (q *timeoutHeap) Len() int { return len(q.handles) }
+
+func (q *timeoutHeap) Less(i, j int) bool {
+	a, b := q.handles[i], q.handles[j]
+	if !a.Timeout.deadline.Equal(b.Timeout.deadline) {
+		return a.Timeout.deadline.Before(b.Timeout.deadline)
+	}
+	return a.seq < b.seq
+}
+
+func (q *timeoutHeap) Swap(i, j int) {
+	q.handles[i], q.handles[j] = q.handles[j], q.handles[i]
+	q.handles[i].index = i
+	q.handles[j].index = j
+}
+
+func (q *timeoutHeap) Push(x interface{}) {
+	h := x.(*TimeoutHandle)
+	h.index = len(q.handles)
+	q.handles = append(q.handles, h)
+}
+
+func (q *timeoutHeap) Pop() interface{} {
+	old := q.handles
+	n := len(old)
+	h := old[n-1]
+	old[n-1] = nil
+	h.index = -1
+	q.handles = old[:n-1]
+	return h
+}
+
 // TimeoutTicker is a timer that schedules and triggers timeouts.
+//
+// Triggered timeouts are output in Out, in the order of their deadlines.
+// If Out is full, they are kept until they can be output, never dropped.
+//
+// A ticker is single-use: once stopped, it cannot be started again.
 type TimeoutTicker struct {
-	In  chan *Timeout
 	Out chan *Timeout
 
-	timer    *time.Timer
-	timeouts []*Timeout
+	clock Clock
+	mutex sync.Mutex
+	queue *TimeoutQueue
+	// Signals the ticker thread that the next deadline may have changed
+	wake chan struct{}
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started sync.Once
+	done    chan struct{}
 }
 
-// NewTimeoutTicker returns an instance of Timeout Ticker.
+// NewTimeoutTicker returns an instance of Timeout Ticker, driven by the
+// system clock.
 func NewTimeoutTicker() *TimeoutTicker {
+	return NewTimeoutTickerWithClock(SystemClock{})
+}
+
+// NewTimeoutTickerWithClock returns an instance of Timeout Ticker, driven by
+// the provided clock.
+func NewTimeoutTickerWithClock(clock Clock) *TimeoutTicker {
 	t := &TimeoutTicker{
-		In:    make(chan *Timeout, 100),
 		Out:   make(chan *Timeout, 100),
-		timer: time.NewTimer(0),
+		clock: clock,
+		queue: NewTimeoutQueue(),
+		wake:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
 	}
+	t.queue.lock = &t.mutex
+	t.ctx, t.cancel = context.WithCancel(context.Background())
 	return t
 }
 
-// Start the timeout scheduling thread in background.
-func (t *TimeoutTicker) Start() {
-	go t.run()
+// Schedule a timeout, to be triggered after its duration.
+// The returned handle can be used to cancel the timeout.
+// This method can be invoked by any routine.
+func (t *TimeoutTicker) Schedule(timeout *Timeout) *TimeoutHandle {
+	t.mutex.Lock()
+	deadline := timeout.deadline
+	if deadline.IsZero() {
+		deadline = t.clock.Now().Add(timeout.Duration)
+	}
+	h := t.queue.Push(timeout, deadline)
+	t.mutex.Unlock()
+	// Do not block, a wake up is already pending
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+	return h
 }
 
-func (t *TimeoutTicker) resetTimer() time.Time {
-	// Next deadline is the lowest scheduled deadline
-	now := time.Now()
-	nextDeadline := now.Add(time.Minute)
-	for _, ti := range t.timeouts {
-		if ti != nil && ti.deadline.Before(nextDeadline) {
-			nextDeadline = ti.deadline
-		}
-	}
+// CancelEpochs cancels the scheduled timeouts of epochs up to the provided
+// one. Timeouts already triggered, but not yet output, are still output.
+// This method can be invoked by any routine.
+func (t *TimeoutTicker) CancelEpochs(epoch int64) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.queue.CancelEpochs(epoch)
+}
 
-	// Stop existing timer and set it to nextDeadline
-	if !t.timer.Stop() {
-		select {
-		case <-t.timer.C:
-		default: // Should not block
-		}
+// Start the timeout scheduling thread in background.
+// The thread is only started in the first time this method is invoked, and
+// never after Stop: starting a stopped ticker has no effect.
+func (t *TimeoutTicker) Start() {
+	t.started.Do(func() {
+		go t.run()
+	})
+}
 
-	}
-	t.timer.Reset(nextDeadline.Sub(now))
-	return nextDeadline
+// Stop the timeout scheduling thread, waiting for it to return if started.
+// Scheduled timeouts are not triggered after this method returns, the ticker
+// cannot be started again.
+func (t *TimeoutTicker) Stop() {
+	t.cancel()
+	t.started.Do(func() {
+		close(t.done)
+	})
+	<-t.done
 }
 
 func (t *TimeoutTicker) run() {
-	nextDeadline := t.resetTimer()
+	defer close(t.done)
+	timer := t.clock.NewTimer()
+	defer timer.Stop()
+	// Triggered timeouts not yet output
+	var triggered []*Timeout
 	for {
+		t.mutex.Lock()
+		triggered = append(triggered, t.queue.Expired(t.clock.Now())...)
+		next, scheduled := t.queue.Next()
+		t.mutex.Unlock()
+		if scheduled {
+			timer.ResetAt(next)
+		} else {
+			timer.Stop()
+		}
+
+		var out chan *Timeout
+		var timeout *Timeout
+		if len(triggered) > 0 {
+			out = t.Out
+			timeout = triggered[0]
+		}
 		select {
-		// Scheduled timeout
-		case ti := <-t.In:
-			if ti.deadline.IsZero() {
-				ti.deadline = time.Now().Add(ti.Duration)
-			}
-			t.timeouts = append(t.timeouts, ti)
-			if ti.deadline.Before(nextDeadline) {
-				nextDeadline = t.resetTimer()
-			}
-
-		// Timer has expired
-		case now := <-t.timer.C:
-			// Trigger expired timeouts
-			for i := range t.timeouts {
-				if t.timeouts[i] == nil ||
-					t.timeouts[i].deadline.After(now) {
-					continue
-				}
-				// FIXME: we should not block here
-				select {
-				case t.Out <- t.timeouts[i]:
-				default:
-					log.Println("failed to publish timeout", t.timeouts[i])
-				}
-				t.timeouts[i] = nil
-			}
-			// Clean up prefix of nil (i.e., trigged) timeouts
-			for len(t.timeouts) > 0 && t.timeouts[0] == nil {
-				t.timeouts = t.timeouts[1:]
-			}
-			nextDeadline = t.resetTimer()
+		case <-t.ctx.Done():
+			return
+		case out <- timeout:
+			triggered[0] = nil
+			triggered = triggered[1:]
+		case <-timer.C():
+		case <-t.wake:
 		}
 	}
 }
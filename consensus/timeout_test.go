@@ -26,8 +26,8 @@ func TestTimeoutTicker(t *testing.T) {
 	// Two subsequent timestamps
 	ts1 := &Timeout{TimeoutPropose, 3, 10 * time.Millisecond, time.Time{}}
 	ts2 := &Timeout{TimeoutEquivocation, 3, 15 * time.Millisecond, time.Time{}}
-	tt.In <- ts1
-	tt.In <- ts2
+	tt.Schedule(ts1)
+	tt.Schedule(ts2)
 
 	select {
 	case tts := <-tt.Out:
@@ -58,8 +58,8 @@ func TestTimeoutTicker(t *testing.T) {
 	// Two timestamps, the second should trigger before the first
 	ts1 = &Timeout{TimeoutPropose, 3, 20 * time.Millisecond, time.Time{}}
 	ts2 = &Timeout{TimeoutPropose, 3, 10 * time.Millisecond, time.Time{}}
-	tt.In <- ts1
-	tt.In <- ts2
+	tt.Schedule(ts1)
+	tt.Schedule(ts2)
 
 	select {
 	case tts := <-tt.Out:
@@ -87,3 +87,120 @@ func TestTimeoutTicker(t *testing.T) {
 		t.Error("Expected timeout at", ts1.deadline, "nothing by", time.Now())
 	}
 }
+
+func TestTimeoutTickerStop(t *testing.T) {
+	tt := NewTimeoutTicker()
+	tt.Start()
+	tt.Schedule(&Timeout{TimeoutPropose, 3, 10 * time.Millisecond, time.Time{}})
+	tt.Stop()
+	// Stopping twice is harmless
+	tt.Stop()
+	select {
+	case tts := <-tt.Out:
+		t.Error("Unexpected timeout after stop", tts)
+	case <-time.After(20 * time.Millisecond):
+	}
+	// A stopped ticker is not started again
+	tt.Start()
+	tt.Schedule(&Timeout{TimeoutPropose, 4, time.Millisecond, time.Time{}})
+	select {
+	case tts := <-tt.Out:
+		t.Error("Unexpected timeout after restart", tts)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// A ticker that was never started can be stopped
+	tt = NewTimeoutTicker()
+	tt.Stop()
+	tt.Start()
+}
+
+func TestTimeoutQueue(t *testing.T) {
+	q := NewTimeoutQueue()
+	t0 := time.Unix(0, 0)
+	ms := time.Millisecond
+	h1 := q.Push(&Timeout{Type: TimeoutPropose, Epoch: 1}, t0.Add(20*ms))
+	q.Push(&Timeout{Type: TimeoutEquivocation, Epoch: 1}, t0.Add(10*ms))
+	q.Push(&Timeout{Type: TimeoutQuitEpoch, Epoch: 1}, t0.Add(10*ms))
+	q.Push(&Timeout{Type: TimeoutPropose, Epoch: 2}, t0.Add(30*ms))
+	q.Push(&Timeout{Type: TimeoutPropose, Epoch: 3}, t0.Add(40*ms))
+
+	if next, ok := q.Next(); !ok || !next.Equal(t0.Add(10*ms)) {
+		t.Error("Expected next deadline", t0.Add(10*ms), "got", next, ok)
+	}
+	// Timeouts with the same deadline are triggered in FIFO order
+	expired := q.Expired(t0.Add(15 * ms))
+	if len(expired) != 2 || expired[0].Type != TimeoutEquivocation || expired[1].Type != TimeoutQuitEpoch {
+		t.Error("Unexpected expired timeouts", expired)
+	}
+	if !h1.Cancel() || h1.Cancel() {
+		t.Error("Expected timeout to be canceled once")
+	}
+	if n := q.CancelEpochs(2); n != 1 || q.Len() != 1 {
+		t.Error("Expected to cancel 1 timeout, canceled", n, "left", q.Len())
+	}
+	expired = q.Expired(t0.Add(time.Second))
+	if len(expired) != 1 || expired[0].Epoch != 3 {
+		t.Error("Unexpected expired timeouts", expired)
+	}
+	if _, ok := q.Next(); ok || q.Len() != 0 {
+		t.Error("Expected empty queue")
+	}
+}
+
+func TestTimeoutTickerManualClock(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	tt := NewTimeoutTickerWithClock(clock)
+	tt.Out = make(chan *Timeout, 1)
+	tt.Start()
+	defer tt.Stop()
+	ms := time.Millisecond
+
+	ts1 := tt.Schedule(&Timeout{TimeoutPropose, 1, 10 * ms, time.Time{}})
+	ts2 := tt.Schedule(&Timeout{TimeoutEquivocation, 1, 20 * ms, time.Time{}})
+	ts3 := tt.Schedule(&Timeout{TimeoutPropose, 2, 30 * ms, time.Time{}})
+	ts4 := tt.Schedule(&Timeout{TimeoutPropose, 3, 40 * ms, time.Time{}})
+	tt.Schedule(&Timeout{TimeoutPropose, 4, 50 * ms, time.Time{}})
+	assertNoTimeout(t, tt)
+
+	// Timeouts exceeding the capacity of Out are not dropped
+	clock.Advance(25 * ms)
+	assertTimeoutEquals(t, receiveTimeout(t, tt), ts1.Timeout)
+	assertTimeoutEquals(t, receiveTimeout(t, tt), ts2.Timeout)
+	assertNoTimeout(t, tt)
+
+	// Canceled timeouts are not triggered
+	if !ts4.Cancel() {
+		t.Error("Expected to cancel timeout", ts4.Timeout)
+	}
+	if n := tt.CancelEpochs(2); n != 1 {
+		t.Error("Expected to cancel 1 timeout, canceled", n)
+	}
+	if ts3.Cancel() {
+		t.Error("Unexpected to cancel timeout twice", ts3.Timeout)
+	}
+	clock.Advance(20 * ms)
+	assertNoTimeout(t, tt)
+	clock.Advance(10 * ms)
+	assertTimeoutEquals(t, receiveTimeout(t, tt), &Timeout{Type: TimeoutPropose, Epoch: 4})
+}
+
+func receiveTimeout(t *testing.T, tt *TimeoutTicker) *Timeout {
+	t.Helper()
+	select {
+	case timeout := <-tt.Out:
+		return timeout
+	case <-time.After(time.Second):
+		t.Error("Expected timeout, nothing in 1s")
+		return nil
+	}
+}
+
+func assertNoTimeout(t *testing.T, tt *TimeoutTicker) {
+	t.Helper()
+	select {
+	case timeout := <-tt.Out:
+		t.Error("Unexpected timeout", timeout)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -0,0 +1,365 @@
+package consensus
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"dslab.inf.usi.ch/tendermint/crypto"
+)
+
+// ValidatorSet defines the processes taking part in consensus in a range of
+// epochs, with their voting power and public keys.
+//
+// Processes are identified by their IDs, which are not required to be
+// contiguous. A validator set is never modified, reconfigurations produce
+// new validator sets.
+type ValidatorSet struct {
+	// First epoch in which the validator set is in effect.
+	Epoch int64
+
+	// Member IDs, in increasing order
+	members []int
+
+	// Voting power and keys, indexed by process ID
+	votingPower []int64
+	keys        []crypto.PublicKey
+
+	// Key authorising reconfigurations, if any, and the sequence number of
+	// the last reconfiguration applied
+	operator crypto.PublicKey
+	sequence uint64
+
+	quorum QuorumRule
+}
+
+// NewValidatorSet creates the validator set with processes from 0 to
+// numProcesses-1. If votingPower is empty, every process has voting power 1,
+// otherwise processes with no voting power are not members of the set.
+// Voting power is capped to MaxVotingPower.
+// Keys can be nil, when signatures are not verified.
+func NewValidatorSet(epoch int64, numProcesses int, votingPower []int64, keys []crypto.PublicKey) *ValidatorSet {
+	power := make([]int64, numProcesses)
+	for id := range power {
+		power[id] = 1
+		if len(votingPower) > 0 {
+			power[id] = votingPower[id]
+		}
+	}
+	return newValidatorSet(epoch, power, keys)
+}
+
+func newValidatorSet(epoch int64, votingPower []int64, keys []crypto.PublicKey) *ValidatorSet {
+	vs := &ValidatorSet{
+		Epoch:       epoch,
+		votingPower: votingPower,
+		keys:        make([]crypto.PublicKey, len(votingPower)),
+	}
+	for id, power := range votingPower {
+		if power <= 0 {
+			vs.votingPower[id] = 0
+			continue
+		}
+		if power > MaxVotingPower {
+			vs.votingPower[id] = MaxVotingPower
+		}
+		vs.members = append(vs.members, id)
+		if id < len(keys) {
+			vs.keys[id] = keys[id]
+		}
+	}
+	vs.quorum = NewQuorumRule(len(votingPower), vs.votingPower)
+	return vs
+}
+
+// Size returns the number of members of the validator set.
+func (vs *ValidatorSet) Size() int {
+	return len(vs.members)
+}
+
+// Members returns the IDs of the members in increasing order.
+func (vs *ValidatorSet) Members() []int {
+	return vs.members
+}
+
+// Member returns the ID of the member with the provided rank, modulo the
+// number of members.
+func (vs *ValidatorSet) Member(rank int) int {
+	if len(vs.members) == 0 {
+		return -1
+	}
+	return vs.members[rank%len(vs.members)]
+}
+
+// Contains returns whether a process is a member of the validator set.
+func (vs *ValidatorSet) Contains(id int) bool {
+	return vs.VotingPower(id) > 0
+}
+
+// VotingPower returns the voting power of a process, 0 if not a member.
+func (vs *ValidatorSet) VotingPower(id int) int64 {
+	return vs.quorum.VotingPower(id)
+}
+
+// PublicKey returns the key of a member, nil if unknown.
+func (vs *ValidatorSet) PublicKey(id int) crypto.PublicKey {
+	if !vs.Contains(id) {
+		return nil
+	}
+	return vs.keys[id]
+}
+
+// PublicKeys returns the keys of the members, indexed by process ID.
+// The keys of processes that are not members are nil.
+func (vs *ValidatorSet) PublicKeys() []crypto.PublicKey {
+	return vs.keys
+}
+
+// QuorumRule returns the rule defining when certificates are complete.
+func (vs *ValidatorSet) QuorumRule() QuorumRule {
+	return vs.quorum
+}
+
+// IsQuorum returns whether the signers of a certificate are a quorum.
+func (vs *ValidatorSet) IsQuorum(c *Certificate) bool {
+	return vs.quorum.IsQuorum(c)
+}
+
+// IsByzantineQuorum returns whether the signers of a certificate hold more
+// than two thirds of the voting power, the quorums of protocols that, as
+// HotStuff, tolerate less than a third of byzantine power.
+func (vs *ValidatorSet) IsByzantineQuorum(c *Certificate) bool {
+	var total int64
+	for _, power := range vs.votingPower {
+		total += power
+	}
+	return 3*c.Weight(vs.quorum) > 2*total
+}
+
+// ValidateCertificate checks that a certificate is well-formed and signed by
+// a quorum of the validator set, see Certificate.Validate.
+func (vs *ValidatorSet) ValidateCertificate(c *Certificate) error {
+	return c.Validate(len(vs.votingPower), vs.quorum)
+}
+
+// IsUnanimous returns whether every member signed a certificate.
+func (vs *ValidatorSet) IsUnanimous(c *Certificate) bool {
+	return vs.quorum.IsUnanimous(c)
+}
+
+// WithOperator returns a copy of the validator set whose reconfigurations
+// are authorised by the provided key. Without an operator, reconfigurations
+// are not authorised.
+func (vs *ValidatorSet) WithOperator(operator crypto.PublicKey) *ValidatorSet {
+	vvs := *vs
+	vvs.operator = operator
+	return &vvs
+}
+
+// Authorizes returns whether a reconfiguration can be applied to the
+// validator set: it is signed by the operator and it was not applied before.
+func (vs *ValidatorSet) Authorizes(r *Reconfiguration) bool {
+	return r.Sequence > vs.sequence && r.Verify(vs.operator)
+}
+
+// ValidValue returns whether a value can be proposed with this validator set.
+// Values carrying a reconfiguration must be authorised by the operator.
+func (vs *ValidatorSet) ValidValue(value []byte) bool {
+	if !bytes.HasPrefix(value, reconfigurationPrefix) {
+		return true
+	}
+	r := ReconfigurationFromValue(value)
+	return r != nil && vs.Authorizes(r)
+}
+
+// Apply returns the validator set, in effect from the provided epoch, that
+// results from applying a list of updates to this set. The operator of the
+// set is retained.
+func (vs *ValidatorSet) Apply(epoch int64, updates []ValidatorUpdate) *ValidatorSet {
+	size := len(vs.votingPower)
+	for _, u := range updates {
+		if u.ID >= size {
+			size = u.ID + 1
+		}
+	}
+	power := make([]int64, size)
+	keys := make([]crypto.PublicKey, size)
+	copy(power, vs.votingPower)
+	copy(keys, vs.keys)
+	for _, u := range updates {
+		if u.ID < 0 {
+			continue
+		}
+		power[u.ID] = u.VotingPower
+		if u.PublicKey != nil {
+			keys[u.ID] = u.PublicKey
+		}
+	}
+	set := newValidatorSet(epoch, power, keys)
+	set.operator = vs.operator
+	set.sequence = vs.sequence
+	return set
+}
+
+// Equal checks whether two validator sets have the same members, with the
+// same voting power and keys.
+func (vs *ValidatorSet) Equal(vvs *ValidatorSet) bool {
+	if vs == nil || vvs == nil {
+		return vs == vvs
+	}
+	if len(vs.members) != len(vvs.members) {
+		return false
+	}
+	for i, id := range vs.members {
+		if vvs.members[i] != id || vvs.votingPower[id] != vs.votingPower[id] {
+			return false
+		}
+		k1, k2 := vs.keys[id], vvs.keys[id]
+		if (k1 == nil) != (k2 == nil) || (k1 != nil && !k1.Equals(k2)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Marshall serialises the validator set as its epoch followed by the
+// reconfiguration adding all its members to an empty set, with the sequence
+// number of the last reconfiguration applied. The operator is not included.
+func (vs *ValidatorSet) Marshall() []byte {
+	r := &Reconfiguration{Sequence: vs.sequence}
+	for _, id := range vs.members {
+		r.Updates = append(r.Updates, ValidatorUpdate{
+			ID:          id,
+			VotingPower: vs.votingPower[id],
+			PublicKey:   vs.keys[id],
+		})
+	}
+	buffer := make([]byte, 8+r.ByteSize())
+	encoding.PutUint64(buffer, uint64(vs.Epoch))
+	copy(buffer[8:], r.Marshall())
+	return buffer
+}
+
+// ValidatorSetFromBytes parses a validator set from a byte array, nil if
+// the encoding is invalid.
+func ValidatorSetFromBytes(buffer []byte) *ValidatorSet {
+	if len(buffer) < 8 {
+		return nil
+	}
+	r := ReconfigurationFromValue(buffer[8:])
+	if r == nil {
+		return nil
+	}
+	empty := newValidatorSet(0, nil, nil)
+	set := empty.Apply(int64(encoding.Uint64(buffer)), r.Updates)
+	set.sequence = r.Sequence
+	return set
+}
+
+// Membership tracks the validator set in effect in each epoch.
+//
+// Validator sets change through reconfiguration transactions committed in
+// blocks. A reconfiguration committed in a block proposed in epoch e takes
+// effect in epoch e+lag, where lag is the maximum number of active epochs.
+// As with leader policies, a process starts epoch e+lag only after
+// committing every block proposed up to epoch e, so that all processes
+// derive the same validator set for the epochs they run.
+//
+// Membership is safe for concurrent use, as the validator sets are read by
+// the routines verifying signatures of received messages.
+type Membership struct {
+	mutex sync.RWMutex
+	lag   int64
+
+	// Validator sets in increasing order of epochs
+	sets []*ValidatorSet
+}
+
+// NewMembership creates a membership with the initial validator set.
+func NewMembership(initial *ValidatorSet, lag int64) *Membership {
+	if lag < 1 {
+		lag = 1
+	}
+	return &Membership{
+		lag:  lag,
+		sets: []*ValidatorSet{initial},
+	}
+}
+
+// At returns the validator set in effect in an epoch.
+// The oldest known validator set is returned for pruned epochs.
+func (m *Membership) At(epoch int64) *ValidatorSet {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.sets[m.index(epoch)]
+}
+
+// Index of the last validator set in effect before or in epoch, 0 if none.
+func (m *Membership) index(epoch int64) int {
+	i := sort.Search(len(m.sets), func(i int) bool {
+		return m.sets[i].Epoch > epoch
+	}) - 1
+	if i < 0 {
+		return 0
+	}
+	return i
+}
+
+// Commit applies the reconfiguration carried by a committed block, if any
+// and if authorised by the operator of the last validator set.
+// It returns the new validator set, or nil if the block does not change it.
+// Contract: blocks are committed in height order.
+func (m *Membership) Commit(block *Block) *ValidatorSet {
+	r := ReconfigurationFromValue(block.Value)
+	if r == nil {
+		return nil
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	epoch := block.Epoch + m.lag
+	last := m.sets[len(m.sets)-1]
+	if !last.Authorizes(r) {
+		return nil
+	}
+	set := last.Apply(epoch, r.Updates)
+	set.sequence = r.Sequence
+	if set.Equal(last) {
+		return nil
+	}
+	// Reconfigurations committed in the same epoch are merged
+	if last.Epoch == epoch {
+		m.sets[len(m.sets)-1] = set
+	} else {
+		m.sets = append(m.sets, set)
+	}
+	return set
+}
+
+// Restore replaces the validator sets in effect from the epoch of the first
+// provided set, e.g., with the ones recovered from the write-ahead log.
+// The restored sets keep the operator of the initial set.
+func (m *Membership) Restore(sets []*ValidatorSet) {
+	if len(sets) == 0 {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	operator := m.sets[0].operator
+	for i, set := range sets {
+		sets[i] = set.WithOperator(operator)
+	}
+	i := sort.Search(len(m.sets), func(i int) bool {
+		return m.sets[i].Epoch >= sets[0].Epoch
+	})
+	m.sets = append(m.sets[:i], sets...)
+}
+
+// Prune discards the validator sets that are no longer in effect in the
+// provided epoch, nor in the following ones.
+func (m *Membership) Prune(epoch int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if i := m.index(epoch); i > 0 {
+		m.sets = append([]*ValidatorSet(nil), m.sets[i:]...)
+	}
+}
@@ -0,0 +1,217 @@
+package consensus
+
+import (
+	"testing"
+
+	"dslab.inf.usi.ch/tendermint/crypto"
+)
+
+func testMembership(n int) *Membership {
+	return NewMembership(NewValidatorSet(MIN_EPOCH, n, nil, nil), 1)
+}
+
+// Operator authorising the reconfigurations of the tests
+var testOperator = crypto.GeneratePrivateKey()
+
+// testReconfigurationBlock returns a block proposed in an epoch carrying a
+// reconfiguration, signed by the test operator with the epoch as sequence
+// number.
+func testReconfigurationBlock(epoch int64, prev *Block, updates ...ValidatorUpdate) *Block {
+	r := &Reconfiguration{Sequence: uint64(epoch), Updates: updates}
+	r.Sign(testOperator)
+	b := NewBlock(r.Marshall(), prev)
+	b.Epoch = epoch
+	return b
+}
+
+func TestReconfigurationMarshalling(t *testing.T) {
+	key := crypto.GeneratePrivateKey().PubKey()
+	r := &Reconfiguration{Sequence: 7, Updates: []ValidatorUpdate{
+		{ID: 4, VotingPower: 3, PublicKey: key},
+		{ID: 1, VotingPower: 0},
+	}}
+	r.Sign(testOperator)
+	rr := ReconfigurationFromValue(r.Marshall())
+	if rr == nil || len(rr.Updates) != 2 || rr.Sequence != 7 {
+		t.Fatal("Expected reconfiguration 7 with 2 updates, got", rr)
+	}
+	if !rr.Verify(testOperator.PubKey()) {
+		t.Error("Expected unmarshalled reconfiguration to be signed by the operator")
+	}
+	if u := rr.Updates[0]; u.ID != 4 || u.VotingPower != 3 || u.PublicKey == nil || !u.PublicKey.Equals(key) {
+		t.Error("Unexpected update", u)
+	}
+	if u := rr.Updates[1]; u.ID != 1 || u.VotingPower != 0 || u.PublicKey != nil {
+		t.Error("Unexpected update", u)
+	}
+	if ReconfigurationFromValue(testRandValue(64)) != nil {
+		t.Error("Expected random value not to be a reconfiguration")
+	}
+	if ReconfigurationFromValue(r.Marshall()[:30]) != nil {
+		t.Error("Expected truncated reconfiguration to be invalid")
+	}
+}
+
+func TestValidatorSetApply(t *testing.T) {
+	keys := make([]crypto.PublicKey, 5)
+	for id := range keys {
+		keys[id] = crypto.GeneratePrivateKey().PubKey()
+	}
+	vs := NewValidatorSet(MIN_EPOCH, 4, nil, keys)
+	if vs.Size() != 4 || vs.Contains(4) || vs.PublicKey(4) != nil {
+		t.Error("Expected members 0 to 3, got", vs.Members())
+	}
+	rotated := crypto.GeneratePrivateKey().PubKey()
+	next := vs.Apply(5, []ValidatorUpdate{
+		{ID: 4, VotingPower: 1, PublicKey: keys[4]},
+		{ID: 1, VotingPower: 0},
+		{ID: 2, VotingPower: 2, PublicKey: rotated},
+	})
+	if next.Epoch != 5 || next.Size() != 4 || next.Contains(1) || !next.Contains(4) {
+		t.Error("Expected members 0, 2, 3 and 4, got", next.Members())
+	}
+	if !next.PublicKey(2).Equals(rotated) || !next.PublicKey(3).Equals(keys[3]) {
+		t.Error("Unexpected keys after rotation", next.PublicKeys())
+	}
+	if vs.Contains(4) || !vs.Contains(1) {
+		t.Error("Expected the original validator set to be unchanged")
+	}
+	// Process 2 has 2 out of 5 of the voting power
+	c := NewSilenceCertificate(5)
+	c.AddSignature(make([]byte, SignatureSize), 2)
+	c.AddSignature(make([]byte, SignatureSize), 1)
+	if next.IsQuorum(c) {
+		t.Error("Expected weight 2 out of 5 not to be a quorum")
+	}
+	c.AddSignature(make([]byte, SignatureSize), 4)
+	if !next.IsQuorum(c) || vs.IsQuorum(c) {
+		t.Error("Expected signers 1, 2 and 4 to be a quorum only in the new set")
+	}
+	if restored := ValidatorSetFromBytes(next.Marshall()); !restored.Equal(next) || restored.Epoch != 5 {
+		t.Error("Expected unmarshalled validator set", next.Members(), "got", restored)
+	}
+}
+
+func TestMembership(t *testing.T) {
+	m := NewMembership(NewValidatorSet(MIN_EPOCH, 4, nil, nil).WithOperator(testOperator.PubKey()), 2)
+	b0 := NewBlock(testRandValue(16), nil)
+	if m.Commit(b0) != nil {
+		t.Error("Expected block with no reconfiguration not to change membership")
+	}
+	b1 := testReconfigurationBlock(1, b0, ValidatorUpdate{ID: 0, VotingPower: 0})
+	if set := m.Commit(b1); set == nil || set.Epoch != 3 {
+		t.Fatal("Expected new validator set from epoch 3, got", set)
+	}
+	for e := int64(0); e < 6; e++ {
+		if m.At(e).Contains(0) != (e < 3) {
+			t.Errorf("Unexpected membership of process 0 in epoch %v", e)
+		}
+	}
+	// Repeated reconfigurations have no effect
+	b2 := testReconfigurationBlock(2, b1, ValidatorUpdate{ID: 0, VotingPower: 0})
+	if m.Commit(b2) != nil {
+		t.Error("Expected repeated reconfiguration not to change membership")
+	}
+	b3 := testReconfigurationBlock(3, b2, ValidatorUpdate{ID: 4, VotingPower: 1})
+	m.Commit(b3)
+	if m.At(4).Contains(4) || !m.At(5).Contains(4) || m.At(5).Contains(0) {
+		t.Error("Expected members 1 to 4 from epoch 5, got", m.At(5).Members())
+	}
+	policy := NewLeaderPolicy(ROUND_ROBIN, m, 2)
+	for e := int64(5); e < 9; e++ {
+		if leader := policy.Leader(e); leader != int(e%4)+1 {
+			t.Errorf("Leader(%v) returned %v expected %v", e, leader, e%4+1)
+		}
+	}
+	m.Prune(5)
+	if m.At(0).Contains(0) {
+		t.Error("Expected validator sets before epoch 5 to be pruned")
+	}
+}
+
+func TestReconfigurationAuthorization(t *testing.T) {
+	vs := NewValidatorSet(MIN_EPOCH, 4, nil, nil).WithOperator(testOperator.PubKey())
+	m := NewMembership(vs, 1)
+	remove := []ValidatorUpdate{{ID: 1, VotingPower: 0}, {ID: 2, VotingPower: 0}, {ID: 3, VotingPower: 0}}
+	block := func(r *Reconfiguration) *Block {
+		b := NewBlock(r.Marshall(), nil)
+		b.Epoch = 1
+		return b
+	}
+
+	// Reconfigurations not signed by the operator are not applied
+	unsigned := &Reconfiguration{Sequence: 1, Updates: remove}
+	forged := &Reconfiguration{Sequence: 1, Updates: remove}
+	forged.Sign(crypto.GeneratePrivateKey())
+	for _, r := range []*Reconfiguration{unsigned, forged} {
+		if vs.ValidValue(r.Marshall()) || m.Commit(block(r)) != nil {
+			t.Error("Expected reconfiguration not signed by the operator to be rejected")
+		}
+	}
+	if NewValidatorSet(MIN_EPOCH, 4, nil, nil).Authorizes(forged) {
+		t.Error("Expected reconfigurations not to be authorised without an operator")
+	}
+	if !vs.ValidValue(testRandValue(64)) {
+		t.Error("Expected values other than reconfigurations to be valid")
+	}
+	// Voting power is bounded
+	unbounded := &Reconfiguration{Sequence: 1, Updates: []ValidatorUpdate{{ID: 0, VotingPower: MaxVotingPower + 1}}}
+	unbounded.Sign(testOperator)
+	if vs.ValidValue(unbounded.Marshall()) || m.Commit(block(unbounded)) != nil {
+		t.Error("Expected reconfiguration with unbounded voting power to be rejected")
+	}
+
+	signed := &Reconfiguration{Sequence: 1, Updates: remove[:1]}
+	signed.Sign(testOperator)
+	if !vs.ValidValue(signed.Marshall()) || m.Commit(block(signed)) == nil {
+		t.Fatal("Expected reconfiguration signed by the operator to be applied")
+	}
+	// A reconfiguration cannot be replayed, nor reuse a sequence number
+	readd := &Reconfiguration{Sequence: 1, Updates: []ValidatorUpdate{{ID: 1, VotingPower: 1}}}
+	readd.Sign(testOperator)
+	if m.Commit(block(readd)) != nil || m.At(2).ValidValue(readd.Marshall()) {
+		t.Error("Expected reconfiguration with a used sequence number to be rejected")
+	}
+	// The sequence number and the operator survive the write-ahead log
+	restored := ValidatorSetFromBytes(m.At(2).Marshall())
+	m.Restore([]*ValidatorSet{restored})
+	if m.At(2).Authorizes(signed) || !m.At(2).Equal(restored) {
+		t.Error("Expected restored validator set to retain the sequence number")
+	}
+	readd.Sequence = 2
+	readd.Sign(testOperator)
+	if m.Commit(block(readd)) == nil || !m.At(2).Contains(1) {
+		t.Error("Expected restored validator set to retain the operator")
+	}
+}
+
+func TestValidatorSetMaxVotingPower(t *testing.T) {
+	max := int64(^uint64(0) >> 1)
+	vs := NewValidatorSet(MIN_EPOCH, 3, []int64{max, max, max}, nil)
+	if vs.VotingPower(0) != MaxVotingPower {
+		t.Error("Expected voting power to be capped, got", vs.VotingPower(0))
+	}
+	c := NewBlockCertificate(MIN_EPOCH, testRandValue(BlockIDSize), MIN_HEIGHT)
+	c.AddSignature(nil, 0)
+	if vs.IsQuorum(c) || vs.IsByzantineQuorum(c) {
+		t.Error("Expected 1 out of 3 not to be a quorum")
+	}
+	c.AddSignature(nil, 1)
+	if !vs.IsQuorum(c) || vs.IsByzantineQuorum(c) {
+		t.Error("Expected 2 out of 3 to be a quorum, not a byzantine quorum")
+	}
+}
+
+func TestValidatorSetByzantineQuorum(t *testing.T) {
+	vs := NewValidatorSet(MIN_EPOCH, 4, []int64{1, 1, 1, 3}, nil)
+	c := NewBlockCertificate(MIN_EPOCH, testRandValue(BlockIDSize), MIN_HEIGHT)
+	c.AddSignature(nil, 3)
+	c.AddSignature(nil, 0)
+	if !vs.IsQuorum(c) || vs.IsByzantineQuorum(c) {
+		t.Error("Expected 4 out of 6 to be a quorum, not a byzantine quorum")
+	}
+	c.AddSignature(nil, 1)
+	if !vs.IsByzantineQuorum(c) {
+		t.Error("Expected 5 out of 6 to be a byzantine quorum")
+	}
+}
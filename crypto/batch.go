@@ -0,0 +1,44 @@
+package crypto
+
+import (
+	"github.com/tendermint/tendermint/crypto/ed25519"
+)
+
+// VerifyBatch checks the signatures with the public keys indexed by process
+// ID. Returns whether all signatures are valid and the validity of each
+// signature.
+//
+// Individual ed25519 signatures are verified with a single multi-scalar
+// check. If the check fails, they are verified one by one to identify the
+// invalid ones. Other signatures, BLS and aggregate ones, are verified
+// individually.
+func VerifyBatch(sigs []*Signature, keys []PublicKey) (bool, []bool) {
+	valid := make([]bool, len(sigs))
+	all := true
+	batch := ed25519.NewBatchVerifier()
+	var batched []int
+	for i, sig := range sigs {
+		if sig.Signers == nil && sig.ID >= 0 && sig.ID < len(keys) && keys[sig.ID] != nil {
+			if err := batch.Add(keys[sig.ID], sig.Payload, sig.Signature); err == nil {
+				batched = append(batched, i)
+				continue
+			}
+		}
+		valid[i] = sig.Verify(keys)
+		all = all && valid[i]
+	}
+	if len(batched) == 0 {
+		return all, valid
+	}
+	if ok, _ := batch.Verify(); ok {
+		for _, i := range batched {
+			valid[i] = true
+		}
+		return all, valid
+	}
+	for _, i := range batched {
+		valid[i] = sigs[i].Verify(keys)
+		all = all && valid[i]
+	}
+	return all, valid
+}
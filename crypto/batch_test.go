@@ -0,0 +1,74 @@
+package crypto
+
+import (
+	"fmt"
+	"testing"
+)
+
+func testBatch(n int, payload []byte) ([]*Signature, []PublicKey) {
+	sigs := make([]*Signature, n)
+	keys := make([]PublicKey, n)
+	for i := 0; i < n; i++ {
+		priv := GeneratePrivateKey()
+		keys[i] = priv.PubKey()
+		signature, err := priv.Sign(payload)
+		if err != nil {
+			panic(err)
+		}
+		sigs[i] = NewSignature(i, payload, signature)
+	}
+	return sigs, keys
+}
+
+func TestVerifyBatch(t *testing.T) {
+	sigs, keys := testBatch(8, []byte("a payload"))
+	if ok, valid := VerifyBatch(sigs, keys); !ok || len(valid) != len(sigs) {
+		t.Error("Failed to verify batch of valid signatures", valid)
+	}
+	// A signature of another payload, and a signature of an unknown process
+	other, _ := GeneratePrivateKey().Sign([]byte("a payload"))
+	sigs[3] = NewSignature(3, []byte("another payload"), sigs[3].Signature)
+	sigs = append(sigs, NewSignature(len(keys), []byte("a payload"), other))
+	ok, valid := VerifyBatch(sigs, keys)
+	if ok {
+		t.Error("Unexpected to verify batch with invalid signatures")
+	}
+	for i := range sigs {
+		if valid[i] != (i != 3 && i != len(keys)) {
+			t.Errorf("Unexpected validity %v of signature %v", valid[i], i)
+		}
+	}
+	// BLS signatures are verified individually
+	bls := GenerateBLSPrivateKey()
+	signature, _ := bls.Sign([]byte("a payload"))
+	sigs, keys = testBatch(4, []byte("a payload"))
+	keys = append(keys, bls.PubKey())
+	sigs = append(sigs, NewSignature(4, []byte("a payload"), signature))
+	if ok, _ := VerifyBatch(sigs, keys); !ok {
+		t.Error("Failed to verify batch with BLS signatures")
+	}
+}
+
+func BenchmarkVerifySignatures(b *testing.B) {
+	for _, n := range []int{4, 16, 64} {
+		sigs, keys := testBatch(n, []byte("a payload"))
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, sig := range sigs {
+					sig.Verify(keys)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkVerifyBatch(b *testing.B) {
+	for _, n := range []int{4, 16, 64} {
+		sigs, keys := testBatch(n, []byte("a payload"))
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				VerifyBatch(sigs, keys)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"sync"
+
+	bls12381 "github.com/kilic/bls12-381"
+	tmcrypto "github.com/tendermint/tendermint/crypto"
+)
+
+// BLS signatures over the BLS12-381 curve, in the minimal-signature-size
+// variant: signatures are points of G1 and public keys are points of G2.
+// Signatures of the same message by multiple keys aggregate into a single
+// signature, verified against the aggregate of the public keys.
+//
+// Aggregate verification is only safe against rogue-key attacks when the
+// public keys are known to be generated by their owners, as it is the case
+// of keys assigned by the configuration or by committed reconfigurations.
+const (
+	// Size in bytes of BLS signatures, compressed points of G1: 48 bytes.
+	// Produced signatures are padded with zeros to SignatureSize.
+	BLSSignatureSize = 48
+
+	// Size in bytes of encoded BLS public keys, compressed points of G2.
+	BLSPublicKeySize = 96
+
+	// Size in bytes of encoded BLS private keys.
+	BLSPrivateKeySize = 32
+
+	// Type of BLS keys.
+	BLSKeyType = "bls12-381"
+)
+
+// Domain separation tag of the hash of messages to G1.
+var blsDomain = []byte("BLS_SIG_BLS12381G1_XMD:SHA-256_SSWU_RO_NUL_")
+
+var errInvalidSignature = errors.New("crypto: invalid BLS signature")
+
+// Decoded public keys, by encoding, as decoding points of G2 is expensive.
+var blsPublicKeys sync.Map
+
+// Decodes a public key to a point of G2, a copy of the cached point.
+func blsPublicKeyPoint(k BLSPublicKey) (*bls12381.PointG2, error) {
+	point, ok := blsPublicKeys.Load(string(k))
+	if !ok {
+		decoded, err := bls12381.NewG2().FromCompressed(k)
+		if err != nil {
+			return nil, err
+		}
+		point, _ = blsPublicKeys.LoadOrStore(string(k), decoded)
+	}
+	return new(bls12381.PointG2).Set(point.(*bls12381.PointG2)), nil
+}
+
+// BLSPrivateKey is a BLS private key, a scalar.
+type BLSPrivateKey []byte
+
+// BLSPublicKey is a BLS public key, a compressed point of G2.
+type BLSPublicKey []byte
+
+// GenerateBLSPrivateKey generates a new BLS private key.
+func GenerateBLSPrivateKey() PrivateKey {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		// FIXME: anything better than panicing here?
+		panic(err)
+	}
+	return GenerateBLSPrivateKeyFromSecret(secret)
+}
+
+// GenerateBLSPrivateKeyFromSecret generates a BLS private key from a secret.
+func GenerateBLSPrivateKeyFromSecret(secret []byte) PrivateKey {
+	hash := sha256.Sum256(secret)
+	scalar := bls12381.NewFr().FromBytes(hash[:])
+	if scalar.IsZero() {
+		scalar.One()
+	}
+	return BLSPrivateKey(scalar.ToBytes())
+}
+
+// Bytes returns the encoded private key.
+func (k BLSPrivateKey) Bytes() []byte {
+	return []byte(k)
+}
+
+// Sign signs the message, the signature is padded to SignatureSize.
+func (k BLSPrivateKey) Sign(msg []byte) ([]byte, error) {
+	g1 := bls12381.NewG1()
+	point, err := g1.HashToCurve(msg, blsDomain)
+	if err != nil {
+		return nil, err
+	}
+	g1.MulScalar(point, point, bls12381.NewFr().FromBytes(k))
+	signature := make([]byte, SignatureSize)
+	copy(signature, g1.ToCompressed(point))
+	return signature, nil
+}
+
+// PubKey returns the public key associated to the private key.
+func (k BLSPrivateKey) PubKey() tmcrypto.PubKey {
+	g2 := bls12381.NewG2()
+	point := g2.New()
+	g2.MulScalar(point, g2.One(), bls12381.NewFr().FromBytes(k))
+	return BLSPublicKey(g2.ToCompressed(point))
+}
+
+// Equals checks whether the keys are the same, in constant time.
+func (k BLSPrivateKey) Equals(other tmcrypto.PrivKey) bool {
+	if o, ok := other.(BLSPrivateKey); ok {
+		return subtle.ConstantTimeCompare(k, o) == 1
+	}
+	return false
+}
+
+// Type returns the BLS key type.
+func (k BLSPrivateKey) Type() string {
+	return BLSKeyType
+}
+
+// Address returns the address of the public key.
+func (k BLSPublicKey) Address() tmcrypto.Address {
+	return tmcrypto.AddressHash(k)
+}
+
+// Bytes returns the encoded public key.
+func (k BLSPublicKey) Bytes() []byte {
+	return []byte(k)
+}
+
+// VerifySignature checks that sig is a BLS signature of msg by the key.
+func (k BLSPublicKey) VerifySignature(msg []byte, sig []byte) bool {
+	g2 := bls12381.NewG2()
+	key, err := blsPublicKeyPoint(k)
+	if err != nil || g2.IsZero(key) {
+		return false
+	}
+	point, err := blsSignatureFromBytes(sig)
+	if err != nil {
+		return false
+	}
+	hash, err := bls12381.NewG1().HashToCurve(msg, blsDomain)
+	if err != nil {
+		return false
+	}
+	// e(sig, g2) == e(H(msg), key)
+	engine := bls12381.NewEngine()
+	engine.AddPairInv(point, g2.One())
+	engine.AddPair(hash, key)
+	return engine.Check()
+}
+
+// Equals checks whether the keys are the same.
+func (k BLSPublicKey) Equals(other tmcrypto.PubKey) bool {
+	if o, ok := other.(BLSPublicKey); ok {
+		return bytes.Equal(k, o)
+	}
+	return false
+}
+
+// Type returns the BLS key type.
+func (k BLSPublicKey) Type() string {
+	return BLSKeyType
+}
+
+// Decodes a signature, padded or not, to a point of G1.
+func blsSignatureFromBytes(sig []byte) (*bls12381.PointG1, error) {
+	if len(sig) != BLSSignatureSize && len(sig) != SignatureSize {
+		return nil, errInvalidSignature
+	}
+	for _, b := range sig[BLSSignatureSize:] {
+		if b != 0 {
+			return nil, errInvalidSignature
+		}
+	}
+	return bls12381.NewG1().FromCompressed(sig[:BLSSignatureSize])
+}
+
+// AggregateSignatures aggregates BLS signatures of the same message into a
+// signature verified by the aggregate of the signers' public keys.
+func AggregateSignatures(signatures [][]byte) ([]byte, error) {
+	g1 := bls12381.NewG1()
+	aggregate := g1.Zero()
+	for _, sig := range signatures {
+		point, err := blsSignatureFromBytes(sig)
+		if err != nil {
+			return nil, err
+		}
+		g1.Add(aggregate, aggregate, point)
+	}
+	signature := make([]byte, SignatureSize)
+	copy(signature, g1.ToCompressed(aggregate))
+	return signature, nil
+}
+
+// AggregatePublicKeys aggregates BLS public keys, nil if any of the keys is
+// not a valid BLS public key.
+func AggregatePublicKeys(keys []PublicKey) PublicKey {
+	g2 := bls12381.NewG2()
+	aggregate := g2.Zero()
+	for _, key := range keys {
+		k, ok := key.(BLSPublicKey)
+		if !ok {
+			return nil
+		}
+		point, err := blsPublicKeyPoint(k)
+		if err != nil {
+			return nil
+		}
+		g2.Add(aggregate, aggregate, point)
+	}
+	return BLSPublicKey(g2.ToCompressed(aggregate))
+}
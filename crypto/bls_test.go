@@ -0,0 +1,74 @@
+package crypto
+
+import "testing"
+
+func TestBLSKeys(t *testing.T) {
+	priv1 := GenerateBLSPrivateKey()
+	priv2 := GenerateBLSPrivateKeyFromSecret([]byte("my secret"))
+	if priv1.Equals(priv2) {
+		t.Error("Unexpected generated private keys to be equal", priv1, priv2)
+	}
+	if !priv2.Equals(GenerateBLSPrivateKeyFromSecret([]byte("my secret"))) {
+		t.Error("Expected private keys from the same secret to be equal")
+	}
+	pub1 := priv1.PubKey()
+	pub2 := priv2.PubKey()
+	if len(pub1.Bytes()) != BLSPublicKeySize {
+		t.Error("Public key size expected", BLSPublicKeySize, "got", len(pub1.Bytes()))
+	}
+	if key := PublicKeyFromBytes(pub1.Bytes()); key == nil || !key.Equals(pub1) {
+		t.Error("Failed to decode public key", pub1)
+	}
+
+	m := []byte("a message")
+	sig1, err := priv1.Sign(m)
+	if err != nil || len(sig1) != SignatureSize {
+		t.Error("Produced signature size expected", SignatureSize, "got", len(sig1), err)
+	}
+	if !pub1.VerifySignature(m, sig1) {
+		t.Error("Failed to verify signature produced by the key")
+	}
+	if pub2.VerifySignature(m, sig1) {
+		t.Error("Unexpected to verify signature produced by other key")
+	}
+	if pub1.VerifySignature([]byte("another message"), sig1) {
+		t.Error("Unexpected to verify signature of other message")
+	}
+	sig1[SignatureSize-1] = 1
+	if pub1.VerifySignature(m, sig1) {
+		t.Error("Unexpected to verify signature with invalid padding")
+	}
+}
+
+func TestAggregateSignatures(t *testing.T) {
+	m := []byte("a message")
+	var keys []PublicKey
+	var sigs [][]byte
+	for i := 0; i < 4; i++ {
+		priv := GenerateBLSPrivateKey()
+		keys = append(keys, priv.PubKey())
+		sig, _ := priv.Sign(m)
+		sigs = append(sigs, sig)
+	}
+	aggregate, err := AggregateSignatures(sigs[1:])
+	if err != nil || len(aggregate) != SignatureSize {
+		t.Fatal("Failed to aggregate signatures", err)
+	}
+	if !NewAggregateSignature([]int{1, 2, 3}, m, aggregate).Verify(keys) {
+		t.Error("Failed to verify aggregate signature")
+	}
+	if NewAggregateSignature([]int{0, 1, 2, 3}, m, aggregate).Verify(keys) {
+		t.Error("Unexpected to verify aggregate signature with other signers")
+	}
+	if NewAggregateSignature([]int{1, 2, 4}, m, aggregate).Verify(keys) {
+		t.Error("Unexpected to verify aggregate signature with unknown signer")
+	}
+	if !NewSignature(2, m, sigs[2]).Verify(keys) {
+		t.Error("Failed to verify individual signature")
+	}
+	ed := GeneratePrivateKey()
+	sig, _ := ed.Sign(m)
+	if _, err := AggregateSignatures([][]byte{sigs[0], sig}); err == nil {
+		t.Error("Unexpected aggregation of ed25519 signature")
+	}
+}
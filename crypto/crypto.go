@@ -8,6 +8,9 @@ import (
 // Size in bytes of produced signatures: 64 bytes.
 const SignatureSize = ed25519.SignatureSize
 
+// Size in bytes of encoded public keys: 32 bytes.
+const PublicKeySize = ed25519.PubKeySize
+
 // A key used to verify signatures produced by the associated private key.
 type PublicKey tmcrypto.PubKey
 
@@ -23,3 +26,19 @@ func GeneratePrivateKey() PrivateKey {
 func GeneratePrivateKeyFromSecret(secret []byte) PrivateKey {
 	return ed25519.GenPrivKeyFromSecret(secret)
 }
+
+// PublicKeyFromBytes decodes a public key, nil if the encoding is invalid.
+// The type of the key is given by the size of the encoding.
+func PublicKeyFromBytes(buffer []byte) PublicKey {
+	switch len(buffer) {
+	case PublicKeySize:
+		key := make(ed25519.PubKey, PublicKeySize)
+		copy(key, buffer)
+		return key
+	case BLSPublicKeySize:
+		key := make(BLSPublicKey, BLSPublicKeySize)
+		copy(key, buffer)
+		return key
+	}
+	return nil
+}
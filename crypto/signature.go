@@ -6,6 +6,9 @@ type Signature struct {
 	ID        int
 	Payload   []byte
 	Signature []byte
+
+	// Signers of an aggregate signature, whose ID is not meaningful.
+	Signers []int
 }
 
 func NewSignature(ID int, payload []byte, signature []byte) *Signature {
@@ -16,6 +19,40 @@ func NewSignature(ID int, payload []byte, signature []byte) *Signature {
 	}
 }
 
+// NewAggregateSignature creates a signature of the payload aggregating the
+// signatures of the signers.
+func NewAggregateSignature(signers []int, payload []byte, signature []byte) *Signature {
+	return &Signature{
+		ID:        -1,
+		Payload:   payload,
+		Signature: signature,
+		Signers:   signers,
+	}
+}
+
+// Verify checks the signature with the public keys indexed by process ID.
+// Aggregate signatures are verified with the aggregate of the signers' keys.
+func (s *Signature) Verify(keys []PublicKey) bool {
+	if s.Signers == nil {
+		if s.ID < 0 || s.ID >= len(keys) || keys[s.ID] == nil {
+			return false
+		}
+		return keys[s.ID].VerifySignature(s.Payload, s.Signature)
+	}
+	if len(s.Signers) == 0 {
+		return false
+	}
+	signerKeys := make([]PublicKey, len(s.Signers))
+	for i, id := range s.Signers {
+		if id < 0 || id >= len(keys) || keys[id] == nil {
+			return false
+		}
+		signerKeys[i] = keys[id]
+	}
+	key := AggregatePublicKeys(signerKeys)
+	return key != nil && key.VerifySignature(s.Payload, s.Signature)
+}
+
 // Key returns a fixed-size key for this signature.
 func (s *Signature) Key() (key [SignatureSize]byte) {
 	copy(key[:], s.Signature)
@@ -23,5 +60,8 @@ func (s *Signature) Key() (key [SignatureSize]byte) {
 }
 
 func (s Signature) String() string {
+	if s.Signers != nil {
+		return fmt.Sprint("Signers:", s.Signers, "Payload:", s.Payload, "Signature:", s.Signature)
+	}
 	return fmt.Sprint("ID:", s.ID, "Payload:", s.Payload, "Signature:", s.Signature)
 }
@@ -0,0 +1,198 @@
+package tendermint
+
+import (
+	"time"
+)
+
+// DelayController adapts the small and big deltas, the bounds on the delays
+// of small messages and of proposals, to the delays observed by a process.
+//
+// Delays are measured in the epochs in which the process is the proposer.
+// A process that votes for a proposal forwards it, setting its ID as the
+// SenderFwd of the proposal, and broadcasts its vote. The proposer receives
+// the forwarded proposal after two proposal delays, and the vote after a
+// proposal delay and a small message delay.
+//
+// Each delta is set to the largest delay observed in the recent samples of
+// any sender, multiplied by a safety margin, within the configured bounds.
+type DelayController struct {
+	smallMin, smallMax time.Duration
+	bigMin, bigMax     time.Duration
+	margin             float64
+	samples            int
+
+	smallDelta time.Duration
+	bigDelta   time.Duration
+
+	// Proposals sent, by epoch, and their epochs in sending order
+	proposals map[int64]*proposalTimes
+	epochs    []int64
+
+	// Recent delays observed per sender
+	small map[int]*delaySamples
+	big   map[int]*delaySamples
+}
+
+// Times in which a proposal was sent, forwarded back and voted, per sender.
+type proposalTimes struct {
+	sent      time.Time
+	forwarded map[int]time.Time
+	voted     map[int]time.Time
+}
+
+// Ring buffer of the last delays observed from a sender.
+type delaySamples struct {
+	delays []time.Duration
+	next   int
+}
+
+func (s *delaySamples) add(delay time.Duration) {
+	if len(s.delays) < cap(s.delays) {
+		s.delays = append(s.delays, delay)
+		return
+	}
+	s.delays[s.next] = delay
+	s.next = (s.next + 1) % len(s.delays)
+}
+
+func (s *delaySamples) max() (max time.Duration) {
+	for _, delay := range s.delays {
+		if delay > max {
+			max = delay
+		}
+	}
+	return max
+}
+
+// NewDelayController creates a controller with the bounds and initial deltas
+// from the provided configuration.
+func NewDelayController(config *Config) *DelayController {
+	d := &DelayController{
+		smallMin:  config.TimeoutSmallDeltaMin,
+		smallMax:  config.TimeoutSmallDeltaMax,
+		bigMin:    config.TimeoutBigDeltaMin,
+		bigMax:    config.TimeoutBigDeltaMax,
+		margin:    config.DelayMargin,
+		samples:   config.DelaySamples,
+		proposals: make(map[int64]*proposalTimes),
+		small:     make(map[int]*delaySamples),
+		big:       make(map[int]*delaySamples),
+	}
+	if d.margin < 1 {
+		d.margin = 1
+	}
+	if d.samples < 1 {
+		d.samples = 1
+	}
+	d.smallDelta = bound(config.TimeoutSmallDelta, d.smallMin, d.smallMax)
+	d.bigDelta = bound(config.TimeoutBigDelta, d.bigMin, d.bigMax)
+	return d
+}
+
+// SmallDelta returns the current bound on the delay of small messages.
+func (d *DelayController) SmallDelta() time.Duration {
+	return d.smallDelta
+}
+
+// BigDelta returns the current bound on the delay of proposals.
+func (d *DelayController) BigDelta() time.Duration {
+	return d.bigDelta
+}
+
+// ProposalSent records that the process has broadcast its proposal.
+func (d *DelayController) ProposalSent(epoch int64, now time.Time) {
+	if _, ok := d.proposals[epoch]; ok {
+		return
+	}
+	d.proposals[epoch] = &proposalTimes{
+		sent:      now,
+		forwarded: make(map[int]time.Time),
+		voted:     make(map[int]time.Time),
+	}
+	d.epochs = append(d.epochs, epoch)
+	// Late messages of old proposals are not measured
+	if len(d.epochs) > d.samples {
+		delete(d.proposals, d.epochs[0])
+		d.epochs = d.epochs[1:]
+	}
+}
+
+// ProposalForwarded records the reception of the proposal of the process,
+// forwarded by another process.
+func (d *DelayController) ProposalForwarded(epoch int64, sender int, now time.Time) {
+	p, ok := d.proposals[epoch]
+	if !ok || !p.forwarded[sender].IsZero() {
+		return
+	}
+	p.forwarded[sender] = now
+	d.sample(d.big, sender, now.Sub(p.sent)/2)
+	d.observeSmall(p, sender)
+	d.update()
+}
+
+// VoteReceived records the reception of a vote for the proposal of the
+// process, sent by another process.
+func (d *DelayController) VoteReceived(epoch int64, sender int, now time.Time) {
+	p, ok := d.proposals[epoch]
+	if !ok || !p.voted[sender].IsZero() {
+		return
+	}
+	p.voted[sender] = now
+	if d.observeSmall(p, sender) {
+		d.update()
+	}
+}
+
+// Records the small message delay of a sender once both its vote and its
+// forwarded proposal were received. Returns false if a time is missing.
+func (d *DelayController) observeSmall(p *proposalTimes, sender int) bool {
+	forwarded, voted := p.forwarded[sender], p.voted[sender]
+	if forwarded.IsZero() || voted.IsZero() {
+		return false
+	}
+	delay := voted.Sub(p.sent) - forwarded.Sub(p.sent)/2
+	if delay < 0 {
+		delay = 0
+	}
+	d.sample(d.small, sender, delay)
+	return true
+}
+
+func (d *DelayController) sample(samples map[int]*delaySamples, sender int, delay time.Duration) {
+	s, ok := samples[sender]
+	if !ok {
+		s = &delaySamples{delays: make([]time.Duration, 0, d.samples)}
+		samples[sender] = s
+	}
+	s.add(delay)
+}
+
+// Sets the deltas from the largest recent delays of any sender.
+func (d *DelayController) update() {
+	if delay := maxDelay(d.small); delay > 0 {
+		d.smallDelta = bound(time.Duration(d.margin*float64(delay)), d.smallMin, d.smallMax)
+	}
+	if delay := maxDelay(d.big); delay > 0 {
+		d.bigDelta = bound(time.Duration(d.margin*float64(delay)), d.bigMin, d.bigMax)
+	}
+}
+
+func maxDelay(samples map[int]*delaySamples) (max time.Duration) {
+	for _, s := range samples {
+		if delay := s.max(); delay > max {
+			max = delay
+		}
+	}
+	return max
+}
+
+// Bounds a delta, unset bounds are ignored.
+func bound(delta, min, max time.Duration) time.Duration {
+	if min > 0 && delta < min {
+		return min
+	}
+	if max > 0 && delta > max {
+		return max
+	}
+	return delta
+}
@@ -0,0 +1,50 @@
+package tendermint
+
+import (
+	"testing"
+	"time"
+)
+
+func testDelayConfig() *Config {
+	config := DefaultConfig()
+	config.TimeoutSmallDelta = 100 * time.Millisecond
+	config.TimeoutBigDelta = 500 * time.Millisecond
+	config.TimeoutSmallDeltaMin = 20 * time.Millisecond
+	config.TimeoutBigDeltaMax = 400 * time.Millisecond
+	config.DelayMargin = 2
+	config.DelaySamples = 2
+	return config
+}
+
+func TestDelayController(t *testing.T) {
+	d := NewDelayController(testDelayConfig())
+	if d.SmallDelta() != 100*time.Millisecond || d.BigDelta() != 400*time.Millisecond {
+		t.Error("Expected bounded initial deltas, got", d.SmallDelta(), d.BigDelta())
+	}
+	t0 := time.Now()
+	ms := time.Millisecond
+	// Proposal delay 50ms to process 1, 100ms to process 2, small delay 20ms
+	d.ProposalSent(0, t0)
+	d.VoteReceived(0, 1, t0.Add(70*ms))
+	d.ProposalForwarded(0, 1, t0.Add(100*ms))
+	d.ProposalForwarded(0, 2, t0.Add(200*ms))
+	d.VoteReceived(0, 2, t0.Add(120*ms))
+	if d.BigDelta() != 200*ms || d.SmallDelta() != 40*ms {
+		t.Error("Expected deltas 40ms and 200ms, got", d.SmallDelta(), d.BigDelta())
+	}
+	// Only the recent samples of process 2 are considered, the largest
+	// delays are now the ones of process 1
+	for e := int64(1); e <= 2; e++ {
+		d.ProposalSent(e, t0)
+		d.ProposalForwarded(e, 2, t0.Add(20*ms))
+		d.VoteReceived(e, 2, t0.Add(12*ms))
+	}
+	if d.BigDelta() != 100*ms || d.SmallDelta() != 40*ms {
+		t.Error("Expected deltas 40ms and 100ms, got", d.SmallDelta(), d.BigDelta())
+	}
+	// Late messages of discarded proposals are ignored
+	d.ProposalForwarded(0, 3, t0.Add(time.Second))
+	if d.BigDelta() != 100*ms {
+		t.Error("Expected late forwarded proposal to be ignored, got", d.BigDelta())
+	}
+}
@@ -2,8 +2,10 @@ package tendermint
 
 import (
 	"fmt"
+	"time"
 
 	"dslab.inf.usi.ch/tendermint/consensus"
+	"dslab.inf.usi.ch/tendermint/wal"
 )
 
 func (p *Process) BootstrapEpochWindow() {
@@ -12,6 +14,33 @@ func (p *Process) BootstrapEpochWindow() {
 	p.epochs = make([]consensus.Consensus, p.config.MaxActiveEpochs)
 }
 
+// RecoverEpochWindow restores the epoch window and the blockchain from the
+// state recovered from the write-ahead log.
+//
+// The last started epoch, if not yet decided, is started again by Start
+// with the recovered locked certificate. In this epoch, as in any other, the
+// process will not send votes conflicting with the ones logged before.
+func (p *Process) RecoverEpochWindow(state *wal.State) {
+	p.membership.Restore(state.Validators)
+	if state.LastCommited != nil {
+		p.blockchain.Restore(state.LastCommited)
+		p.leaders.Commit(state.LastCommited)
+	}
+	p.lastDecided = state.LastDecided
+	if state.LastEpoch > state.LastDecided {
+		p.lastEpoch = state.LastEpoch - 1
+	} else {
+		p.lastEpoch = state.LastDecided
+	}
+	p.recoveredLocked = state.LockedCertificate
+	for _, evidence := range state.Evidence {
+		p.evidence[evidence.Epoch] = evidence
+	}
+	p.pruneEvidence(p.prunedEpoch())
+	p.config.Log.Printf("Recovered epoch window: last decided %v, restarting epoch %v\n",
+		p.lastDecided, p.lastEpoch+1)
+}
+
 // StartNewEpoch creates and starts a new epoch of consensus
 func (p *Process) StartNewEpoch(lockedCertificate *consensus.Certificate, sentLockedCertificate bool) {
 	activeEpochs := p.lastEpoch - p.lastDecided
@@ -31,41 +60,63 @@ func (p *Process) StartNewEpoch(lockedCertificate *consensus.Certificate, sentLo
 			p.deltaStat[p.lastEpoch] = NewDeltaStat(p.num, int(p.lastEpoch))
 		}*/
 	//p.config.Log.Printf("Epoch %v started with %v\n and %v.\n", p.lastEpoch, validCertificate, lockedCertificate)
+	if p.wal != nil {
+		if err := p.wal.StartEpoch(p.lastEpoch); err != nil {
+			panic(err)
+		}
+	}
+	if r, ok := p.config.Reconfigurations[p.lastEpoch]; ok {
+		p.SubmitReconfiguration(r)
+	}
 	index := p.lastEpoch % p.config.MaxActiveEpochs
 	if p.epochs[index] == nil || p.epochs[index].GetEpoch() != p.lastEpoch {
 		p.epochs[index] = p.CreateNewEpoch(p.lastEpoch)
 	}
+	p.epochTimer.Start(p.lastEpoch, time.Now())
 	p.epochs[index].Start(lockedCertificate, sentLockedCertificate)
 	p.stats.InstanceStarted()
 }
 
 func (p *Process) CreateNewEpoch(epoch int64) consensus.Consensus {
 	switch p.config.Model {
-	case "alter":
-		return consensus.NewFastAlterBFT(epoch, p, p.config.FastAlterEnabled)
+	case "alter", "silence", "equiv":
+		return p.newAttackedFastAlterBFT(epoch)
+	case "hot-stuff":
+		return consensus.NewHotStuff(epoch, p, p.hotStuff)
+	case "tendermint":
+		return consensus.NewTendermint(epoch, p, p.tendermint)
 	case "delta":
 		return consensus.NewDeltaProtocol(epoch, p)
 	case "delta-chunk":
 		return consensus.NewDeltaChunkedProtocol(epoch, p, p.config.ChunksNumber)
-	case "silence":
-		if p.config.Byzantines[p.ID()] {
-			//return consensus.NewByzantineSyncConsensus(epoch, p, p.config.Byzantines, p.config.ByzTime, p.config.ByzAttack)
-			return consensus.NewFastAlterBFTSilence(epoch, p, p.config.FastAlterEnabled)
-		} else {
-			return consensus.NewFastAlterBFT(epoch, p, p.config.FastAlterEnabled)
-		}
-	case "equiv":
-		if p.config.Byzantines[p.ID()] {
-			//return consensus.NewByzantineSyncConsensus(epoch, p, p.config.Byzantines, p.config.ByzTime, p.config.ByzAttack)
-			return consensus.NewAlterBFTEquivLeader(epoch, p, p.config.FastAlterEnabled)
-		} else {
-			return consensus.NewFastAlterBFT(epoch, p, p.config.FastAlterEnabled)
-		}
 	}
 	return nil
 }
 
+func (p *Process) newFastAlterBFT(epoch int64) *consensus.FastAlterBFT {
+	c := consensus.NewFastAlterBFT(epoch, p, p.config.FastAlterEnabled)
+	c.SeparateDissemination = p.config.SeparateDissemination
+	c.ErasureCoding = p.config.ErasureCoding
+	c.AggregateCertificates = p.config.AggregateCertificates
+	return c
+}
+
+// newAttackedFastAlterBFT returns an instance of FastAlterBFT running the
+// attack of the process, if byzantine.
+func (p *Process) newAttackedFastAlterBFT(epoch int64) consensus.Consensus {
+	c := p.newFastAlterBFT(epoch)
+	if p.attack == nil {
+		return c
+	}
+	return consensus.NewAttacker(c, p.attack, consensus.AttackConfig{
+		Byzantines: p.config.Byzantines,
+		Delay:      time.Duration(p.config.ByzTime) * time.Millisecond,
+		Partition:  p.config.ByzPartition,
+	})
+}
+
 // FinishEpoch finishes epoch and stop all active epochs before this one.
+// The outstanding timeouts of the finished epochs are canceled.
 func (p *Process) FinishEpoch(epoch int64) bool {
 	if epoch > p.lastDecided {
 		for i := epoch; i > p.lastDecided; i-- {
@@ -73,6 +124,7 @@ func (p *Process) FinishEpoch(epoch int64) bool {
 			p.epochs[index].Stop()
 			//p.config.Log.Printf("Epoch %v finished.\n", epoch)
 		}
+		p.timeoutTicker.CancelEpochs(epoch)
 		p.lastDecided = epoch
 		return true
 	}
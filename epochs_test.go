@@ -0,0 +1,41 @@
+package tendermint
+
+import (
+	"testing"
+	"time"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
+	"dslab.inf.usi.ch/tendermint/net/mock"
+)
+
+func TestFinishEpochCancelsTimeouts(t *testing.T) {
+	clock := consensus.NewManualClock(time.Unix(0, 0))
+	config := DefaultConfig()
+	config.Model = "alter"
+	config.VotingPower = []int64{1}
+	config.Clock = clock
+	p := NewProcess(0, 1, config, mock.NewGossip(8), mock.NewProxy(8))
+	defer p.Stop()
+	for epoch := int64(0); epoch < 3; epoch++ {
+		p.GetConsensusEpoch(epoch)
+		p.Schedule(&consensus.Timeout{Type: consensus.TimeoutPropose, Epoch: epoch, Duration: time.Second})
+	}
+	p.timeoutTicker.Start()
+	p.FinishEpoch(1)
+	clock.Advance(time.Second)
+
+	// Only the timeout of the epoch not finished is triggered
+	select {
+	case timeout := <-p.timeoutTicker.Out:
+		if timeout.Epoch != 2 {
+			t.Error("Unexpected timeout of finished epoch", timeout)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected timeout of epoch 2, nothing in 1s")
+	}
+	select {
+	case timeout := <-p.timeoutTicker.Out:
+		t.Error("Unexpected timeout", timeout)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
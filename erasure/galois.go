@@ -0,0 +1,139 @@
+package erasure
+
+import (
+	"errors"
+)
+
+// Arithmetic in GF(2^8) with the primitive polynomial x^8+x^4+x^3+x^2+1.
+const polynomial = 0x11d
+
+var (
+	expTable [510]byte
+	logTable [256]byte
+	mulTable [256][256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		expTable[i] = byte(x)
+		expTable[i+255] = byte(x)
+		logTable[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= polynomial
+		}
+	}
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			mulTable[a][b] = expTable[int(logTable[a])+int(logTable[b])]
+		}
+	}
+}
+
+func mul(a, b byte) byte {
+	return mulTable[a][b]
+}
+
+// Contract: a is not zero.
+func inverse(a byte) byte {
+	return expTable[255-int(logTable[a])]
+}
+
+// exp returns a to the power n.
+func exp(a byte, n int) byte {
+	if n == 0 {
+		return 1
+	}
+	if a == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])*n)%255]
+}
+
+var errSingular = errors.New("erasure: singular matrix")
+
+// matrix is a matrix over GF(2^8), by rows.
+type matrix [][]byte
+
+func newMatrix(rows, cols int) matrix {
+	m := make(matrix, rows)
+	for i := range m {
+		m[i] = make([]byte, cols)
+	}
+	return m
+}
+
+// Row i is [1, i, i^2, ...].
+func newVandermonde(rows, cols int) matrix {
+	m := newMatrix(rows, cols)
+	for i := range m {
+		for j := range m[i] {
+			m[i][j] = exp(byte(i), j)
+		}
+	}
+	return m
+}
+
+// Returns the matrix with rows from start to end, excluded.
+func (m matrix) subMatrix(start, end int) matrix {
+	sub := newMatrix(end-start, len(m[0]))
+	for i := range sub {
+		copy(sub[i], m[start+i])
+	}
+	return sub
+}
+
+func (m matrix) multiply(mm matrix) matrix {
+	result := newMatrix(len(m), len(mm[0]))
+	for i := range result {
+		for j := range result[i] {
+			var v byte
+			for k := range mm {
+				v ^= mul(m[i][k], mm[k][j])
+			}
+			result[i][j] = v
+		}
+	}
+	return result
+}
+
+// Inverts a square matrix with Gauss-Jordan elimination.
+func (m matrix) invert() (matrix, error) {
+	n := len(m)
+	work := newMatrix(n, 2*n)
+	for i := range work {
+		copy(work[i], m[i])
+		work[i][n+i] = 1
+	}
+	for col := 0; col < n; col++ {
+		pivot := col
+		for pivot < n && work[pivot][col] == 0 {
+			pivot++
+		}
+		if pivot == n {
+			return nil, errSingular
+		}
+		work[col], work[pivot] = work[pivot], work[col]
+		if v := work[col][col]; v != 1 {
+			scale := inverse(v)
+			for j := range work[col] {
+				work[col][j] = mul(work[col][j], scale)
+			}
+		}
+		for i := 0; i < n; i++ {
+			if i == col || work[i][col] == 0 {
+				continue
+			}
+			factor := work[i][col]
+			for j := range work[i] {
+				work[i][j] ^= mul(factor, work[col][j])
+			}
+		}
+	}
+	result := newMatrix(n, n)
+	for i := range result {
+		copy(result[i], work[i][n:])
+	}
+	return result, nil
+}
@@ -0,0 +1,130 @@
+// Package erasure implements a systematic Reed-Solomon erasure code over
+// GF(2^8).
+//
+// Data is split into k data shards, from which the coder computes n-k
+// parity shards, so that any k of the n shards reconstruct the data.
+package erasure
+
+import (
+	"errors"
+)
+
+var (
+	// ErrInvalidShards is returned when creating a coder with an invalid
+	// number of shards.
+	ErrInvalidShards = errors.New("erasure: invalid number of shards")
+
+	// ErrTooFewShards is returned when decoding with less than k shards.
+	ErrTooFewShards = errors.New("erasure: too few shards to reconstruct data")
+
+	// ErrShardSize is returned when decoding shards of different sizes.
+	ErrShardSize = errors.New("erasure: shards of different sizes")
+)
+
+// Coder encodes data into a fixed number of shards.
+type Coder struct {
+	dataShards  int
+	totalShards int
+
+	// Encoding matrix, the first dataShards rows are the identity
+	matrix matrix
+}
+
+// NewCoder creates a coder encoding data into totalShards shards, any
+// dataShards of which reconstruct the data.
+func NewCoder(dataShards, totalShards int) (*Coder, error) {
+	if dataShards <= 0 || totalShards < dataShards || totalShards > 256 {
+		return nil, ErrInvalidShards
+	}
+	// Any dataShards rows of a Vandermonde matrix are independent, as are
+	// the rows of its product with the inverse of its top square matrix.
+	vandermonde := newVandermonde(totalShards, dataShards)
+	top, _ := vandermonde.subMatrix(0, dataShards).invert()
+	return &Coder{
+		dataShards:  dataShards,
+		totalShards: totalShards,
+		matrix:      vandermonde.multiply(top),
+	}, nil
+}
+
+// DataShards returns the number of shards needed to reconstruct the data.
+func (c *Coder) DataShards() int {
+	return c.dataShards
+}
+
+// TotalShards returns the number of shards produced by the coder.
+func (c *Coder) TotalShards() int {
+	return c.totalShards
+}
+
+// ShardSize returns the size of the shards encoding data of the provided size.
+func (c *Coder) ShardSize(size int) int {
+	if size == 0 {
+		return 1
+	}
+	return (size + c.dataShards - 1) / c.dataShards
+}
+
+// Encode splits the data into data shards, padded with zeros, and computes
+// the parity shards. The first shards are the data shards.
+func (c *Coder) Encode(data []byte) [][]byte {
+	shardSize := c.ShardSize(len(data))
+	buffer := make([]byte, c.totalShards*shardSize)
+	copy(buffer, data)
+	shards := make([][]byte, c.totalShards)
+	for i := range shards {
+		shards[i] = buffer[i*shardSize : (i+1)*shardSize]
+	}
+	for i := c.dataShards; i < c.totalShards; i++ {
+		mulRows(c.matrix[i], shards[:c.dataShards], shards[i])
+	}
+	return shards
+}
+
+// Decode reconstructs data of the provided size from its shards, indexed as
+// produced by Encode. Missing shards are nil.
+func (c *Coder) Decode(shards [][]byte, size int) ([]byte, error) {
+	var indexes []int
+	var present [][]byte
+	for i := 0; i < len(shards) && i < c.totalShards && len(indexes) < c.dataShards; i++ {
+		if shards[i] == nil {
+			continue
+		}
+		if len(present) > 0 && len(shards[i]) != len(present[0]) {
+			return nil, ErrShardSize
+		}
+		indexes = append(indexes, i)
+		present = append(present, shards[i])
+	}
+	if len(indexes) < c.dataShards {
+		return nil, ErrTooFewShards
+	}
+	shardSize := len(present[0])
+	if shardSize*c.dataShards < size {
+		return nil, ErrShardSize
+	}
+	data := make([]byte, c.dataShards*shardSize)
+	decoding := make(matrix, c.dataShards)
+	for i, index := range indexes {
+		decoding[i] = c.matrix[index]
+	}
+	// Singular matrices are not possible, any dataShards rows are independent
+	inverse, _ := decoding.invert()
+	for i := 0; i < c.dataShards; i++ {
+		mulRows(inverse[i], present, data[i*shardSize:(i+1)*shardSize])
+	}
+	return data[:size], nil
+}
+
+// Computes output as the linear combination of the inputs with coefficients.
+func mulRows(coefficients []byte, inputs [][]byte, output []byte) {
+	for i := range output {
+		output[i] = 0
+	}
+	for j, input := range inputs {
+		table := &mulTable[coefficients[j]]
+		for i, b := range input {
+			output[i] ^= table[b]
+		}
+	}
+}
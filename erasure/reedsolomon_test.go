@@ -0,0 +1,66 @@
+package erasure
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	rand.Read(data)
+	return data
+}
+
+func TestCoderEncode(t *testing.T) {
+	c, err := NewCoder(3, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := testData(100)
+	shards := c.Encode(data)
+	if len(shards) != 5 {
+		t.Fatal("Expected 5 shards, got", len(shards))
+	}
+	for i, shard := range shards {
+		if len(shard) != c.ShardSize(len(data)) {
+			t.Errorf("Shard %v has size %v, expected %v", i, len(shard), c.ShardSize(len(data)))
+		}
+	}
+	// Data shards contain the data
+	if !bytes.Equal(bytes.Join(shards[:3], nil)[:100], data) {
+		t.Error("Expected data shards to contain the data")
+	}
+	if _, err := NewCoder(3, 2); err != ErrInvalidShards {
+		t.Error("Expected invalid number of shards, got", err)
+	}
+}
+
+func TestCoderDecode(t *testing.T) {
+	c, _ := NewCoder(4, 7)
+	for _, size := range []int{0, 1, 4, 1023} {
+		data := testData(size)
+		shards := c.Encode(data)
+		// Every subset of 4 shards reconstructs the data
+		for subset := 0; subset < 1<<7; subset++ {
+			received := make([][]byte, 7)
+			count := 0
+			for i := range received {
+				if subset&(1<<i) != 0 {
+					received[i] = shards[i]
+					count++
+				}
+			}
+			decoded, err := c.Decode(received, size)
+			if count < 4 {
+				if err != ErrTooFewShards {
+					t.Errorf("Expected too few shards with %v shards, got %v", count, err)
+				}
+				continue
+			}
+			if err != nil || !bytes.Equal(decoded, data) {
+				t.Errorf("Failed to decode %v bytes from shards %b: %v", size, subset, err)
+			}
+		}
+	}
+}
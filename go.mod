@@ -4,6 +4,7 @@ go 1.16
 
 require (
 	github.com/hashicorp/golang-lru v0.5.4
+	github.com/kilic/bls12-381 v0.1.0
 	github.com/libp2p/go-libp2p v0.20.3
 	github.com/libp2p/go-libp2p-core v0.16.1
 	github.com/libp2p/go-libp2p-kad-dht v0.16.0
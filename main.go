@@ -1,23 +1,27 @@
 package tendermint
 
 import (
+	"context"
 	"log"
 	"time"
 
 	"dslab.inf.usi.ch/tendermint/bootstrap"
 	"dslab.inf.usi.ch/tendermint/consensus"
+	"dslab.inf.usi.ch/tendermint/net"
 )
 
 // Bootstrap runs the bootstrap protocol to initialize the network.
 //
 // This methods returns when this process has been able to exchange messages
-// with every process in the network, meaning that the network is connected.
+// with as many processes as the members of its validator set, meaning that
+// the network is connected. Processes that have already bootstrapped
+// answer the announces of processes joining later, see MainLoop.
 func (p *Process) Bootstrap() {
 	// Start the verifier to handle potentially early consensus messages,
 	// which will then be buffered in the deliveryQueue.
 	p.verifier.Start()
 	ticker := time.Tick(p.config.BootstrapTickInterval)
-	protocol := bootstrap.NewBootstrap(p.id, p.num)
+	protocol := bootstrap.NewBootstrap(p.id, p.Validators(p.lastEpoch+1).Size())
 	message := protocol.ProcessTick()
 	for !protocol.Done() {
 		// Broadcast produced broadcast messages, if any
@@ -43,39 +47,126 @@ func (p *Process) Bootstrap() {
 	}
 }
 
-// MainLoop runs the main routine of a process.
+// Start runs the main routine of a process in background, together with the
+// routines signing and verifying messages and the timeout ticker.
+//
+// The process runs until the provided context is canceled or Stop is
+// invoked. The process is only started in the first time this method is
+// invoked.
+func (p *Process) Start(ctx context.Context) {
+	p.started.Do(func() {
+		p.ctx, p.cancel = context.WithCancel(ctx)
+		// Start threads for signing and broadcasting messages
+		p.signers.Add(p.config.SignatureGenerationThreads)
+		for i := 0; i < p.config.SignatureGenerationThreads; i++ {
+			go p.signAndBroadcastRoutine()
+		}
+		// Start the verifier routines of signature verification.
+		p.verifier.Start()
+		p.timeoutTicker.Start()
+		// FIXME: handle the initialization of the first instance/epoch
+		// A recovered locked certificate is sent to the leader of the epoch.
+		p.StartNewEpoch(p.recoveredLocked, p.recoveredLocked == nil)
+		go p.mainLoop()
+	})
+}
+
+// Stop stops the process, returning when all its routines have returned.
+//
+// The last stats are published, if enabled, and the write-ahead log is
+// closed. A stopped process cannot be started again.
+func (p *Process) Stop() {
+	p.started.Do(func() {
+		// Never started, but the verifier may run since Bootstrap
+		p.shutdown()
+		close(p.done)
+	})
+	p.cancel()
+	<-p.done
+}
+
+// Done returns a channel that is closed when the process has stopped.
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// MainLoop runs the main routine of a process, returning when it is stopped.
 func (p *Process) MainLoop() {
-	// Start threads for signing and broadcasting messages
-	for i := 0; i < p.config.SignatureGenerationThreads; i++ {
-		go p.signAndBroadcastRoutine()
-	}
-	// Start the verifier routines of signature verification.
-	p.verifier.Start()
-	p.timeoutTicker.Start()
-	// FIXME: handle the initialization of the first instance/epoch
-	p.StartNewEpoch(nil, true)
+	p.Start(context.Background())
+	<-p.done
+}
+
+func (p *Process) mainLoop() {
+	defer close(p.done)
 	if p.config.StatsPublishingInterval > 0 {
-		p.statsTicker = time.Tick(p.config.StatsPublishingInterval)
+		ticker := time.NewTicker(p.config.StatsPublishingInterval)
+		defer ticker.Stop()
+		p.statsTicker = ticker.C
 	}
 	for {
+		// Control messages have strict priority over other events
 		select {
-		// p.deliveryQueue is fed by the Verifier
+		case message := <-p.controlQueue:
+			p.processReceivedMessage(message, true)
+			continue
+		default:
+		}
+		select {
+		case <-p.ctx.Done():
+			p.shutdown()
+			return
+
+		// p.controlQueue and p.deliveryQueue are fed by the Verifier
+		case message := <-p.controlQueue:
+			p.processReceivedMessage(message, true)
+
 		case message := <-p.deliveryQueue:
-			//p.config.Log.Printf("Message received: %v\n", message)
-			p.stats.MessageReceived(message.Type)
-			p.processConsensusMessage(message)
+			p.processReceivedMessage(message, false)
 
 		case timeout := <-p.timeoutTicker.Out:
 			//p.config.Log.Printf("Timeout received: %v\n", timeout)
 			p.processConsensusTimeout(timeout)
 
+		case rawMessage := <-p.verifier.Skipped():
+			p.processLateBootstrap(rawMessage)
+
 		case <-p.statsTicker:
 			p.publishAndResetStats()
+
+		case reply := <-p.statusQueue:
+			reply <- p.status()
 		}
 
 	}
 }
 
+// Waits for the routines of the process to return, then flushes its stats
+// and closes the write-ahead log.
+func (p *Process) shutdown() {
+	p.signers.Wait()
+	p.verifier.Stop()
+	p.timeoutTicker.Stop()
+	if p.config.StatsPublishingInterval > 0 {
+		p.publishAndResetStats()
+	}
+	if p.wal != nil {
+		if err := p.wal.Close(); err != nil {
+			p.config.Log.Println("Could not close the write-ahead log:", err)
+		}
+	}
+}
+
+// Process a verified message, a control message or a bulk one.
+func (p *Process) processReceivedMessage(message *consensus.Message, control bool) {
+	//p.config.Log.Printf("Message received: %v\n", message)
+	p.stats.MessageReceived(message.Type)
+	if !message.Received.IsZero() {
+		p.stats.MessageQueued(control, time.Since(message.Received))
+	}
+	p.observeDelays(message)
+	p.processConsensusMessage(message)
+}
+
 // Deliver the message to the associated consensus instance, if present.
 func (p *Process) processConsensusMessage(message *consensus.Message) {
 	// Here we update the deltaStat
@@ -85,6 +176,21 @@ func (p *Process) processConsensusMessage(message *consensus.Message) {
 	//p.config.Log.Printf("DeltaStat: In epoch %v process %v (%v) received forwarded proposal from %v (%v) in %v ms\n", message.Epoch, p.ID(), p.ID()%5, message.SenderFwd, message.SenderFwd%5, duration)
 	//}
 	//fmt.Printf("Message received %v %v %v\n", message.Type, message.Epoch, message.Sender)
+	// Block requests and evidence are handled by the process, not by a
+	// consensus instance
+	switch message.Type {
+	case consensus.BLOCK_REQUEST:
+		p.processBlockRequest(message)
+		return
+	case consensus.EVIDENCE:
+		p.processEvidence(message)
+		return
+	}
+	// Certificates justifying proposals or epoch changes must be complete
+	if err := message.ValidateCertificate(p.Validators); err != nil {
+		p.config.Log.Println("Discarding message from", message.Sender, "with invalid certificate:", err)
+		return
+	}
 	epoch := p.GetConsensusEpoch(message.Epoch)
 	if epoch != nil {
 		epoch.ProcessMessage(message)
@@ -96,16 +202,33 @@ func (p *Process) processConsensusMessage(message *consensus.Message) {
 	//}
 }
 
+// Answers the announce of a process still running the bootstrap protocol,
+// e.g., a process joining the validator set or recovering from a crash.
+func (p *Process) processLateBootstrap(rawMessage net.Message) {
+	if rawMessage.Code() != bootstrap.MessageCode {
+		return
+	}
+	message := bootstrap.NewMessageFromBytes(rawMessage)
+	if message.Active() || message.Sender() == p.id {
+		return
+	}
+	p.bootstrapAnnounces++
+	p.transport.Broadcast(bootstrap.NewMessage(p.id, p.bootstrapAnnounces, true).Marshall())
+}
+
 // Deliver the timeout to the associated consensus instance, if present.
 func (p *Process) processConsensusTimeout(timeout *consensus.Timeout) {
 	epoch := p.GetConsensusEpoch(timeout.Epoch)
 	if epoch != nil {
+		p.Emit(consensus.NewTimeoutEvent(timeout))
 		epoch.ProcessTimeout(timeout)
 	}
 }
 
 // Publish stats to StatsQueue, discarding them if the queue is full.
 func (p *Process) publishAndResetStats() {
+	p.stats.Deltas = [2]time.Duration{p.smallDelta(), p.bigDelta()}
+	p.stats.computeLatencies()
 	select {
 	case p.statsQueue <- p.stats:
 	default:
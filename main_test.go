@@ -0,0 +1,78 @@
+package tendermint
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"dslab.inf.usi.ch/tendermint/crypto"
+	"dslab.inf.usi.ch/tendermint/net/mock"
+)
+
+func TestProcessStartStop(t *testing.T) {
+	key := crypto.GeneratePrivateKey()
+	routines := runtime.NumGoroutine()
+	for i := 0; i < 4; i++ {
+		transport := mock.NewGossip(1024)
+		proxy := mock.NewProxy(1024)
+		config := DefaultConfig()
+		config.PrivateKeys = []crypto.PrivateKey{key}
+		config.PublicKeys = []crypto.PublicKey{key.PubKey()}
+		config.Model = "alter"
+		config.VotingPower = []int64{1}
+		config.MaxEpochToStart = 1024
+		config.SignatureGenerationThreads = 2
+		config.SignatureVerificationThreads = 2
+		config.StatsPublishingInterval = time.Hour
+		config.TimeoutSmallDelta = 5 * time.Millisecond
+		config.TimeoutBigDelta = 20 * time.Millisecond
+		p := NewProcess(0, 1, config, transport, proxy)
+		proxy.Proposals <- []byte("a value")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		p.Start(ctx)
+		// The process broadcasts its proposal
+		select {
+		case <-transport.SendQueue:
+		case <-time.After(time.Second):
+			t.Fatal("No message broadcast in 1s")
+		}
+		// The process is stopped either by Stop or by its context
+		if i%2 == 0 {
+			p.Stop()
+		} else {
+			cancel()
+			<-p.Done()
+		}
+		cancel()
+		select {
+		case <-p.StatsQueue():
+		default:
+			t.Error("Expected stats to be published when stopped")
+		}
+		transport.DrainQueues()
+		proxy.DrainQueues()
+	}
+
+	// Routines of stopped processes have returned
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > routines && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > routines {
+		t.Errorf("Expected %v routines after stopping processes, got %v", routines, n)
+	}
+}
+
+func TestProcessStopNotStarted(t *testing.T) {
+	p := NewProcess(0, 1, nil, mock.NewGossip(1), mock.NewProxy(1))
+	p.Stop()
+	select {
+	case <-p.Done():
+	default:
+		t.Error("Expected process to be done")
+	}
+	// Stopping twice is harmless
+	p.Stop()
+}
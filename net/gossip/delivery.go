@@ -1,17 +1,26 @@
 package gossip
 
-import "dslab.inf.usi.ch/tendermint/net"
+import (
+	"sync"
+
+	"dslab.inf.usi.ch/tendermint/net"
+)
 
 type DeliveryQueue struct {
 	channel chan net.Message
 	drop    bool
 	stats   QueueStats
+
+	// Closed when the queue is closed
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
 func NewDeliveryQueue(size int, drop bool) *DeliveryQueue {
 	return &DeliveryQueue{
 		drop:    drop,
 		channel: make(chan net.Message, size),
+		closed:  make(chan struct{}),
 	}
 }
 
@@ -28,11 +37,22 @@ func (q *DeliveryQueue) Add(message *Message) {
 		if q.drop {
 			break // message is dropped
 		}
-		q.channel <- message.Message
+		select {
+		case q.channel <- message.Message:
+		case <-q.closed: // message is dropped
+		}
 	}
 
 }
 
+// Close unblocks the routines adding to the queue.
+// Messages added to a full closed queue are dropped.
+func (q *DeliveryQueue) Close() {
+	q.closeOnce.Do(func() {
+		close(q.closed)
+	})
+}
+
 func (q *DeliveryQueue) Chan() <-chan net.Message {
 	return q.channel
 }
@@ -1,6 +1,8 @@
 package gossip
 
 import (
+	"context"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -12,11 +14,17 @@ import (
 
 var _ net.Gossip = new(Gossip)
 var _ net.Transport = new(Gossip)
+var _ net.PriorityTransport = new(Gossip)
 
 var DefaultQueueSize = 32
 
 var BroadcastQueueSize int = 32
 var DeliveryQueueSize int = 32
+var ControlQueueSize int = 32
+
+// ControlMessage classifies the messages to deliver in the ControlQueue, if
+// set. Other messages are delivered in the DeliveryQueue.
+var ControlMessage func(message net.Message) bool
 
 var RecvQueueSize int = 32
 var RecvQueueDrop bool = false
@@ -41,6 +49,7 @@ type Gossip struct {
 
 	// Output queues
 	DeliveryQueue  *DeliveryQueue  // Messages to be delivered.
+	ControlQueue   *DeliveryQueue  // Control messages to be delivered.
 	PeerSendQueues []*MessageQueue // Messages to send to active peers.
 
 	// Same content as PeerSendQueues, but possibly with nil entries
@@ -63,6 +72,17 @@ type Gossip struct {
 	statsQueue    chan *Stats
 	statsInterval time.Duration
 
+	// Lifecycle, the routines of the transport return when ctx is canceled
+	ctx          context.Context
+	cancel       context.CancelFunc
+	done         chan struct{}
+	stopOnce     sync.Once
+	peerRoutines sync.WaitGroup
+
+	// All send queues ever created, closed when the transport is stopped
+	sendQueues     []*MessageQueue
+	sendQueuesLock sync.Mutex
+
 	// Message Loss stats
 	msgLossRand  *rand.Rand
 	msgLossRate  float64
@@ -82,13 +102,16 @@ func NewGossipTransport(host *libp2p.Host, log net.Log, msgLossRate float64) *Go
 
 		BroadcastQueue: NewMessageQueue(BroadcastQueueSize, false),
 		DeliveryQueue:  NewDeliveryQueue(DeliveryQueueSize, false),
+		ControlQueue:   NewDeliveryQueue(ControlQueueSize, false),
 		PeerRecvQueue:  NewMessageQueue(RecvQueueSize, RecvQueueDrop),
 
 		msgLossRand: rand.New(rand.NewSource(time.Now().UnixNano())),
 		msgLossRate: msgLossRate,
 
 		statsQueue: make(chan *Stats, 32),
+		done:       make(chan struct{}),
 	}
+	transport.ctx, transport.cancel = context.WithCancel(context.Background())
 	go transport.gossipMainLoop()
 	return transport
 }
@@ -113,10 +136,67 @@ func (g *Gossip) ReceiveQueue() <-chan net.Message {
 	return g.DeliveryQueue.Chan()
 }
 
+// Implements the 'tendermint/net/PriorityTransport' interface
+func (g *Gossip) ControlReceiveQueue() <-chan net.Message {
+	return g.ControlQueue.Chan()
+}
+
+// Adds the message to the ControlQueue or DeliveryQueue, according to its class.
+func (g *Gossip) deliver(message *Message) {
+	if ControlMessage != nil && ControlMessage(message.Message) {
+		g.ControlQueue.Add(message)
+	} else {
+		g.DeliveryQueue.Add(message)
+	}
+}
+
 func (g *Gossip) StatsQueue() chan *Stats {
 	return g.statsQueue
 }
 
+// Stop stops the transport, returning when all its routines have returned.
+//
+// The streams with peers are reset and queued messages are discarded.
+// The libp2p host is not closed.
+func (g *Gossip) Stop() {
+	g.stopOnce.Do(func() {
+		g.cancel()
+		g.Network.Stop()
+		// Unblock the main loop, if adding messages to full queues
+		g.closeQueues()
+		<-g.done
+		// Also closes send queues created before the main loop returned
+		g.closeQueues()
+		// Unblock receivers and senders writing to or reading from peers
+		g.Peers.Lock()
+		for _, peer := range g.Peers.List() {
+			if peer.RecvStream != nil {
+				peer.RecvStream.Reset()
+			}
+			if peer.SendStream != nil {
+				peer.SendStream.Reset()
+			}
+		}
+		g.Peers.Unlock()
+		g.peerRoutines.Wait()
+	})
+}
+
+func (g *Gossip) closeQueues() {
+	g.BroadcastQueue.Close()
+	g.PeerRecvQueue.Close()
+	g.DeliveryQueue.Close()
+	g.ControlQueue.Close()
+	if g.UnicastQueue != nil {
+		g.UnicastQueue.Close()
+	}
+	g.sendQueuesLock.Lock()
+	for _, sendQueue := range g.sendQueues {
+		sendQueue.Close()
+	}
+	g.sendQueuesLock.Unlock()
+}
+
 func (g *Gossip) addNeighbor(peer *Peer) {
 	g.Log.Println("added peer", peer.ID, peer.Chosen, peer.Addr)
 	sendQueue := NewMessageQueue(SendQueuesSize, SendQueuesDrop)
@@ -127,9 +207,14 @@ func (g *Gossip) addNeighbor(peer *Peer) {
 		g.PeerSendQueuesByID = append(g.PeerSendQueuesByID, nil)
 	}
 	g.PeerSendQueuesByID[peer.ID] = sendQueue
+	g.sendQueuesLock.Lock()
+	g.sendQueues = append(g.sendQueues, sendQueue)
+	g.sendQueuesLock.Unlock()
 
+	g.peerRoutines.Add(1)
 	go g.receiver(peer)
 	if SendQueuesBatchMax < 1 {
+		g.peerRoutines.Add(1)
 		go g.sender(peer, sendQueue)
 		//	} else {
 		//	go g.senderBatch(peer, sendQueue)
@@ -164,7 +249,7 @@ func (g *Gossip) deactivateNeighbor(peer *Peer) {
 }
 
 func (g *Gossip) deliverAndForward(message *Message) {
-	g.DeliveryQueue.Add(message)
+	g.deliver(message)
 	for i, sendQueue := range g.PeerSendQueues {
 		// Do not send the message to its sources
 		if message.from == g.Neighbors[i].ID ||
@@ -192,9 +277,14 @@ func (g *Gossip) deliverAndForward(message *Message) {
 //}
 
 func (g *Gossip) gossipMainLoop() {
-	ticker := time.Tick(StatsInterval)
+	defer close(g.done)
+	ticker := time.NewTicker(StatsInterval)
+	defer ticker.Stop()
 	for {
 		select {
+		case <-g.ctx.Done():
+			return
+
 		case message := <-g.BroadcastQueue.Chan():
 			g.Cache.Add(message.ID())
 			g.deliverAndForward(message)
@@ -227,13 +317,14 @@ func (g *Gossip) gossipMainLoop() {
 				g.deactivateNeighbor(&peer)
 			}
 
-		case <-ticker:
+		case <-ticker.C:
 			g.statsReport()
 		}
 	}
 }
 
 func (g *Gossip) receiver(peer *Peer) {
+	defer g.peerRoutines.Done()
 	var err error
 	reader := peer.BufferedReader()
 	for {
@@ -249,6 +340,10 @@ func (g *Gossip) receiver(peer *Peer) {
 }
 
 func (g *Gossip) receiverError(peer *Peer, err error) {
+	// Streams are reset when the transport is stopped
+	if g.ctx.Err() != nil {
+		return
+	}
 	g.Peers.Lock()
 	peer = g.Peers.GetByAddr(peer.Addr)
 	peer.Comment = "receiver"
@@ -263,6 +358,7 @@ func (g *Gossip) receiverError(peer *Peer, err error) {
 }
 
 func (g *Gossip) sender(peer *Peer, sendQueue *MessageQueue) {
+	defer g.peerRoutines.Done()
 	var err error
 	var message *Message
 	var validator Validator
@@ -271,6 +367,9 @@ func (g *Gossip) sender(peer *Peer, sendQueue *MessageQueue) {
 	}
 	for err == nil {
 		message = sendQueue.Next()
+		if message == nil {
+			return // Queue closed, the transport is stopped
+		}
 		if validator == nil || validator.Validate(message.Message) {
 			err = message.WriteTo(peer.SendStream)
 		} else {
@@ -308,6 +407,10 @@ func (g *Gossip) sender(peer *Peer, sendQueue *MessageQueue) {
 //}
 
 func (g *Gossip) senderError(peer *Peer, err error) {
+	// Streams are reset when the transport is stopped
+	if g.ctx.Err() != nil {
+		return
+	}
 	g.Peers.Lock()
 	peer = g.Peers.GetByAddr(peer.Addr)
 	peer.Comment = "sender"
@@ -325,6 +428,7 @@ func (g *Gossip) statsReport() {
 	stats.Cache = g.Cache.Stats()
 	stats.BQueue = g.BroadcastQueue.Stats()
 	stats.DQueue = g.DeliveryQueue.Stats()
+	stats.CQueue = g.ControlQueue.Stats()
 	stats.RQueue = g.PeerRecvQueue.Stats()
 	stats.SQueues = QueueStats{}
 	for i := 0; i < len(g.PeerSendQueues); i++ {
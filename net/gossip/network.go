@@ -1,6 +1,7 @@
 package gossip
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"time"
@@ -24,18 +25,28 @@ type Network struct {
 	// Input from libp2p's Host
 	ConnsQueue   <-chan network.Conn
 	StreamsQueue chan network.Stream
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
 func NewNetwork(host *libp2p.Host, peers *PeersTable) *Network {
 	n := &Network{
 		Host:  host,
 		Peers: peers,
+		done:  make(chan struct{}),
 	}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
 	n.ConnsQueue = host.NotifyConnections(DefaultQueueSize)
 	n.StreamsQueue = make(chan network.Stream, DefaultQueueSize)
 	host.Host.SetStreamHandler(ProtocolID,
 		func(stream network.Stream) {
-			n.StreamsQueue <- stream
+			select {
+			case n.StreamsQueue <- stream:
+			case <-n.ctx.Done():
+				stream.Reset()
+			}
 		})
 	rand.Seed(time.Now().UnixNano())
 	go n.mainLoop()
@@ -61,9 +72,20 @@ func (n *Network) Connect(addr peer.AddrInfo) {
 	}
 }
 
+// Stop stops accepting connections and streams from peers, returning when
+// the main loop has returned.
+func (n *Network) Stop() {
+	n.cancel()
+	n.Host.Host.RemoveStreamHandler(ProtocolID)
+	<-n.done
+}
+
 func (n *Network) mainLoop() {
+	defer close(n.done)
 	for {
 		select {
+		case <-n.ctx.Done():
+			return
 		case conn := <-n.ConnsQueue:
 			n.addConnection(conn)
 		case stream := <-n.StreamsQueue:
@@ -51,6 +51,15 @@ func (p *PeersTable) GetByStream(stream network.Stream) *Peer {
 	return p.GetByConn(stream.Conn())
 }
 
+// List returns copies of the peers in the table, which must be locked.
+func (p *PeersTable) List() []Peer {
+	peers := make([]Peer, 0, len(p.table))
+	for _, peer := range p.table {
+		peers = append(peers, *peer)
+	}
+	return peers
+}
+
 func (p *PeersTable) Lock() {
 	p.lock.Lock()
 }
@@ -1,15 +1,22 @@
 package gossip
 
+import "sync"
+
 type MessageQueue struct {
 	channel chan *Message
 	drop    bool
 	stats   QueueStats
+
+	// Closed when the queue is closed
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
 func NewMessageQueue(size int, drop bool) *MessageQueue {
 	return &MessageQueue{
 		drop:    drop,
 		channel: make(chan *Message, size),
+		closed:  make(chan struct{}),
 	}
 }
 
@@ -26,17 +33,34 @@ func (q *MessageQueue) Add(message *Message) {
 		if q.drop {
 			break // message is dropped
 		}
-		q.channel <- message
+		select {
+		case q.channel <- message:
+		case <-q.closed: // message is dropped
+		}
 	}
 
 }
 
+// Close unblocks the routines adding to or waiting on the queue.
+// Messages added to a full closed queue are dropped.
+func (q *MessageQueue) Close() {
+	q.closeOnce.Do(func() {
+		close(q.closed)
+	})
+}
+
 func (q *MessageQueue) Chan() <-chan *Message {
 	return q.channel
 }
 
+// Next returns the next message in the queue, or nil if the queue is closed.
 func (q *MessageQueue) Next() *Message {
-	return <-q.channel
+	select {
+	case message := <-q.channel:
+		return message
+	case <-q.closed:
+		return nil
+	}
 }
 
 func (q *MessageQueue) Retrieve(batch []*Message) int {
@@ -39,6 +39,7 @@ type Stats struct {
 	Cache       CacheStats
 	BQueue      QueueStats
 	DQueue      QueueStats
+	CQueue      QueueStats
 	RQueue      QueueStats
 	SQueues     QueueStats
 	Validator   ValidatorStats
@@ -1,6 +1,7 @@
 package gossip
 
 import (
+	"context"
 	"math/rand"
 	"time"
 
@@ -20,6 +21,7 @@ func NewUnicastTransport(host *libp2p.Host, log net.Log, msgLossRate float64) *G
 
 		BroadcastQueue: NewMessageQueue(BroadcastQueueSize, false),
 		DeliveryQueue:  NewDeliveryQueue(DeliveryQueueSize, false),
+		ControlQueue:   NewDeliveryQueue(ControlQueueSize, false),
 		PeerRecvQueue:  NewMessageQueue(RecvQueueSize, RecvQueueDrop),
 		UnicastQueue:   NewMessageQueue(BroadcastQueueSize, false),
 
@@ -27,7 +29,9 @@ func NewUnicastTransport(host *libp2p.Host, log net.Log, msgLossRate float64) *G
 		msgLossRate: msgLossRate,
 
 		statsQueue: make(chan *Stats, 32),
+		done:       make(chan struct{}),
 	}
+	transport.ctx, transport.cancel = context.WithCancel(context.Background())
 	go transport.unicastMainLoop()
 	return transport
 }
@@ -44,7 +48,7 @@ func (g *Gossip) Send(message net.Message, pids ...int) {
 func (g *Gossip) sendUnicastMessage(message *Message) {
 	for _, pid := range message.to {
 		if pid == g.Host.ID { // do not sent, but delivery
-			g.DeliveryQueue.Add(message)
+			g.deliver(message)
 			continue
 		}
 		if pid < 0 || pid >= len(g.PeerSendQueuesByID) {
@@ -62,14 +66,19 @@ func (g *Gossip) sendUnicastMessage(message *Message) {
 }
 
 func (g *Gossip) unicastMainLoop() {
-	ticker := time.Tick(StatsInterval)
+	defer close(g.done)
+	ticker := time.NewTicker(StatsInterval)
+	defer ticker.Stop()
 	for {
 		select {
+		case <-g.ctx.Done():
+			return
+
 		case message := <-g.UnicastQueue.Chan():
 			g.sendUnicastMessage(message)
 
 		case message := <-g.BroadcastQueue.Chan():
-			g.DeliveryQueue.Add(message)
+			g.deliver(message)
 			for i := range g.PeerSendQueues { // Broadcast
 				g.PeerSendQueues[i].Add(message)
 			}
@@ -84,7 +93,7 @@ func (g *Gossip) unicastMainLoop() {
 					continue
 				}
 			}
-			g.DeliveryQueue.Add(message)
+			g.deliver(message)
 
 		case peer := <-g.Peers.Active:
 			if peer.Active {
@@ -93,7 +102,7 @@ func (g *Gossip) unicastMainLoop() {
 				g.deactivateNeighbor(&peer)
 			}
 
-		case <-ticker:
+		case <-ticker.C:
 			g.statsReport()
 		}
 	}
@@ -2,6 +2,9 @@ package mock
 
 import "dslab.inf.usi.ch/tendermint/net"
 
+// Gossip implements net.Transport interface.
+var _ net.Transport = new(Gossip)
+
 // Gossip is a mock implementation of net.Gossip interface.
 type Gossip struct {
 	RecvQueue chan net.Message
@@ -32,6 +35,12 @@ func (g *Gossip) DrainQueues() {
 	}
 }
 
+// Send implements net.Transport.Send(), the message is only added to the
+// send queue.
+func (g *Gossip) Send(message net.Message, pids ...int) {
+	g.SendQueue <- message
+}
+
 // Receive implements net.Gossip.Receive()
 func (g *Gossip) Receive() net.Message {
 	return <-g.RecvQueue
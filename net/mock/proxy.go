@@ -12,6 +12,7 @@ var _ net.Proxy = new(Proxy)
 type Proxy struct {
 	Proposals chan []byte
 	Decisions chan *net.Decision
+	Evidence  chan *consensus.Evidence
 }
 
 // NewGossip creates a mock proxy implementation.
@@ -19,6 +20,7 @@ func NewProxy(queueSize int) *Proxy {
 	return &Proxy{
 		Proposals: make(chan []byte, queueSize),
 		Decisions: make(chan *net.Decision, queueSize),
+		Evidence:  make(chan *consensus.Evidence, queueSize),
 	}
 }
 
@@ -30,6 +32,9 @@ func (p *Proxy) DrainQueues() {
 	for len(p.Decisions) > 0 {
 		<-p.Decisions
 	}
+	for len(p.Evidence) > 0 {
+		<-p.Evidence
+	}
 }
 
 // Deliver delivers a block committed by the consensus protocol.
@@ -50,3 +55,8 @@ func (p *Proxy) GetValue() types.Value {
 		return nil
 	}
 }
+
+// ReportEvidence reports that the proposer of an epoch has equivocated.
+func (p *Proxy) ReportEvidence(evidence *consensus.Evidence) {
+	p.Evidence <- evidence
+}
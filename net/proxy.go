@@ -29,4 +29,8 @@ type Proxy interface {
 
 	// GetValue returns a value to be proposed in the consensus protocol.
 	GetValue() types.Value
+
+	// ReportEvidence reports that the proposer of an epoch has equivocated,
+	// so that the application can exclude the misbehaving process.
+	ReportEvidence(evidence *consensus.Evidence)
 }
@@ -2,7 +2,9 @@ package proxy
 
 import (
 	"bufio"
+	"context"
 	"io"
+	"sync"
 
 	"dslab.inf.usi.ch/tendermint/consensus"
 	"dslab.inf.usi.ch/tendermint/net"
@@ -30,6 +32,16 @@ type Proxy struct {
 
 	streams      []network.Stream
 	streamsQueue chan network.Stream
+
+	misbehaving     map[int]bool
+	misbehavingLock sync.Mutex
+
+	// Lifecycle, the routines of the proxy return when ctx is canceled
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      chan struct{}
+	stopOnce  sync.Once
+	receivers sync.WaitGroup
 }
 
 func NewProxy(host *libp2p.Host, log net.Log, debug bool) *Proxy {
@@ -40,10 +52,17 @@ func NewProxy(host *libp2p.Host, log net.Log, debug bool) *Proxy {
 		decisionQueue: make(chan *net.Decision, QueueSize),
 		proposalQueue: make(chan []byte, QueueSize),
 		streamsQueue:  make(chan network.Stream, QueueSize),
+		misbehaving:   make(map[int]bool),
+		done:          make(chan struct{}),
 	}
+	proxy.ctx, proxy.cancel = context.WithCancel(context.Background())
 	proxy.log.Prefix += " proxy"
 	host.Host.SetStreamHandler(ProtocolID, func(s network.Stream) {
-		proxy.streamsQueue <- s
+		select {
+		case proxy.streamsQueue <- s:
+		case <-proxy.ctx.Done():
+			s.Reset()
+		}
 	})
 	go proxy.mainLoop()
 	return proxy
@@ -52,11 +71,15 @@ func NewProxy(host *libp2p.Host, log net.Log, debug bool) *Proxy {
 // Deliver delivers a block committed by the consensus protocol.
 //
 // This method extracts the delivery data, which is added to the decisions queue.
+// Decisions delivered after the proxy is stopped are discarded.
 func (p *Proxy) Deliver(epoch int64, block *consensus.Block) {
-	p.decisionQueue <- &net.Decision{
+	select {
+	case p.decisionQueue <- &net.Decision{
 		Instance: uint64(block.Height),
 		Value:    block.Value,
 		ValueID:  net.ValueID(block.Value),
+	}:
+	case <-p.ctx.Done():
 	}
 }
 
@@ -70,13 +93,50 @@ func (p *Proxy) GetValue() types.Value {
 	}
 }
 
+// ReportEvidence reports that the proposer of an epoch has equivocated.
+//
+// The misbehaving process is logged, and its ID is made available through
+// Misbehaving.
+func (p *Proxy) ReportEvidence(evidence *consensus.Evidence) {
+	p.log.Println("equivocation", evidence.Proposer, "epoch", evidence.Epoch)
+	p.misbehavingLock.Lock()
+	p.misbehaving[evidence.Proposer] = true
+	p.misbehavingLock.Unlock()
+}
+
+// Misbehaving returns whether evidence has been reported against a process.
+func (p *Proxy) Misbehaving(id int) bool {
+	p.misbehavingLock.Lock()
+	defer p.misbehavingLock.Unlock()
+	return p.misbehaving[id]
+}
+
 func (p *Proxy) Debug(enable bool) {
 	p.debug = enable
 }
 
+// Stop stops the proxy, returning when all its routines have returned.
+//
+// Queued decisions are sent to the clients, then the streams with the
+// clients are closed.
+func (p *Proxy) Stop() {
+	p.stopOnce.Do(func() {
+		p.cancel()
+		p.host.Host.RemoveStreamHandler(ProtocolID)
+		<-p.done
+		p.receivers.Wait()
+	})
+}
+
 func (p *Proxy) mainLoop() {
+	defer close(p.done)
 	for {
 		select {
+		case <-p.ctx.Done():
+			p.flushDecisions()
+			p.closeClients()
+			return
+
 		case stream := <-p.streamsQueue:
 			p.addClient(stream)
 
@@ -92,8 +152,36 @@ func (p *Proxy) mainLoop() {
 	}
 }
 
+// Sends the decisions still in the decisions queue to the clients.
+func (p *Proxy) flushDecisions() {
+	for {
+		select {
+		case decision := <-p.decisionQueue:
+			if len(p.streams) > 0 {
+				p.broadcastDecision(decision)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Closes the streams with the clients, unblocking their receivers.
+func (p *Proxy) closeClients() {
+	for _, stream := range p.streams {
+		if stream == nil {
+			continue
+		}
+		if err := stream.Close(); err != nil {
+			stream.Reset()
+		}
+	}
+	p.streams = nil
+}
+
 func (p *Proxy) addClient(stream network.Stream) {
 	// Listen to proposed values
+	p.receivers.Add(1)
 	go p.receiver(stream)
 
 	// Register as destination of decisions
@@ -127,6 +215,7 @@ func (p *Proxy) removeClient(index int) {
 }
 
 func (p *Proxy) receiver(stream network.Stream) {
+	defer p.receivers.Done()
 	var err error
 	header := make([]byte, 4)
 	reader := bufio.NewReader(stream)
@@ -149,7 +238,11 @@ func (p *Proxy) receiver(stream network.Stream) {
 		}
 
 		// Propose value for consensus
-		p.proposalQueue <- value
+		select {
+		case p.proposalQueue <- value:
+		case <-p.ctx.Done():
+			return
+		}
 	}
 }
 
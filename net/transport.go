@@ -17,3 +17,12 @@ type Transport interface {
 	// The process ID id of the destinations should be provided.
 	Send(message Message, pids ...int)
 }
+
+// PriorityTransport delivers control messages in a separate queue, to be
+// received with priority over the messages in ReceiveQueue.
+type PriorityTransport interface {
+	Transport
+
+	// ControlReceiveQueue is a channel with received control messages.
+	ControlReceiveQueue() <-chan Message
+}
@@ -3,10 +3,16 @@ package tendermint
 import (
 	//	"fmt"
 
+	"bytes"
+	"context"
+	"errors"
+	"sync"
 	"time"
 
 	"dslab.inf.usi.ch/tendermint/consensus"
+	"dslab.inf.usi.ch/tendermint/crypto"
 	"dslab.inf.usi.ch/tendermint/net"
+	"dslab.inf.usi.ch/tendermint/wal"
 )
 
 /*
@@ -52,22 +58,65 @@ type Process struct {
 
 	verifier      *Verifier
 	timeoutTicker *consensus.TimeoutTicker
+	delays        *DelayController
 	blockchain    *consensus.Blockchain
+	leaders       consensus.LeaderPolicy
+	membership    *consensus.Membership
+
+	// Attack run by the process, if byzantine
+	attack consensus.Attack
+
+	// State of HotStuff carried across epochs, with the "hot-stuff" model
+	hotStuff *consensus.HotStuffState
+
+	// State of Tendermint carried across epochs, with the "tendermint" model
+	tendermint *consensus.TendermintState
+
+	// Submitted reconfigurations, proposed until committed
+	reconfigurations []*consensus.Reconfiguration
 
 	// Epoch window
 	lastDecided int64
 	lastEpoch   int64
 	epochs      []consensus.Consensus
 
+	// Write-ahead log, if enabled, and the locked certificate recovered from it
+	wal             *wal.WAL
+	recoveredLocked *consensus.Certificate
+
+	// Evidence of equivocating proposers, by epoch, pruned with the
+	// validator sets of old epochs
+	evidence map[int64]*consensus.Evidence
+
+	// Announces sent to processes bootstrapping after this one
+	bootstrapAnnounces int
+
+	// Time by which the block responses sent to each process are paced
+	blockResponses map[int]time.Time
+
 	// Parallel message signing and broadcast
 	broadcastQueue chan *consensus.Message
 	// Parallel message receiving and signature validation
 	deliveryQueue chan *consensus.Message
+	// Control messages, processed with priority, if delivered separately
+	controlQueue chan *consensus.Message
 
 	// Process statistics, periodically published to statsQueue
 	stats       *Stats
 	statsQueue  chan *Stats
 	statsTicker <-chan time.Time
+	// Latencies of the phases of epochs, recorded in stats
+	epochTimer *EpochTimer
+
+	// Requests of snapshots of the process status
+	statusQueue chan chan *Status
+
+	// Lifecycle, the routines of the process return when ctx is canceled
+	ctx     context.Context
+	cancel  context.CancelFunc
+	started sync.Once
+	signers sync.WaitGroup
+	done    chan struct{}
 
 	// Sync delta statistics
 	deltaStartTimes []time.Time
@@ -85,24 +134,78 @@ func NewProcess(id, numProcesses int, config *Config, transport net.Transport, p
 		transport: transport,
 		proxy:     proxy,
 
-		timeoutTicker: consensus.NewTimeoutTicker(),
+		evidence:       make(map[int64]*consensus.Evidence),
+		blockResponses: make(map[int]time.Time),
 
 		stats:      NewStats(),
 		statsQueue: make(chan *Stats, config.MessageQueuesSize),
 
+		statusQueue: make(chan chan *Status),
+
+		done: make(chan struct{}),
+
 		deltaStartTimes: make([]time.Time, config.MaxEpochToStart),
 	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	if config.Clock != nil {
+		p.timeoutTicker = consensus.NewTimeoutTickerWithClock(config.Clock)
+	} else {
+		p.timeoutTicker = consensus.NewTimeoutTicker()
+	}
+	p.epochTimer = NewEpochTimer(func() *Stats { return p.stats }, config.hasFastPath())
 	// Sign and broadcast messages in parallel
 	if config.SignatureGenerationThreads > 0 {
 		p.broadcastQueue = make(chan *consensus.Message, config.MessageQueuesSize)
 	}
 	p.deliveryQueue = make(chan *consensus.Message, config.MessageQueuesSize*numProcesses)
 	// Receives and validates messages in parallel, adding them to the deliveryQueue
-	p.verifier = NewVerifier(config.PublicKeys, transport.ReceiveQueue(), p.deliveryQueue)
+	p.verifier = NewVerifier(config.PublicKeys, transport.ReceiveQueue(), p.deliveryQueue,
+		config.SignatureVerificationThreads)
 	// Blockchain abstraction.
 	p.blockchain = consensus.NewBlockchain(int(config.BlockchainSize))
+	p.membership = consensus.NewMembership(consensus.NewValidatorSet(consensus.MIN_EPOCH,
+		numProcesses, config.VotingPower, config.PublicKeys).WithOperator(config.OperatorKey),
+		config.MaxActiveEpochs)
+	p.verifier.membership = p.membership
+	p.verifier.batchThreshold = config.BatchVerificationThreshold
+	if priority, ok := transport.(net.PriorityTransport); ok {
+		p.controlQueue = make(chan *consensus.Message, config.ControlQueuesSize*numProcesses)
+		p.verifier.controlInput = priority.ControlReceiveQueue()
+		p.verifier.controlOutput = p.controlQueue
+	}
+	p.leaders = consensus.NewLeaderPolicy(config.LeaderPolicy, p.membership, config.MaxActiveEpochs)
+	// FIXME: anything better than panicing here?
+	if p.leaders == nil {
+		panic("Unknown leader policy " + config.LeaderPolicy)
+	}
+	if config.Model == "hot-stuff" {
+		p.hotStuff = consensus.NewHotStuffState()
+	}
+	if config.Model == "tendermint" {
+		p.tendermint = consensus.NewTendermintState()
+	}
+	if config.Byzantines[id] {
+		p.attack = consensus.NewAttack(config.attackName())
+		// FIXME: anything better than panicing here?
+		if p.attack == nil {
+			panic("Unknown byzantine attack " + config.attackName())
+		}
+	}
+
+	if config.AdaptiveDelays {
+		p.delays = NewDelayController(config)
+	}
 
 	p.BootstrapEpochWindow()
+	if config.WALFile != "" {
+		var err error
+		p.wal, err = wal.Open(config.WALFile)
+		// FIXME: anything better than panicing here?
+		if err != nil {
+			panic(err)
+		}
+		p.RecoverEpochWindow(p.wal.State())
+	}
 	return p
 }
 
@@ -116,29 +219,76 @@ func (p *Process) NumProcesses() int {
 	return p.num
 }
 
+// Validators returns the validator set in effect in an epoch.
+func (p *Process) Validators(epoch int64) *consensus.ValidatorSet {
+	return p.membership.At(epoch)
+}
+
 // Broadcast a consensus message.
 // Consensus messages are signed before being broadcast.
 func (p *Process) Broadcast(message *consensus.Message) {
+	if !p.logSent(message) {
+		return
+	}
+	if message.Type == consensus.PROPOSE && p.delays != nil {
+		p.delays.ProposalSent(message.Epoch, time.Now())
+	}
 	if p.config.SignatureGenerationThreads > 0 {
 		// Signature computed in parallel
-		p.broadcastQueue <- message
+		select {
+		case p.broadcastQueue <- message:
+		case <-p.ctx.Done():
+		}
 	} else {
 		p.signAndBroadcast(message)
 	}
 }
 
+// Records the votes, precommits and silence messages sent by the process in
+// the write-ahead log, if enabled, whether broadcast or sent to some peers.
+// Returns false if the message must not be sent, as it conflicts with a
+// message sent in the same epoch, possibly before a restart.
+func (p *Process) logSent(message *consensus.Message) bool {
+	if p.wal == nil || message.Sender != p.id {
+		return true
+	}
+	var err error
+	switch message.Type {
+	case consensus.VOTE:
+		err = p.wal.Vote(message.Epoch, message.BlockID)
+	case consensus.PRECOMMIT:
+		err = p.wal.Precommit(message.Epoch, message.BlockID)
+	case consensus.SILENCE:
+		err = p.wal.Silence(message.Epoch)
+	}
+	if errors.Is(err, wal.ErrConflictingVote) || errors.Is(err, wal.ErrSilenceAfterVote) {
+		p.config.Log.Println("Not sending message:", err)
+		return false
+	}
+	// Safety cannot be ensured if the log cannot be written
+	if err != nil {
+		panic(err)
+	}
+	return true
+}
+
 // Routine for signing and broadcasting messages.
 func (p *Process) signAndBroadcastRoutine() {
+	defer p.signers.Done()
 	for {
-		message := <-p.broadcastQueue
-		p.signAndBroadcast(message)
+		select {
+		case message := <-p.broadcastQueue:
+			p.signAndBroadcast(message)
+		case <-p.ctx.Done():
+			return
+		}
 	}
 }
 
 // Signs a consensus message with the process private key and broadcast it.
 func (p *Process) signAndBroadcast(message *consensus.Message) {
-	if p.config.PrivateKeys[message.Sender] != nil {
-		message.Sign(p.config.PrivateKeys[message.Sender])
+	if key := p.privateKey(message); key != nil {
+		message.Sign(key)
 	}
 	// Here we start meashuring delta
 	if message.Type == consensus.PROPOSE {
@@ -155,54 +305,111 @@ func (p *Process) Forward(message *consensus.Message) {
 	p.transport.Broadcast(message.Marshall())
 }
 
-// Send a consensus message.
-// The message is already signed by its original sender.
+// Send a consensus message to a set of peers.
+// Messages of other processes are already signed by their original sender.
 func (p *Process) Send(message *consensus.Message, ids ...int) {
+	if !p.logSent(message) {
+		return
+	}
 	//p.config.Log.Printf("Message forwarded: %v\n", message)
-	if p.config.PrivateKeys[message.Sender] != nil {
-		message.Sign(p.config.PrivateKeys[message.Sender])
+	if message.Sender != p.id {
+		p.transport.Send(message.Marshall(), ids...)
+		return
+	}
+	if key := p.privateKey(message); key != nil {
+		message.Sign(key)
 	}
 	p.transport.Send(message.Marshall(), ids...)
 }
 
+// Returns the private key to sign a message, matching the public key of the
+// sender in the validator set of the message epoch, nil if none.
+func (p *Process) privateKey(message *consensus.Message) crypto.PrivateKey {
+	var key crypto.PrivateKey
+	if message.Sender >= 0 && message.Sender < len(p.config.PrivateKeys) {
+		key = p.config.PrivateKeys[message.Sender]
+	}
+	public := p.Validators(message.Epoch).PublicKey(message.Sender)
+	if key == nil || public == nil || key.PubKey().Equals(public) {
+		return key
+	}
+	for _, spare := range p.config.SpareKeys {
+		if spare.PubKey().Equals(public) {
+			return spare
+		}
+	}
+	return key
+}
+
 // Schedule a consensus timeout.
 func (p *Process) Schedule(timeout *consensus.Timeout) {
 	if !p.config.ScheduleTimeouts {
 		return
 	}
-	select {
-	case p.timeoutTicker.In <- timeout:
-	default:
-		p.config.Log.Println("failed to schedule timeout", timeout)
-	}
+	p.timeoutTicker.Schedule(timeout)
 }
 
 // Decide in an epoch of consensus.
+// HotStuff decides in the epoch of the committed block, as later epochs
+// are still running.
 func (p *Process) Decide(epoch int64, block *consensus.Block) {
-	if p.config.Model == "hot-stuff" {
-		blocks := p.blockchain.Commit(block)
-		for i := len(blocks) - 1; i >= 0; i-- {
-			p.proxy.Deliver(epoch, blocks[i])
-			if len(blocks[i].Value) > 0 {
-				p.stats.InstanceDelivered(1)
-			} else {
-				p.stats.InstanceDelivered(0)
-			}
+	if !p.FinishEpoch(epoch) {
+		return
+	}
+	blocks := p.blockchain.Commit(block)
+	for i := len(blocks) - 1; i >= 0; i-- {
+		p.reconfigure(blocks[i])
+	}
+	p.logCommit(epoch, block)
+	for i := len(blocks) - 1; i >= 0; i-- {
+		p.leaders.Commit(blocks[i])
+		p.proxy.Deliver(epoch, blocks[i])
+		if len(blocks[i].Value) > 0 {
+			p.stats.InstanceDelivered(1)
+		} else {
+			p.stats.InstanceDelivered(0)
 		}
-	} else {
-		if p.FinishEpoch(epoch) {
-			blocks := p.blockchain.Commit(block)
-			for i := len(blocks) - 1; i >= 0; i-- {
-				p.proxy.Deliver(epoch, blocks[i])
-				if len(blocks[i].Value) > 0 {
-					p.stats.InstanceDelivered(1)
-				} else {
-					p.stats.InstanceDelivered(0)
-				}
-				//p.config.Log.Printf("Block delivered in epoch %v\n", epoch)
-			}
+		//p.config.Log.Printf("Block delivered in epoch %v\n", epoch)
+	}
+	p.epochTimer.Delivered(epoch, time.Now())
+	p.membership.Prune(p.prunedEpoch())
+	p.pruneEvidence(p.prunedEpoch())
+}
+
+// Returns the last epoch whose state is no longer retained, as it is older
+// than the epoch window.
+func (p *Process) prunedEpoch() int64 {
+	return p.lastDecided - p.config.MaxActiveEpochs
+}
+
+// Applies the reconfiguration carried by a committed block, if any.
+// The resulting validator set is recorded in the write-ahead log before the
+// block is, so that it is not lost if the process crashes meanwhile.
+func (p *Process) reconfigure(block *consensus.Block) {
+	for i := 0; i < len(p.reconfigurations); i++ {
+		if bytes.Equal(block.Value, p.reconfigurations[i].Marshall()) {
+			p.reconfigurations = append(p.reconfigurations[:i], p.reconfigurations[i+1:]...)
+			i--
+		}
+	}
+	set := p.membership.Commit(block)
+	if set == nil {
+		return
+	}
+	if p.wal != nil {
+		if err := p.wal.Validators(set); err != nil {
+			panic(err)
 		}
 	}
+	p.config.Log.Printf("Validator set from epoch %v: %v\n", set.Epoch, set.Members())
+}
+
+// SubmitReconfiguration submits a reconfiguration of the validator set.
+// The reconfiguration is proposed by the process, in the epochs in which it
+// is the proposer, until a block carrying it is committed.
+// This method should be invoked by the process main routine.
+func (p *Process) SubmitReconfiguration(r *consensus.Reconfiguration) {
+	p.reconfigurations = append(p.reconfigurations, r)
 }
 
 // Finish an epoch of consensus, this should start new epoch.
@@ -212,10 +419,91 @@ func (p *Process) Finish(epoch int64, lockedCertificate *consensus.Certificate,
 	}
 }
 
-// Proposer returns the ID of the proposer of a height and round of consensus.
+// Lock records the locked certificate in the write-ahead log, if enabled.
+func (p *Process) Lock(epoch int64, lockedCertificate *consensus.Certificate) {
+	if p.wal == nil {
+		return
+	}
+	if err := p.wal.Lock(lockedCertificate); err != nil {
+		panic(err)
+	}
+}
+
+// Emit records the phase of the epoch reached by an event of a consensus
+// instance and reports the event to the event sink, if set.
+func (p *Process) Emit(event *consensus.Event) {
+	event.Process = p.id
+	event.Time = time.Now()
+	p.epochTimer.Event(event)
+	if p.config.Events == nil {
+		return
+	}
+	p.config.Events.Emit(event)
+}
+
+// ReportEvidence records the evidence that a proposer has equivocated,
+// reports it to the proxy and broadcasts it to the other processes.
+func (p *Process) ReportEvidence(evidence *consensus.Evidence) {
+	if p.addEvidence(evidence) {
+		p.Forward(consensus.NewEvidenceMessage(evidence))
+	}
+}
+
+// Records an evidence received from another process, if valid.
+func (p *Process) processEvidence(message *consensus.Message) {
+	evidence := message.Evidence
+	proposer := p.Proposer(evidence.Epoch)
+	keys := p.Validators(evidence.Epoch).PublicKeys()
+	if evidence.Proposer != proposer ||
+		(p.config.VerifySignatures && !evidence.Verify(keys, proposer)) {
+		return
+	}
+	p.addEvidence(evidence)
+}
+
+// Records an evidence, if unknown, in the write-ahead log and reports it to
+// the proxy. Returns false if the evidence was already known, or if it refers
+// to an epoch whose evidence was pruned.
+func (p *Process) addEvidence(evidence *consensus.Evidence) bool {
+	if evidence.Epoch <= p.prunedEpoch() {
+		return false
+	}
+	if _, ok := p.evidence[evidence.Epoch]; ok {
+		return false
+	}
+	p.evidence[evidence.Epoch] = evidence
+	if p.wal != nil {
+		if err := p.wal.Evidence(evidence); err != nil {
+			panic(err)
+		}
+	}
+	p.config.Log.Printf("Proposer %v equivocated in epoch %v\n", evidence.Proposer, evidence.Epoch)
+	p.proxy.ReportEvidence(evidence)
+	return true
+}
+
+// Discards the evidence of epochs up to the provided one.
+func (p *Process) pruneEvidence(epoch int64) {
+	for e := range p.evidence {
+		if e <= epoch {
+			delete(p.evidence, e)
+		}
+	}
+}
+
+// Records a committed block in the write-ahead log, if enabled.
+func (p *Process) logCommit(epoch int64, block *consensus.Block) {
+	if p.wal == nil {
+		return
+	}
+	if err := p.wal.Commit(epoch, block); err != nil {
+		panic(err)
+	}
+}
+
+// Proposer returns the proposer of an epoch, defined by the leader policy.
 func (p *Process) Proposer(epoch int64) int {
-	var proposerID = epoch % int64(p.num)
-	return int(proposerID) // TODO: mind overflow
+	return p.leaders.Leader(epoch)
 }
 
 // AddBlock add a possible decision block to the blockchain.
@@ -228,34 +516,123 @@ func (p *Process) ExtendValidChain(b *consensus.Block) bool {
 	return p.blockchain.ExtendValidChain(b)
 }
 
+// MissingAncestor returns the first ancestor of a block missing in the blockchain.
+func (p *Process) MissingAncestor(b *consensus.Block) (int64, consensus.BlockID) {
+	return p.blockchain.MissingAncestor(b)
+}
+
+// Block responses sent to a process at once, after which one is sent per
+// interval, so that requests cannot make a process flood the network.
+const (
+	blockResponseBurst    = 16
+	blockResponseInterval = 10 * time.Millisecond
+)
+
+// Serves a block requested by another process, if it is known.
+// Requests are only served to members of the validator set, whose signature
+// is verified, at a limited rate.
+func (p *Process) processBlockRequest(request *consensus.Message) {
+	if !p.Validators(request.Epoch).Contains(request.Sender) || request.Sender == p.ID() {
+		return
+	}
+	block := p.blockchain.GetBlock(request.Height, request.BlockID)
+	if block == nil || !block.HasPayload() {
+		return
+	}
+	if !p.allowBlockResponse(request.Sender, time.Now()) {
+		return
+	}
+	response := consensus.NewBlockResponseMessage(request.Epoch, block, p.ID())
+	p.Send(response, request.Sender)
+}
+
+// Returns whether a block response can be sent to a process, at most
+// blockResponseBurst at once and then one every blockResponseInterval.
+func (p *Process) allowBlockResponse(id int, now time.Time) bool {
+	next := p.blockResponses[id]
+	if next.Before(now) {
+		next = now
+	}
+	if next.Sub(now) >= blockResponseBurst*blockResponseInterval {
+		return false
+	}
+	p.blockResponses[id] = next.Add(blockResponseInterval)
+	return true
+}
+
 func (p *Process) IsEquivocatedBlock(block *consensus.Block) bool {
 	//return p.blockchain.IsEquivocatedBlock(block)
 	return false
 }
 
 // GetBlock returns a set of txs to propose as a new block.
+// Submitted reconfigurations are proposed before values from the proxy.
 func (p *Process) GetValue() []byte {
+	if len(p.reconfigurations) > 0 {
+		return p.reconfigurations[0].Marshall()
+	}
 	return p.proxy.GetValue()
 }
 
 // TimeoutPropose returns the timeout duration within which proposer should propose.
 func (p *Process) TimeoutPropose(epoch int64) time.Duration {
-	return p.config.TimeoutSmallDelta + p.config.TimeoutBigDelta
+	return p.smallDelta() + p.bigDelta()
 }
 
 // TimeoutEquivocation returns the timeout duration we need to detect equivocation.
 func (p *Process) TimeoutEquivocation(epoch int64) time.Duration {
-	return 2 * p.config.TimeoutSmallDelta
+	return 2 * p.smallDelta()
 }
 
 // TimeoutQuitEpoch returns the timeout duration of QuitEpochStep.
 func (p *Process) TimeoutQuitEpoch(epoch int64) time.Duration {
-	return 2 * p.config.TimeoutSmallDelta
+	return 2 * p.smallDelta()
 }
 
 // TimeoutEpochChange returns the timeout duration of timeout needed to learn the highest locked certificate.
 func (p *Process) TimeoutEpochChange(epoch int64) time.Duration {
-	return 2 * p.config.TimeoutSmallDelta
+	return 2 * p.smallDelta()
+}
+
+// Returns the bound on the delay of small messages, adapted if enabled.
+func (p *Process) smallDelta() time.Duration {
+	if p.delays != nil {
+		return p.delays.SmallDelta()
+	}
+	return p.config.TimeoutSmallDelta
+}
+
+// Returns the bound on the delay of proposals, adapted if enabled.
+func (p *Process) bigDelta() time.Duration {
+	if p.delays != nil {
+		return p.delays.BigDelta()
+	}
+	return p.config.TimeoutBigDelta
+}
+
+// Measures the delays of proposals forwarded back and of votes for the
+// proposals of this process, if adaptive delays are enabled.
+func (p *Process) observeDelays(message *consensus.Message) {
+	if p.delays == nil {
+		return
+	}
+	switch message.Type {
+	case consensus.PROPOSE:
+		// Forwarded headers do not measure the delay of proposals
+		if message.Sender == p.id && message.SenderFwd != p.id && message.Block.HasPayload() {
+			p.delays.ProposalForwarded(message.Epoch, message.SenderFwd, time.Now())
+		}
+	case consensus.VOTE:
+		if message.Sender2 == p.id && message.Sender != p.id {
+			p.delays.VoteReceived(message.Epoch, message.Sender, time.Now())
+		}
+	}
+}
+
+// VerifierStats returns the stats of the signature verification of received
+// messages. This method can be invoked by any routine.
+func (p *Process) VerifierStats() VerifierStats {
+	return p.verifier.Stats()
 }
 
 // StatsQueue returns the queue to which stats are periodically published.
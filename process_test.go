@@ -1,5 +1,16 @@
 package tendermint
 
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
+	"dslab.inf.usi.ch/tendermint/crypto"
+	"dslab.inf.usi.ch/tendermint/net/mock"
+)
+
 /*
 import (
 	"testing"
@@ -68,3 +79,132 @@ func TestProcessAcceptsIncorporatedProxy(t *testing.T) {
 	}
 }
 */
+
+func TestBlockRequests(t *testing.T) {
+	config := DefaultConfig()
+	config.Model = "alter"
+	transport := mock.NewGossip(1024)
+	p := NewProcess(0, 4, config, transport, mock.NewProxy(8))
+	defer p.Stop()
+	block := consensus.NewBlock([]byte("a value"), nil)
+	p.AddBlock(block)
+
+	// Requests of processes not in the validator set are not served
+	p.processBlockRequest(consensus.NewBlockRequestMessage(0, block.Height, block.BlockID(), 7))
+	if len(transport.SendQueue) != 0 {
+		t.Error("Expected request of a non-member not to be served")
+	}
+	p.processBlockRequest(consensus.NewBlockRequestMessage(0, block.Height, block.BlockID(), 1))
+	if len(transport.SendQueue) != 1 {
+		t.Error("Expected request of a member to be served")
+	}
+
+	// Responses to a process are rate-limited
+	now := time.Now()
+	for i := 0; i < blockResponseBurst; i++ {
+		if !p.allowBlockResponse(2, now) {
+			t.Error("Expected response", i, "to be allowed")
+		}
+	}
+	if p.allowBlockResponse(2, now) {
+		t.Error("Expected response over the burst not to be allowed")
+	}
+	if !p.allowBlockResponse(3, now) {
+		t.Error("Expected responses to other processes not to be limited")
+	}
+	if !p.allowBlockResponse(2, now.Add(blockResponseInterval)) {
+		t.Error("Expected a response to be allowed after the interval")
+	}
+}
+
+func TestSentMessagesLogged(t *testing.T) {
+	config := DefaultConfig()
+	config.Model = "hot-stuff"
+	config.SignatureGenerationThreads = 0
+	config.WALFile = filepath.Join(t.TempDir(), "wal")
+	gossip := mock.NewGossip(8)
+	p := NewProcess(0, 4, config, gossip, mock.NewProxy(8))
+	defer p.Stop()
+	gossip.DrainQueues()
+	b0 := consensus.NewBlock([]byte("b0"), nil)
+	b1 := consensus.NewBlock([]byte("b1"), nil)
+	// Votes sent to a single process, as in HotStuff, are logged
+	p.Send(consensus.NewVoteMessage(3, b0.BlockID(), 0, 0, 1), 1)
+	p.Send(consensus.NewVoteMessage(3, b1.BlockID(), 0, 0, 1), 1)
+	// Precommits, as in Tendermint, are logged
+	p.Broadcast(consensus.NewPrecommitMessage(4, nil, 0, 0))
+	p.Broadcast(consensus.NewPrecommitMessage(4, b0.BlockID(), 0, 0))
+	if len(gossip.SendQueue) != 2 {
+		t.Error("Expected conflicting vote and precommit not to be sent, got", len(gossip.SendQueue))
+	}
+	if voted, ok := p.wal.State().Voted(3); !ok || !voted.Equal(b0.BlockID()) {
+		t.Error("Expected the vote to be logged")
+	}
+	if _, ok := p.wal.State().Precommitted(4); !ok {
+		t.Error("Expected the precommit to be logged")
+	}
+}
+
+func TestSendMessagesOfOthers(t *testing.T) {
+	config := DefaultConfig()
+	config.Model = "alter"
+	for id := 0; id < 4; id++ {
+		config.PrivateKeys = append(config.PrivateKeys, crypto.GeneratePrivateKey())
+	}
+	gossip := mock.NewGossip(8)
+	p := NewProcess(0, 4, config, gossip, mock.NewProxy(8))
+	defer p.Stop()
+	gossip.DrainQueues()
+	b0 := consensus.NewBlock([]byte("b0"), nil)
+	vote := consensus.NewVoteMessage(3, b0.BlockID(), 0, 1, 1)
+	vote.Sign(crypto.GeneratePrivateKey())
+	signed := append([]byte(nil), vote.Marshall()...)
+	// A message of another process is sent as signed by its sender
+	p.Send(consensus.MessageFromBytes(vote.Marshall()), 2)
+	if len(gossip.SendQueue) != 1 {
+		t.Fatal("Expected the vote to be sent")
+	}
+	if sent := <-gossip.SendQueue; !bytes.Equal(sent, signed) {
+		t.Error("Expected the vote of process 1 not to be signed again")
+	}
+}
+
+func TestEvidenceOfNonProposer(t *testing.T) {
+	config := DefaultConfig()
+	config.Model = "alter"
+	proxy := mock.NewProxy(8)
+	p := NewProcess(0, 4, config, mock.NewGossip(8), proxy)
+	defer p.Stop()
+	proposer := p.Proposer(5)
+	p.processEvidence(consensus.NewEvidenceMessage(&consensus.Evidence{Epoch: 5, Proposer: (proposer + 1) % 4}))
+	if len(p.evidence) != 0 {
+		t.Error("Expected evidence of a process other than the proposer to be rejected")
+	}
+	p.processEvidence(consensus.NewEvidenceMessage(&consensus.Evidence{Epoch: 5, Proposer: proposer}))
+	if len(p.evidence) != 1 {
+		t.Error("Expected evidence of the proposer to be recorded")
+	}
+}
+
+func TestEvidencePruning(t *testing.T) {
+	config := DefaultConfig()
+	config.Model = "alter"
+	config.MaxActiveEpochs = 2
+	proxy := mock.NewProxy(8)
+	p := NewProcess(0, 4, config, mock.NewGossip(8), proxy)
+	defer p.Stop()
+	for epoch := int64(0); epoch < 4; epoch++ {
+		if !p.addEvidence(&consensus.Evidence{Epoch: epoch, Proposer: int(epoch)}) {
+			t.Error("Expected evidence of epoch", epoch, "to be added")
+		}
+	}
+	p.lastDecided = 3
+	p.pruneEvidence(p.prunedEpoch())
+	if len(p.evidence) != 2 || p.evidence[1] != nil || p.evidence[2] == nil {
+		t.Error("Expected the evidence of epochs up to 1 to be pruned, got", len(p.evidence))
+	}
+	// The evidence of pruned epochs is not recorded again
+	if p.addEvidence(&consensus.Evidence{Epoch: 1, Proposer: 1}) {
+		t.Error("Expected evidence of a pruned epoch not to be added")
+	}
+}
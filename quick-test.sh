@@ -47,5 +47,5 @@ echo "Results are in bin/logs/ directory:"
 ls -lh logs/deliveries.* 2>/dev/null || echo "No delivery files found"
 echo ""
 echo "To verify consensus, check that all nodes delivered the same blocks:"
-echo "  cd bin/logs && md5sum deliveries.*"
+echo "  cd bin && go run ./checker logs/deliveries.*"
 
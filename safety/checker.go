@@ -0,0 +1,209 @@
+// Package safety checks that replicas agree on their committed chains.
+//
+// A Checker receives the blocks committed by every replica, either directly
+// from the consensus.Process Decide calls or from the deliveries logs written
+// by the workload generator, and reports the violations of agreement.
+package safety
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
+)
+
+// Violation types.
+const (
+	CONFLICT    = iota // Different blocks committed at the same height
+	BROKEN_LINK        // Committed block not extending the block committed at the previous height
+)
+
+// Commit records the commit of a block by a replica.
+type Commit struct {
+	Replica     int
+	Epoch       int64
+	Height      int64
+	BlockID     consensus.BlockID
+	PrevBlockID consensus.BlockID
+}
+
+// NewCommit creates a Commit from a block committed by a replica in an epoch.
+func NewCommit(replica int, epoch int64, block *consensus.Block) *Commit {
+	return &Commit{
+		Replica:     replica,
+		Epoch:       epoch,
+		Height:      block.Height,
+		BlockID:     block.BlockID(),
+		PrevBlockID: block.PrevBlockID,
+	}
+}
+
+// String returns string representation of a commit.
+func (c *Commit) String() string {
+	return fmt.Sprintf("replica %d committed %s (prev %s) at height %d in epoch %d",
+		c.Replica, formatBlockID(c.BlockID), formatBlockID(c.PrevBlockID),
+		c.Height, c.Epoch)
+}
+
+// Violation is a pair of commits that break agreement.
+//
+// A CONFLICT violation has two commits of different blocks at Height.
+// A BROKEN_LINK violation has a commit at Height whose PrevBlockID is not the
+// block of the second commit, at Height - 1.
+type Violation struct {
+	Type   int
+	Height int64
+	First  *Commit
+	Second *Commit
+}
+
+// String returns string representation of a violation.
+func (v *Violation) String() string {
+	switch v.Type {
+	case CONFLICT:
+		return fmt.Sprintf("Conflict at height %d: %v; %v",
+			v.Height, v.First, v.Second)
+	case BROKEN_LINK:
+		return fmt.Sprintf("Broken link at height %d: %v; %v",
+			v.Height, v.First, v.Second)
+	}
+	return fmt.Sprintf("Invalid violation type %d", v.Type)
+}
+
+// Checker verifies agreement among the chains committed by replicas.
+type Checker struct {
+	// Distinct commits at each height, at most one per replica and block
+	heights map[int64][]*Commit
+	commits int
+}
+
+// NewChecker creates an empty checker.
+func NewChecker() *Checker {
+	return &Checker{
+		heights: make(map[int64][]*Commit),
+	}
+}
+
+// Add records a commit. Repeated commits of the same block by a replica are
+// ignored.
+func (c *Checker) Add(commit *Commit) {
+	for _, cc := range c.heights[commit.Height] {
+		if cc.Replica == commit.Replica && cc.BlockID.Equal(commit.BlockID) {
+			return
+		}
+	}
+	c.heights[commit.Height] = append(c.heights[commit.Height], commit)
+	c.commits++
+}
+
+// AddBlock records the commit of a block by a replica in an epoch.
+func (c *Checker) AddBlock(replica int, epoch int64, block *consensus.Block) {
+	c.Add(NewCommit(replica, epoch, block))
+}
+
+// Commits returns the number of recorded commits.
+func (c *Checker) Commits() int {
+	return c.commits
+}
+
+// Check returns the violations of agreement among the recorded commits,
+// ordered by height. Each conflicting block is reported once per height,
+// against the first block recorded at that height.
+func (c *Checker) Check() []*Violation {
+	var violations []*Violation
+	for _, height := range c.sortedHeights() {
+		commits := c.heights[height]
+		first := commits[0]
+		for _, commit := range commits[1:] {
+			if !commit.BlockID.Equal(first.BlockID) &&
+				!reported(violations, height, commit.BlockID) {
+				violations = append(violations, &Violation{
+					Type:   CONFLICT,
+					Height: height,
+					First:  first,
+					Second: commit,
+				})
+			}
+		}
+		// Committed blocks must extend the block committed by the same
+		// replica at height - 1, or one of the blocks committed at that
+		// height if the replica has not recorded it. Gaps in the recorded
+		// heights (e.g., empty blocks not logged) are not checked.
+		for _, commit := range commits {
+			if prev := c.previous(commit); prev != nil {
+				violations = append(violations, &Violation{
+					Type:   BROKEN_LINK,
+					Height: height,
+					First:  commit,
+					Second: prev,
+				})
+			}
+		}
+	}
+	return violations
+}
+
+// previous returns a commit at the previous height that the provided commit
+// does not extend, or nil if the link is valid or cannot be checked.
+func (c *Checker) previous(commit *Commit) *Commit {
+	prevs := c.heights[commit.Height-1]
+	for _, prev := range prevs {
+		if prev.Replica == commit.Replica {
+			if prev.BlockID.Equal(commit.PrevBlockID) {
+				return nil
+			}
+			return prev
+		}
+	}
+	for _, prev := range prevs {
+		if prev.BlockID.Equal(commit.PrevBlockID) {
+			return nil
+		}
+	}
+	if len(prevs) > 0 {
+		return prevs[0]
+	}
+	return nil
+}
+
+// reported checks whether a conflicting block was already reported at height.
+func reported(violations []*Violation, height int64, blockID consensus.BlockID) bool {
+	for _, v := range violations {
+		if v.Type == CONFLICT && v.Height == height && v.Second.BlockID.Equal(blockID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Checker) sortedHeights() []int64 {
+	heights := make([]int64, 0, len(c.heights))
+	for height := range c.heights {
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights
+}
+
+// Report returns a human-readable report of the provided violations.
+func Report(violations []*Violation) string {
+	if len(violations) == 0 {
+		return "No safety violations found\n"
+	}
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("%d safety violations found\n", len(violations)))
+	for _, v := range violations {
+		out.WriteString(v.String())
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+func formatBlockID(id consensus.BlockID) string {
+	if id == nil {
+		return "-"
+	}
+	return base64.RawStdEncoding.EncodeToString(id)
+}
@@ -0,0 +1,104 @@
+package safety
+
+import (
+	"strings"
+	"testing"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
+	"dslab.inf.usi.ch/tendermint/workload"
+)
+
+// testChain returns a chain of blocks with the provided values.
+func testChain(prev *consensus.Block, values ...string) []*consensus.Block {
+	var blocks []*consensus.Block
+	for _, v := range values {
+		prev = consensus.NewBlock([]byte(v), prev)
+		blocks = append(blocks, prev)
+	}
+	return blocks
+}
+
+func TestCheckerAgreement(t *testing.T) {
+	chain := testChain(nil, "a", "b", "c")
+	c := NewChecker()
+	for replica := 0; replica < 3; replica++ {
+		for i, b := range chain {
+			c.AddBlock(replica, int64(i), b)
+		}
+	}
+	// Repeated commits are ignored
+	c.AddBlock(0, 0, chain[0])
+	if c.Commits() != 9 {
+		t.Error("Expected 9 commits, got", c.Commits())
+	}
+	if v := c.Check(); len(v) != 0 {
+		t.Error("Unexpected violations", Report(v))
+	}
+}
+
+func TestCheckerConflict(t *testing.T) {
+	chain := testChain(nil, "a", "b")
+	fork := testChain(chain[0], "x")[0]
+	c := NewChecker()
+	c.AddBlock(0, 1, chain[1])
+	c.AddBlock(1, 1, chain[1])
+	c.AddBlock(2, 2, fork)
+	c.AddBlock(3, 2, fork)
+	v := c.Check()
+	if len(v) != 1 {
+		t.Fatal("Expected one violation, got", Report(v))
+	}
+	if v[0].Type != CONFLICT || v[0].Height != 1 {
+		t.Error("Expected conflict at height 1, got", v[0])
+	}
+	if v[0].First.Replica != 0 || v[0].Second.Replica != 2 ||
+		v[0].Second.Epoch != 2 || !v[0].Second.BlockID.Equal(fork.BlockID()) {
+		t.Error("Unexpected conflicting commits", v[0])
+	}
+}
+
+func TestCheckerBrokenLink(t *testing.T) {
+	chain := testChain(nil, "a", "b", "c")
+	other := testChain(nil, "x", "y")
+	c := NewChecker()
+	// Replica 0 commits a block not extending its own previous block
+	c.AddBlock(0, 0, chain[0])
+	c.AddBlock(0, 1, other[1])
+	// Replica 1 commits a block extending a block it has not recorded
+	c.AddBlock(1, 3, testChain(other[1], "z")[0])
+	v := c.Check()
+	var links int
+	for _, violation := range v {
+		if violation.Type == BROKEN_LINK {
+			links++
+			if violation.Height != 1 || violation.First.Replica != 0 {
+				t.Error("Unexpected broken link", violation)
+			}
+		}
+	}
+	if links != 1 {
+		t.Error("Expected one broken link, got", Report(v))
+	}
+}
+
+func TestCheckerReadDeliveries(t *testing.T) {
+	chain := testChain(nil, "a", "b", "c")
+	var log strings.Builder
+	for i, b := range chain {
+		log.WriteString(workload.FormatDelivery(workload.NewDelivery(int64(i), b)))
+	}
+	log.WriteString("# DONE\n")
+	c := NewChecker()
+	if err := c.ReadDeliveries(0, strings.NewReader(log.String())); err != nil {
+		t.Fatal("Unexpected error reading deliveries", err)
+	}
+	c.AddBlock(1, 1, testChain(chain[0], "x")[0])
+	v := c.Check()
+	if c.Commits() != 4 || len(v) != 1 || v[0].Type != CONFLICT {
+		t.Error("Expected one conflict from 4 commits, got", c.Commits(), Report(v))
+	}
+
+	if err := c.ReadDeliveries(0, strings.NewReader("invalid\n")); err == nil {
+		t.Error("Expected error reading invalid deliveries")
+	}
+}
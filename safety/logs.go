@@ -0,0 +1,55 @@
+package safety
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"dslab.inf.usi.ch/tendermint/workload"
+)
+
+// ReadDeliveries adds to the checker the commits of a replica logged by the
+// workload generator. Comment lines, starting with '#', are ignored.
+func (c *Checker) ReadDeliveries(replica int, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if len(strings.TrimSpace(text)) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		d, err := workload.ParseDelivery(text)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		c.Add(&Commit{
+			Replica:     replica,
+			Epoch:       d.Epoch,
+			Height:      d.Height,
+			BlockID:     d.BlockID,
+			PrevBlockID: d.PrevBlockID,
+		})
+	}
+	return scanner.Err()
+}
+
+// ReadDeliveriesFile adds to the checker the commits logged in a deliveries
+// file. The replica ID is the extension of the file name: deliveries.ID.
+func (c *Checker) ReadDeliveriesFile(filename string) error {
+	replica, err := strconv.Atoi(strings.TrimPrefix(filepath.Ext(filename), "."))
+	if err != nil {
+		return fmt.Errorf("%s: no replica ID in file name", filename)
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err = c.ReadDeliveries(replica, file); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	return nil
+}
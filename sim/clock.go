@@ -0,0 +1,98 @@
+package sim
+
+import (
+	"container/heap"
+	"time"
+)
+
+// event is an action scheduled to run at a given virtual time.
+type event struct {
+	at  time.Duration
+	seq uint64
+	run func()
+}
+
+// eventQueue is a min-heap of events, ordered by time and then by the order
+// in which they were scheduled, so that simultaneous events run in FIFO order.
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(*event)) }
+
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return e
+}
+
+// Clock is a virtual clock that drives a simulation.
+//
+// Time only advances when the next scheduled event is run, so a simulation
+// runs as fast as possible and its outcome does not depend on wall-clock time.
+type Clock struct {
+	now    time.Duration
+	seq    uint64
+	events eventQueue
+}
+
+// NewClock returns a virtual clock set to time zero.
+func NewClock() *Clock {
+	return &Clock{}
+}
+
+// Now returns the virtual time elapsed since the start of the simulation.
+func (c *Clock) Now() time.Duration {
+	return c.now
+}
+
+// Time returns the virtual time as a time.Time, counted from the Unix epoch.
+func (c *Clock) Time() time.Time {
+	return time.Unix(0, 0).Add(c.now)
+}
+
+// After schedules f to run once the virtual time has advanced by d.
+func (c *Clock) After(d time.Duration, f func()) {
+	if d < 0 {
+		d = 0
+	}
+	c.seq++
+	heap.Push(&c.events, &event{at: c.now + d, seq: c.seq, run: f})
+}
+
+// Pending returns the number of scheduled events not yet run.
+func (c *Clock) Pending() int {
+	return len(c.events)
+}
+
+// Next returns the time of the next scheduled event, if any.
+func (c *Clock) Next() (time.Duration, bool) {
+	if len(c.events) == 0 {
+		return 0, false
+	}
+	return c.events[0].at, true
+}
+
+// Step advances the clock to the next scheduled event and runs it.
+// It returns false if there are no scheduled events.
+func (c *Clock) Step() bool {
+	if len(c.events) == 0 {
+		return false
+	}
+	e := heap.Pop(&c.events).(*event)
+	c.now = e.at
+	e.run()
+	return true
+}
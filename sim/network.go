@@ -0,0 +1,136 @@
+package sim
+
+import (
+	"math/rand"
+	"time"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
+)
+
+// Dropped is the delay returned by policies to discard a message or timeout.
+const Dropped time.Duration = -1
+
+// Envelope is a message in transit from a replica to another.
+//
+// The message is the instance produced by the sender, it should not be
+// modified by policies.
+type Envelope struct {
+	From    int
+	To      int
+	Message *consensus.Message
+}
+
+// LinkPolicy returns the delay for delivering a message over a link.
+// A negative delay (e.g., Dropped) means that the message is lost.
+type LinkPolicy func(env *Envelope, rnd *rand.Rand) time.Duration
+
+// TimeoutPolicy returns the delay after which a timeout scheduled by a
+// replica is triggered. A negative delay means that it never fires.
+type TimeoutPolicy func(id int, timeout *consensus.Timeout, rnd *rand.Rand) time.Duration
+
+// FixedDelay delivers small messages after small and messages carrying block
+// values, proposals, payloads and chunks, after big.
+func FixedDelay(small, big time.Duration) LinkPolicy {
+	return func(env *Envelope, rnd *rand.Rand) time.Duration {
+		if carriesValue(env.Message) {
+			return big
+		}
+		return small
+	}
+}
+
+// UniformDelay delivers small messages after a delay uniformly distributed in
+// [0, small] and messages carrying block values after a delay uniformly
+// distributed in [0, big].
+func UniformDelay(small, big time.Duration) LinkPolicy {
+	return func(env *Envelope, rnd *rand.Rand) time.Duration {
+		max := small
+		if carriesValue(env.Message) {
+			max = big
+		}
+		return time.Duration(rnd.Int63n(int64(max) + 1))
+	}
+}
+
+// Proposals carry block values, unless they carry only the block header.
+func carriesValue(m *consensus.Message) bool {
+	return m.Type == consensus.PAYLOAD || m.Type == consensus.CHUNK ||
+		(m.Type == consensus.PROPOSE && m.Block.HasPayload())
+}
+
+// DropAll discards every message sent over a link.
+func DropAll() LinkPolicy {
+	return func(env *Envelope, rnd *rand.Rand) time.Duration {
+		return Dropped
+	}
+}
+
+// DropTypes discards messages of the provided types, and delivers the other
+// messages according to policy.
+func DropTypes(policy LinkPolicy, types ...int16) LinkPolicy {
+	return func(env *Envelope, rnd *rand.Rand) time.Duration {
+		for _, t := range types {
+			if env.Message.Type == t {
+				return Dropped
+			}
+		}
+		return policy(env, rnd)
+	}
+}
+
+// DropEpochs discards messages of epochs in [from, to], and delivers the
+// other messages according to policy.
+func DropEpochs(policy LinkPolicy, from, to int64) LinkPolicy {
+	return func(env *Envelope, rnd *rand.Rand) time.Duration {
+		if env.Message.Epoch >= from && env.Message.Epoch <= to {
+			return Dropped
+		}
+		return policy(env, rnd)
+	}
+}
+
+// ExactTimeout triggers timeouts after their nominal duration.
+func ExactTimeout() TimeoutPolicy {
+	return func(id int, timeout *consensus.Timeout, rnd *rand.Rand) time.Duration {
+		return timeout.Duration
+	}
+}
+
+// NoTimeout never triggers timeouts.
+func NoTimeout() TimeoutPolicy {
+	return func(id int, timeout *consensus.Timeout, rnd *rand.Rand) time.Duration {
+		return Dropped
+	}
+}
+
+// network is an in-memory network with a policy for each directed link.
+type network struct {
+	num      int
+	links    [][]LinkPolicy
+	timeouts []TimeoutPolicy
+}
+
+func newNetwork(num int, link LinkPolicy, timeout TimeoutPolicy) *network {
+	n := &network{
+		num:      num,
+		links:    make([][]LinkPolicy, num),
+		timeouts: make([]TimeoutPolicy, num),
+	}
+	for from := range n.links {
+		n.links[from] = make([]LinkPolicy, num)
+		for to := range n.links[from] {
+			n.links[from][to] = link
+		}
+		n.timeouts[from] = timeout
+	}
+	return n
+}
+
+// delay returns the delivery delay of a message, negative if dropped.
+// Messages sent by a replica to itself are delivered without delay.
+func (n *network) delay(env *Envelope, rnd *rand.Rand) time.Duration {
+	if env.From == env.To {
+		return 0
+	}
+	return n.links[env.From][env.To](env, rnd)
+}
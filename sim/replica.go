@@ -0,0 +1,344 @@
+package sim
+
+import (
+	"bytes"
+	"time"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
+)
+
+// Decision is a block delivered by a replica.
+type Decision struct {
+	Epoch int64            // Epoch in which the block was committed
+	Block *consensus.Block // Committed block
+	Time  time.Duration    // Virtual time of the delivery
+}
+
+// Replica is a simulated process, implementing consensus.Process.
+//
+// Replicas maintain the same epoch window and blockchain as tendermint.Process,
+// but communicate through the simulated network and use the virtual clock.
+type Replica struct {
+	id  int
+	sim *Simulator
+
+	blockchain *consensus.Blockchain
+	membership *consensus.Membership
+	leaders    consensus.LeaderPolicy
+
+	// Submitted reconfigurations, proposed until committed
+	reconfigurations []*consensus.Reconfiguration
+
+	// Epoch window
+	lastDecided int64
+	lastEpoch   int64
+	epochs      []consensus.Consensus
+
+	// State of HotStuff and Tendermint carried across epochs
+	hotStuff   *consensus.HotStuffState
+	tendermint *consensus.TendermintState
+
+	// Scheduled timeouts, triggered at their deadline in virtual time
+	timeouts *consensus.TimeoutQueue
+
+	crashed   bool
+	decisions []Decision
+	evidence  []*consensus.Evidence
+}
+
+func newReplica(id int, sim *Simulator) *Replica {
+	membership := consensus.NewMembership(consensus.NewValidatorSet(consensus.MIN_EPOCH,
+		sim.config.NumProcesses, sim.config.VotingPower, sim.publicKeys).WithOperator(sim.config.OperatorKey),
+		sim.config.MaxActiveEpochs)
+	leaders := consensus.NewLeaderPolicy(sim.config.LeaderPolicy,
+		membership, sim.config.MaxActiveEpochs)
+	if leaders == nil {
+		panic("Unknown leader policy " + sim.config.LeaderPolicy)
+	}
+	return &Replica{
+		id:          id,
+		sim:         sim,
+		blockchain:  consensus.NewBlockchain(int(sim.config.BlockchainSize)),
+		membership:  membership,
+		leaders:     leaders,
+		lastDecided: -1,
+		lastEpoch:   -1,
+		epochs:      make([]consensus.Consensus, sim.config.MaxActiveEpochs),
+		hotStuff:    consensus.NewHotStuffState(),
+		tendermint:  consensus.NewTendermintState(),
+		timeouts:    consensus.NewTimeoutQueue(),
+	}
+}
+
+// ID returns the process' unique identifier.
+func (r *Replica) ID() int {
+	return r.id
+}
+
+// NumProcesses returns the total number of processes.
+func (r *Replica) NumProcesses() int {
+	return r.sim.config.NumProcesses
+}
+
+// Validators returns the validator set in effect in an epoch.
+func (r *Replica) Validators(epoch int64) *consensus.ValidatorSet {
+	return r.membership.At(epoch)
+}
+
+// Broadcast a consensus message, signed by the replica.
+func (r *Replica) Broadcast(message *consensus.Message) {
+	r.sim.sign(message)
+	r.sim.broadcast(r.id, message)
+}
+
+// Forward a consensus message, already signed by its original sender.
+func (r *Replica) Forward(message *consensus.Message) {
+	r.sim.broadcast(r.id, message)
+}
+
+// Send a consensus message to a subset of processes, signed by the replica
+// unless it is a message of another process.
+func (r *Replica) Send(message *consensus.Message, ids ...int) {
+	if message.Sender == r.id {
+		r.sim.sign(message)
+	}
+	r.sim.send(r.id, message, ids...)
+}
+
+// Schedule a consensus timeout.
+func (r *Replica) Schedule(timeout *consensus.Timeout) {
+	r.sim.schedule(r.id, timeout)
+}
+
+// Proposer returns the proposer of an epoch, defined by the leader policy.
+func (r *Replica) Proposer(epoch int64) int {
+	return r.leaders.Leader(epoch)
+}
+
+// GetValue returns a value to propose, or a submitted reconfiguration.
+func (r *Replica) GetValue() []byte {
+	if len(r.reconfigurations) > 0 {
+		return r.reconfigurations[0].Marshall()
+	}
+	value := make([]byte, r.sim.config.ValueSize)
+	r.sim.rnd.Read(value)
+	return value
+}
+
+// AddBlock adds a block to the replica's blockchain.
+func (r *Replica) AddBlock(block *consensus.Block) bool {
+	return r.blockchain.AddBlock(block)
+}
+
+// ExtendValidChain checks whether a block extends the committed chain.
+func (r *Replica) ExtendValidChain(block *consensus.Block) bool {
+	return r.blockchain.ExtendValidChain(block)
+}
+
+// MissingAncestor returns the first ancestor of a block missing in the blockchain.
+func (r *Replica) MissingAncestor(block *consensus.Block) (int64, consensus.BlockID) {
+	return r.blockchain.MissingAncestor(block)
+}
+
+// IsEquivocatedBlock checks whether a block is equivocated.
+func (r *Replica) IsEquivocatedBlock(block *consensus.Block) bool {
+	return false
+}
+
+// Decide in an epoch of consensus.
+func (r *Replica) Decide(epoch int64, block *consensus.Block) {
+	if !r.finishEpoch(epoch) {
+		return
+	}
+	blocks := r.blockchain.Commit(block)
+	for i := len(blocks) - 1; i >= 0; i-- {
+		r.reconfigure(blocks[i])
+		r.leaders.Commit(blocks[i])
+		r.decisions = append(r.decisions, Decision{
+			Epoch: epoch,
+			Block: blocks[i],
+			Time:  r.sim.clock.Now(),
+		})
+	}
+}
+
+// Applies the reconfiguration carried by a committed block, if any.
+func (r *Replica) reconfigure(block *consensus.Block) {
+	for i := 0; i < len(r.reconfigurations); i++ {
+		if bytes.Equal(block.Value, r.reconfigurations[i].Marshall()) {
+			r.reconfigurations = append(r.reconfigurations[:i], r.reconfigurations[i+1:]...)
+			i--
+		}
+	}
+	r.membership.Commit(block)
+}
+
+// Finish an epoch of consensus, starting the next one.
+func (r *Replica) Finish(epoch int64, lockedCertificate *consensus.Certificate, sentLockedCertificate bool) {
+	if r.lastEpoch == epoch {
+		r.startNewEpoch(lockedCertificate, sentLockedCertificate)
+	}
+}
+
+// Lock informs that an epoch of consensus has locked a certificate.
+func (r *Replica) Lock(epoch int64, lockedCertificate *consensus.Certificate) {
+}
+
+// ReportEvidence records and disseminates the evidence that a proposer has
+// equivocated.
+func (r *Replica) ReportEvidence(evidence *consensus.Evidence) {
+	if r.addEvidence(evidence) {
+		r.Forward(consensus.NewEvidenceMessage(evidence))
+	}
+}
+
+// Emit reports an event of a consensus instance to the event sink, if set.
+func (r *Replica) Emit(event *consensus.Event) {
+	if r.sim.config.Events == nil {
+		return
+	}
+	event.Process = r.id
+	event.Time = r.sim.clock.Time()
+	r.sim.config.Events.Emit(event)
+}
+
+// TimeoutPropose returns the duration of the propose timeout.
+func (r *Replica) TimeoutPropose(epoch int64) time.Duration {
+	return r.sim.config.TimeoutSmallDelta + r.sim.config.TimeoutBigDelta
+}
+
+// TimeoutEquivocation returns the duration of the equivocation timeout.
+func (r *Replica) TimeoutEquivocation(epoch int64) time.Duration {
+	return 2 * r.sim.config.TimeoutSmallDelta
+}
+
+// TimeoutQuitEpoch returns the duration of the quit epoch timeout.
+func (r *Replica) TimeoutQuitEpoch(epoch int64) time.Duration {
+	return 2 * r.sim.config.TimeoutSmallDelta
+}
+
+// TimeoutEpochChange returns the duration of the epoch change timeout.
+func (r *Replica) TimeoutEpochChange(epoch int64) time.Duration {
+	return 2 * r.sim.config.TimeoutSmallDelta
+}
+
+// Decisions returns the blocks delivered by the replica, in delivery order.
+func (r *Replica) Decisions() []Decision {
+	return r.decisions
+}
+
+// Evidence returns the equivocations known by the replica, in reception order.
+func (r *Replica) Evidence() []*consensus.Evidence {
+	return r.evidence
+}
+
+// LastEpoch returns the last epoch started by the replica.
+func (r *Replica) LastEpoch() int64 {
+	return r.lastEpoch
+}
+
+// Crashed returns whether the replica has crashed.
+func (r *Replica) Crashed() bool {
+	return r.crashed
+}
+
+// startNewEpoch creates and starts a new epoch of consensus.
+func (r *Replica) startNewEpoch(lockedCertificate *consensus.Certificate, sentLockedCertificate bool) {
+	if r.lastEpoch-r.lastDecided >= r.sim.config.MaxActiveEpochs {
+		return
+	}
+	r.lastEpoch++
+	if r.sim.config.MaxEpochToStart > 0 && r.lastEpoch >= r.sim.config.MaxEpochToStart {
+		return
+	}
+	if reconfiguration, ok := r.sim.config.Reconfigurations[r.lastEpoch]; ok {
+		r.reconfigurations = append(r.reconfigurations, reconfiguration)
+	}
+	index := r.lastEpoch % r.sim.config.MaxActiveEpochs
+	if r.epochs[index] == nil || r.epochs[index].GetEpoch() != r.lastEpoch {
+		r.epochs[index] = r.sim.newConsensus(r.lastEpoch, r)
+	}
+	r.epochs[index].Start(lockedCertificate, sentLockedCertificate)
+}
+
+// finishEpoch stops all active epochs up to the provided one.
+func (r *Replica) finishEpoch(epoch int64) bool {
+	if epoch <= r.lastDecided {
+		return false
+	}
+	for i := epoch; i > r.lastDecided; i-- {
+		index := i % r.sim.config.MaxActiveEpochs
+		if r.epochs[index] != nil {
+			r.epochs[index].Stop()
+		}
+	}
+	r.timeouts.CancelEpochs(epoch)
+	r.lastDecided = epoch
+	return true
+}
+
+// getEpoch returns the consensus instance of an epoch, creating it if needed.
+func (r *Replica) getEpoch(epoch int64) consensus.Consensus {
+	index := epoch % r.sim.config.MaxActiveEpochs
+	if r.epochs[index] == nil || r.epochs[index].GetEpoch() != epoch {
+		r.epochs[index] = r.sim.newConsensus(epoch, r)
+	}
+	return r.epochs[index]
+}
+
+func (r *Replica) processMessage(message *consensus.Message) {
+	if r.crashed {
+		return
+	}
+	switch message.Type {
+	case consensus.BLOCK_REQUEST:
+		r.processBlockRequest(message)
+		return
+	case consensus.EVIDENCE:
+		epoch := message.Evidence.Epoch
+		if message.Evidence.Verify(r.Validators(epoch).PublicKeys(), r.Proposer(epoch)) {
+			r.addEvidence(message.Evidence)
+		}
+		return
+	}
+	if message.ValidateCertificate(r.Validators) != nil {
+		return
+	}
+	r.getEpoch(message.Epoch).ProcessMessage(message)
+}
+
+// addEvidence records an evidence, returning false if it was already known.
+func (r *Replica) addEvidence(evidence *consensus.Evidence) bool {
+	for _, e := range r.evidence {
+		if e.Equal(evidence) {
+			return false
+		}
+	}
+	r.evidence = append(r.evidence, evidence)
+	return true
+}
+
+// processBlockRequest serves a block requested by another replica, if known.
+func (r *Replica) processBlockRequest(request *consensus.Message) {
+	block := r.blockchain.GetBlock(request.Height, request.BlockID)
+	if block == nil || !block.HasPayload() {
+		return
+	}
+	r.Send(consensus.NewBlockResponseMessage(request.Epoch, block, r.id), request.Sender)
+}
+
+// triggerTimeouts processes the timeouts whose deadline is reached, unless
+// canceled meanwhile.
+func (r *Replica) triggerTimeouts() {
+	for _, timeout := range r.timeouts.Expired(r.sim.clock.Time()) {
+		r.processTimeout(timeout)
+	}
+}
+
+func (r *Replica) processTimeout(timeout *consensus.Timeout) {
+	if r.crashed {
+		return
+	}
+	r.Emit(consensus.NewTimeoutEvent(timeout))
+	r.getEpoch(timeout.Epoch).ProcessTimeout(timeout)
+}
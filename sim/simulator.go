@@ -0,0 +1,361 @@
+// Package sim provides a deterministic simulator for consensus protocols.
+//
+// The simulator runs a set of replicas implementing consensus.Process over an
+// in-memory network driven by a virtual clock. All random choices derive from
+// a single seed, so that a simulation with the same configuration, policies
+// and seed replays identically.
+package sim
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"math/rand"
+	"time"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
+	"dslab.inf.usi.ch/tendermint/crypto"
+	"dslab.inf.usi.ch/tendermint/safety"
+)
+
+// Config defines the configuration of a simulation.
+type Config struct {
+	// Number of simulated replicas.
+	NumProcesses int
+
+	// Seed for the random choices of the simulation and replicas' keys.
+	Seed int64
+
+	// When set to a positive value, defines the maximum number of epochs to start.
+	MaxEpochToStart int64
+
+	// Maximum number of epochs started but not yet decided.
+	MaxActiveEpochs int64
+
+	// Maximum number of the blockchain heights we can have in the blockchain window.
+	BlockchainSize int64
+
+	// Size in bytes of the values proposed by replicas.
+	ValueSize int
+
+	// Defining maximum communication delay in synchronous system.
+	TimeoutSmallDelta time.Duration
+	TimeoutBigDelta   time.Duration
+	FastAlterEnabled  bool
+
+	// If set to true, proposers disseminate block headers and values
+	// separately, see consensus.FastAlterBFT.
+	SeparateDissemination bool
+
+	// If set to true, proposers disseminate erasure-coded chunks of the
+	// values of their blocks, see consensus.FastAlterBFT.
+	ErasureCoding bool
+
+	// If set to true, replicas have BLS keys and send certificates with a
+	// single aggregate signature, see consensus.Certificate.Aggregate.
+	AggregateCertificates bool
+
+	// Protocol run by the replicas: FastAlterBFT if unset or "alter",
+	// chained HotStuff if "hot-stuff", Tendermint if "tendermint".
+	Model string
+
+	// Policy defining the proposer of each epoch, see consensus.NewLeaderPolicy.
+	LeaderPolicy string
+
+	// Initial voting power of each replica. If unset, every replica has
+	// power 1. Replicas with no voting power are not initially members.
+	VotingPower []int64
+
+	// Reconfigurations submitted by every replica when starting an epoch,
+	// by epoch, see consensus.Membership.
+	Reconfigurations map[int64]*consensus.Reconfiguration
+
+	// Key of the operator authorising reconfigurations, see
+	// consensus.Reconfiguration. If unset, reconfigurations are ignored.
+	OperatorKey crypto.PublicKey
+
+	// If set to true, received messages have their signatures verified and
+	// messages with invalid signatures are discarded.
+	VerifySignatures bool
+
+	// Attack run by the byzantine replicas of AttackConfig, one of
+	// consensus.Attacks(). Byzantine replicas run FastAlterBFT with the
+	// attack, correct replicas run FastAlterBFT.
+	Attack       string
+	AttackConfig consensus.AttackConfig
+
+	// Creates the consensus instance run by a replica in an epoch.
+	// If unset, replicas run FastAlterBFT.
+	NewConsensus func(epoch int64, process consensus.Process) consensus.Consensus
+
+	// If set, receives the events of the replicas, timestamped with the
+	// virtual time elapsed since the Unix epoch.
+	Events consensus.EventSink
+}
+
+// DefaultConfig returns a default configuration for a simulation.
+func DefaultConfig() *Config {
+	return &Config{
+		NumProcesses:      4,
+		Seed:              1,
+		MaxEpochToStart:   10,
+		MaxActiveEpochs:   100,
+		BlockchainSize:    100,
+		ValueSize:         64,
+		TimeoutSmallDelta: 10 * time.Millisecond,
+		TimeoutBigDelta:   50 * time.Millisecond,
+		FastAlterEnabled:  true,
+	}
+}
+
+// Simulator runs replicas over a simulated network.
+type Simulator struct {
+	config *Config
+
+	clock    *Clock
+	rnd      *rand.Rand
+	network  *network
+	replicas []*Replica
+
+	privateKeys []crypto.PrivateKey
+	publicKeys  []crypto.PublicKey
+
+	// Digest of every message delivery, in order
+	trace hash.Hash
+
+	started                            bool
+	sent, delivered, dropped, rejected int
+}
+
+// NewSimulator creates a simulation with the provided configuration.
+// By default, links deliver small messages after TimeoutSmallDelta and
+// proposals after TimeoutBigDelta, and timeouts fire after their duration.
+func NewSimulator(config *Config) *Simulator {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	s := &Simulator{
+		config: config,
+		clock:  NewClock(),
+		rnd:    rand.New(rand.NewSource(config.Seed)),
+		network: newNetwork(config.NumProcesses,
+			FixedDelay(config.TimeoutSmallDelta, config.TimeoutBigDelta),
+			ExactTimeout()),
+		trace: sha256.New(),
+	}
+	s.generateKeys()
+	for id := 0; id < config.NumProcesses; id++ {
+		s.replicas = append(s.replicas, newReplica(id, s))
+	}
+	return s
+}
+
+// Deterministic keys, secret: [seed (8 bytes), processId (4 bytes)].
+// Replicas have BLS keys if certificates are aggregated.
+func (s *Simulator) generateKeys() {
+	generate := crypto.GeneratePrivateKeyFromSecret
+	if s.config.AggregateCertificates {
+		generate = crypto.GenerateBLSPrivateKeyFromSecret
+	}
+	s.privateKeys = make([]crypto.PrivateKey, s.config.NumProcesses)
+	s.publicKeys = make([]crypto.PublicKey, s.config.NumProcesses)
+	secret := make([]byte, 8+4)
+	binary.LittleEndian.PutUint64(secret, uint64(s.config.Seed))
+	for id := range s.privateKeys {
+		binary.LittleEndian.PutUint32(secret[8:], uint32(id))
+		s.privateKeys[id] = generate(secret)
+		s.publicKeys[id] = s.privateKeys[id].PubKey()
+	}
+}
+
+// Replicas returns the simulated replicas, indexed by their IDs.
+func (s *Simulator) Replicas() []*Replica {
+	return s.replicas
+}
+
+// Clock returns the virtual clock of the simulation.
+func (s *Simulator) Clock() *Clock {
+	return s.clock
+}
+
+// PublicKeys returns the public keys of the replicas.
+func (s *Simulator) PublicKeys() []crypto.PublicKey {
+	return s.publicKeys
+}
+
+// SetLink sets the policy of the link from a replica to another.
+func (s *Simulator) SetLink(from, to int, policy LinkPolicy) {
+	s.network.links[from][to] = policy
+}
+
+// SetLinks sets the policy of every link between distinct replicas.
+func (s *Simulator) SetLinks(policy LinkPolicy) {
+	for from := 0; from < s.config.NumProcesses; from++ {
+		for to := 0; to < s.config.NumProcesses; to++ {
+			s.network.links[from][to] = policy
+		}
+	}
+}
+
+// SetTimeouts sets the policy for the timeouts scheduled by a replica.
+func (s *Simulator) SetTimeouts(id int, policy TimeoutPolicy) {
+	s.network.timeouts[id] = policy
+}
+
+// Crash stops a replica at the provided virtual time.
+// A crashed replica does not send nor process messages and timeouts.
+func (s *Simulator) Crash(id int, at time.Duration) {
+	if at <= s.clock.Now() {
+		s.replicas[id].crashed = true
+		return
+	}
+	s.clock.After(at-s.clock.Now(), func() {
+		s.replicas[id].crashed = true
+	})
+}
+
+// Run starts the first epoch at every replica, then runs the simulation until
+// there are no more events to process or the virtual time exceeds maxTime.
+func (s *Simulator) Run(maxTime time.Duration) {
+	if !s.started {
+		s.started = true
+		for _, r := range s.replicas {
+			if !r.crashed {
+				r.startNewEpoch(nil, true)
+			}
+		}
+	}
+	for {
+		next, ok := s.clock.Next()
+		if !ok || next > maxTime {
+			return
+		}
+		s.clock.Step()
+	}
+}
+
+// Digest returns a digest of the sequence of messages delivered so far.
+// Simulations that replay identically have the same digest.
+func (s *Simulator) Digest() []byte {
+	return s.trace.Sum(nil)
+}
+
+// Stats returns the number of messages sent, delivered, dropped by the
+// network and rejected due to invalid signatures.
+func (s *Simulator) Stats() (sent, delivered, dropped, rejected int) {
+	return s.sent, s.delivered, s.dropped, s.rejected
+}
+
+// Checker returns a safety checker with the blocks delivered by the replicas.
+func (s *Simulator) Checker() *safety.Checker {
+	checker := safety.NewChecker()
+	for _, r := range s.replicas {
+		for _, d := range r.decisions {
+			checker.AddBlock(r.id, d.Epoch, d.Block)
+		}
+	}
+	return checker
+}
+
+func (s *Simulator) newConsensus(epoch int64, process *Replica) consensus.Consensus {
+	if s.config.NewConsensus != nil {
+		return s.config.NewConsensus(epoch, process)
+	}
+	if s.config.Model == "hot-stuff" {
+		return consensus.NewHotStuff(epoch, process, process.hotStuff)
+	}
+	if s.config.Model == "tendermint" {
+		return consensus.NewTendermint(epoch, process, process.tendermint)
+	}
+	c := consensus.NewFastAlterBFT(epoch, process, s.config.FastAlterEnabled)
+	c.SeparateDissemination = s.config.SeparateDissemination
+	c.ErasureCoding = s.config.ErasureCoding
+	c.AggregateCertificates = s.config.AggregateCertificates
+	if !s.config.AttackConfig.Byzantines[process.ID()] {
+		return c
+	}
+	attack := consensus.NewAttack(s.config.Attack)
+	// FIXME: anything better than panicing here?
+	if attack == nil {
+		panic("Unknown byzantine attack " + s.config.Attack)
+	}
+	return consensus.NewAttacker(c, attack, s.config.AttackConfig)
+}
+
+func (s *Simulator) sign(message *consensus.Message) {
+	if message.Sender >= 0 && message.Sender < len(s.privateKeys) {
+		message.Sign(s.privateKeys[message.Sender])
+	}
+}
+
+func (s *Simulator) broadcast(from int, message *consensus.Message) {
+	for to := 0; to < s.config.NumProcesses; to++ {
+		s.transmit(from, to, message)
+	}
+}
+
+func (s *Simulator) send(from int, message *consensus.Message, ids ...int) {
+	for _, to := range ids {
+		s.transmit(from, to, message)
+	}
+}
+
+// transmit schedules the delivery of a copy of the encoded message, as
+// received messages are retained and modified by consensus instances.
+func (s *Simulator) transmit(from, to int, message *consensus.Message) {
+	if s.replicas[from].crashed {
+		return
+	}
+	s.sent++
+	delay := s.network.delay(&Envelope{From: from, To: to, Message: message}, s.rnd)
+	if delay < 0 {
+		s.dropped++
+		return
+	}
+	buffer := make([]byte, len(message.Marshall()))
+	copy(buffer, message.Marshall())
+	s.clock.After(delay, func() {
+		s.deliver(from, to, buffer)
+	})
+}
+
+func (s *Simulator) deliver(from, to int, buffer []byte) {
+	if s.replicas[to].crashed {
+		s.dropped++
+		return
+	}
+	message := consensus.MessageFromBytes(buffer)
+	if s.config.VerifySignatures && !s.verify(to, message) {
+		s.rejected++
+		return
+	}
+	s.delivered++
+	var header [24]byte
+	binary.LittleEndian.PutUint64(header[0:], uint64(s.clock.Now()))
+	binary.LittleEndian.PutUint64(header[8:], uint64(from))
+	binary.LittleEndian.PutUint64(header[16:], uint64(to))
+	s.trace.Write(header[:])
+	s.trace.Write(buffer)
+	s.replicas[to].processMessage(message)
+}
+
+// Verifies signatures with the keys of the receiver's validator set.
+func (s *Simulator) verify(to int, message *consensus.Message) bool {
+	keys := s.replicas[to].Validators(message.Epoch).PublicKeys()
+	for _, sig := range message.GetCryptoSignatures() {
+		if !sig.Verify(keys) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Simulator) schedule(id int, timeout *consensus.Timeout) {
+	delay := s.network.timeouts[id](id, timeout, s.rnd)
+	if delay < 0 {
+		return
+	}
+	replica := s.replicas[id]
+	replica.timeouts.Push(timeout, s.clock.Time().Add(delay))
+	s.clock.After(delay, replica.triggerTimeouts)
+}
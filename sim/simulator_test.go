@@ -0,0 +1,627 @@
+package sim
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
+	"dslab.inf.usi.ch/tendermint/crypto"
+	"dslab.inf.usi.ch/tendermint/safety"
+)
+
+func testConfig(seed int64) *Config {
+	config := DefaultConfig()
+	config.Seed = seed
+	return config
+}
+
+// testAgreement checks that replicas delivered the same chain.
+func testAgreement(t *testing.T, s *Simulator) {
+	t.Helper()
+	checker := s.Checker()
+	if checker.Commits() == 0 {
+		t.Error("Expected replicas to deliver blocks")
+	}
+	if violations := checker.Check(); len(violations) > 0 {
+		t.Error(safety.Report(violations))
+	}
+}
+
+func TestSimulatorDecides(t *testing.T) {
+	s := NewSimulator(testConfig(1))
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	for _, r := range s.Replicas() {
+		if len(r.Decisions()) != int(s.config.MaxEpochToStart) {
+			t.Errorf("Replica %v delivered %v blocks, expected %v",
+				r.ID(), len(r.Decisions()), s.config.MaxEpochToStart)
+		}
+	}
+}
+
+func TestSimulatorReplay(t *testing.T) {
+	run := func(seed int64) *Simulator {
+		config := testConfig(seed)
+		s := NewSimulator(config)
+		s.SetLinks(UniformDelay(config.TimeoutSmallDelta, config.TimeoutBigDelta))
+		s.Run(time.Minute)
+		return s
+	}
+	s1, s2 := run(7), run(7)
+	if !bytes.Equal(s1.Digest(), s2.Digest()) {
+		t.Error("Simulations with the same seed have different traces")
+	}
+	for i, r := range s1.Replicas() {
+		d1, d2 := r.Decisions(), s2.Replicas()[i].Decisions()
+		if len(d1) != len(d2) {
+			t.Fatalf("Replica %v delivered %v and %v blocks", i, len(d1), len(d2))
+		}
+		for j := range d1 {
+			if !d1[j].Block.Equal(d2[j].Block) || d1[j].Time != d2[j].Time {
+				t.Errorf("Replica %v delivery %v differs: %v %v", i, j, d1[j], d2[j])
+			}
+		}
+	}
+	if s3 := run(8); bytes.Equal(s1.Digest(), s3.Digest()) {
+		t.Error("Simulations with different seeds have the same trace")
+	}
+}
+
+func TestSimulatorCrashedLeader(t *testing.T) {
+	config := testConfig(3)
+	config.MaxEpochToStart = 6
+	s := NewSimulator(config)
+	// Replica 1 is the proposer of epochs 1 and 5
+	s.Crash(1, 0)
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	for _, r := range s.Replicas() {
+		if r.Crashed() {
+			continue
+		}
+		if r.LastEpoch() < config.MaxEpochToStart {
+			t.Errorf("Replica %v stuck in epoch %v", r.ID(), r.LastEpoch())
+		}
+		for _, d := range r.Decisions() {
+			if d.Epoch == 1 || d.Epoch == 5 {
+				t.Errorf("Replica %v decided in epoch %v of crashed leader", r.ID(), d.Epoch)
+			}
+		}
+	}
+}
+
+func TestSimulatorVotingPower(t *testing.T) {
+	config := testConfig(5)
+	config.NumProcesses = 4
+	config.MaxEpochToStart = 6
+	config.VotingPower = []int64{3, 3, 1, 1}
+	s := NewSimulator(config)
+	// Replicas 0 and 1 hold a majority of the voting power
+	s.Crash(2, 0)
+	s.Crash(3, 0)
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	for _, r := range s.Replicas()[:2] {
+		if r.LastEpoch() < config.MaxEpochToStart {
+			t.Errorf("Replica %v stuck in epoch %v", r.ID(), r.LastEpoch())
+		}
+	}
+}
+
+func TestSimulatorReconfiguration(t *testing.T) {
+	config := testConfig(5)
+	config.NumProcesses = 5
+	config.MaxEpochToStart = 12
+	config.MaxActiveEpochs = 4
+	config.VerifySignatures = true
+	// Replica 4 joins and replica 1 leaves, from epoch 0+4
+	config.VotingPower = []int64{1, 1, 1, 1, 0}
+	operator := crypto.GeneratePrivateKey()
+	config.OperatorKey = operator.PubKey()
+	s := NewSimulator(config)
+	reconfiguration := &consensus.Reconfiguration{Sequence: 1, Updates: []consensus.ValidatorUpdate{
+		{ID: 4, VotingPower: 1, PublicKey: s.PublicKeys()[4]},
+		{ID: 1, VotingPower: 0},
+	}}
+	reconfiguration.Sign(operator)
+	config.Reconfigurations = map[int64]*consensus.Reconfiguration{0: reconfiguration}
+	s.Crash(1, 0)
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	r := s.Replicas()[4]
+	if members := r.Validators(4).Members(); len(members) != 4 || members[3] != 4 {
+		t.Fatal("Expected members 0, 2, 3 and 4 from epoch 4, got", members)
+	}
+	proposed := false
+	for _, d := range r.Decisions() {
+		if d.Epoch >= 4 && r.Proposer(d.Epoch) == 4 {
+			proposed = true
+		}
+	}
+	if !proposed {
+		t.Error("Expected blocks proposed by replica 4 to be delivered")
+	}
+	// Epochs of the crashed replica 1 fail only before the reconfiguration
+	if n := len(r.Decisions()); n != int(config.MaxEpochToStart)-1 {
+		t.Error("Expected", config.MaxEpochToStart-1, "blocks to be delivered, got", n)
+	}
+}
+
+func TestSimulatorLeaderPolicies(t *testing.T) {
+	decided := make(map[string]int)
+	for _, policy := range []string{consensus.ROUND_ROBIN, consensus.SEEDED_RANDOM, consensus.REPUTATION} {
+		config := testConfig(3)
+		config.MaxEpochToStart = 16
+		config.MaxActiveEpochs = 4
+		config.LeaderPolicy = policy
+		s := NewSimulator(config)
+		s.Crash(1, 0)
+		s.Run(time.Minute)
+		t.Log("Policy", policy)
+		testAgreement(t, s)
+		decided[policy] = len(s.Replicas()[0].Decisions())
+	}
+	// The crashed replica is skipped after the first epoch it fails
+	if decided[consensus.REPUTATION] <= decided[consensus.ROUND_ROBIN] {
+		t.Error("Expected reputation policy to skip the crashed leader, delivered",
+			decided[consensus.REPUTATION], "and", decided[consensus.ROUND_ROBIN], "blocks")
+	}
+}
+
+func TestSimulatorDroppedLinks(t *testing.T) {
+	config := testConfig(4)
+	s := NewSimulator(config)
+	base := FixedDelay(config.TimeoutSmallDelta, config.TimeoutBigDelta)
+	// Replica 3 does not receive the votes of replica 0 in epochs 2 to 4.
+	s.SetLink(0, 3, func(env *Envelope, rnd *rand.Rand) time.Duration {
+		if env.Message.Type == consensus.VOTE {
+			return DropEpochs(base, 2, 4)(env, rnd)
+		}
+		return base(env, rnd)
+	})
+	s.SetLink(2, 3, DropTypes(base, consensus.PROPOSE))
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	_, _, dropped, _ := s.Stats()
+	if dropped == 0 {
+		t.Error("Expected messages to be dropped")
+	}
+}
+
+func TestSimulatorMissingProposals(t *testing.T) {
+	config := testConfig(6)
+	s := NewSimulator(config)
+	base := FixedDelay(config.TimeoutSmallDelta, config.TimeoutBigDelta)
+	// Replica 3 does not receive the proposals of epochs 2 to 4, neither
+	// from the proposer nor forwarded, so it has to request the blocks.
+	for id := 0; id < config.NumProcesses; id++ {
+		s.SetLink(id, 3, func(env *Envelope, rnd *rand.Rand) time.Duration {
+			if env.Message.Type == consensus.PROPOSE {
+				return DropEpochs(base, 2, 4)(env, rnd)
+			}
+			return base(env, rnd)
+		})
+	}
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	for _, r := range s.Replicas() {
+		if len(r.Decisions()) != int(config.MaxEpochToStart) {
+			t.Errorf("Replica %v delivered %v blocks, expected %v",
+				r.ID(), len(r.Decisions()), config.MaxEpochToStart)
+		}
+	}
+}
+
+func TestSimulatorSeparateDissemination(t *testing.T) {
+	config := testConfig(6)
+	config.SeparateDissemination = true
+	config.VerifySignatures = true
+	s := NewSimulator(config)
+	base := FixedDelay(config.TimeoutSmallDelta, config.TimeoutBigDelta)
+	// Replica 3 does not receive the values of epochs 2 to 4, so it has to
+	// request them to commit the blocks of later epochs.
+	for id := 0; id < config.NumProcesses; id++ {
+		s.SetLink(id, 3, func(env *Envelope, rnd *rand.Rand) time.Duration {
+			if env.Message.Type == consensus.PAYLOAD {
+				return DropEpochs(base, 2, 4)(env, rnd)
+			}
+			return base(env, rnd)
+		})
+	}
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	for _, r := range s.Replicas() {
+		if len(r.Decisions()) != int(config.MaxEpochToStart) {
+			t.Errorf("Replica %v delivered %v blocks, expected %v",
+				r.ID(), len(r.Decisions()), config.MaxEpochToStart)
+		}
+		for _, d := range r.Decisions() {
+			if !d.Block.HasPayload() || len(d.Block.Value) != config.ValueSize {
+				t.Errorf("Replica %v delivered block %v without value", r.ID(), d.Block.Height)
+			}
+		}
+	}
+}
+
+func TestSimulatorDroppedBlockRequests(t *testing.T) {
+	config := testConfig(6)
+	config.SeparateDissemination = true
+	s := NewSimulator(config)
+	base := FixedDelay(config.TimeoutSmallDelta, config.TimeoutBigDelta)
+	// Replica 3 does not receive the values of epochs 2 to 4, and its first
+	// request of each block in each epoch is dropped, as if over the rate
+	// of its peers.
+	for id := 0; id < config.NumProcesses; id++ {
+		s.SetLink(id, 3, func(env *Envelope, rnd *rand.Rand) time.Duration {
+			if env.Message.Type == consensus.PAYLOAD {
+				return DropEpochs(base, 2, 4)(env, rnd)
+			}
+			return base(env, rnd)
+		})
+	}
+	requests := make(map[string]*consensus.Message)
+	for id := 0; id < config.NumProcesses; id++ {
+		s.SetLink(3, id, func(env *Envelope, rnd *rand.Rand) time.Duration {
+			if env.Message.Type == consensus.BLOCK_REQUEST {
+				key := fmt.Sprint(env.Message.Epoch, env.Message.BlockID)
+				first, ok := requests[key]
+				if !ok {
+					requests[key] = env.Message
+					return Dropped
+				}
+				if first == env.Message {
+					return Dropped
+				}
+			}
+			return base(env, rnd)
+		})
+	}
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	if len(requests) == 0 {
+		t.Error("Expected replica 3 to request blocks")
+	}
+	for _, r := range s.Replicas() {
+		if len(r.Decisions()) != int(config.MaxEpochToStart) {
+			t.Errorf("Replica %v delivered %v blocks, expected %v",
+				r.ID(), len(r.Decisions()), config.MaxEpochToStart)
+		}
+	}
+}
+
+func TestSimulatorErasureCoding(t *testing.T) {
+	config := testConfig(7)
+	config.ErasureCoding = true
+	config.VerifySignatures = true
+	config.ValueSize = 1000
+	s := NewSimulator(config)
+	base := FixedDelay(config.TimeoutSmallDelta, config.TimeoutBigDelta)
+	// Replica 3 does not receive its chunks, it has to reconstruct values
+	// from the chunks re-broadcast by the other replicas.
+	for id := 0; id < config.NumProcesses; id++ {
+		s.SetLink(id, 3, func(env *Envelope, rnd *rand.Rand) time.Duration {
+			if env.Message.Type == consensus.CHUNK && env.Message.Chunk.Index == 3 {
+				return Dropped
+			}
+			return base(env, rnd)
+		})
+	}
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	for _, r := range s.Replicas() {
+		if len(r.Decisions()) != int(config.MaxEpochToStart) {
+			t.Errorf("Replica %v delivered %v blocks, expected %v",
+				r.ID(), len(r.Decisions()), config.MaxEpochToStart)
+		}
+		for _, d := range r.Decisions() {
+			if len(d.Block.Value) != config.ValueSize {
+				t.Errorf("Replica %v delivered block %v without value", r.ID(), d.Block.Height)
+			}
+		}
+	}
+}
+
+func TestSimulatorAggregateCertificates(t *testing.T) {
+	config := testConfig(5)
+	config.MaxEpochToStart = 6
+	config.AggregateCertificates = true
+	config.VerifySignatures = true
+	s := NewSimulator(config)
+	// Replica 1 is the proposer of epochs 1 and 5, silence certificates
+	// are formed in those epochs.
+	s.Crash(1, 0)
+	base := FixedDelay(config.TimeoutSmallDelta, config.TimeoutBigDelta)
+	aggregated := make(map[int16]int)
+	for id := 0; id < config.NumProcesses; id++ {
+		s.SetLink(0, id, func(env *Envelope, rnd *rand.Rand) time.Duration {
+			if env.Message.Type == consensus.QUIT_EPOCH && env.Message.Certificate.Aggregated() {
+				aggregated[env.Message.Certificate.Type]++
+			}
+			return base(env, rnd)
+		})
+	}
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	if _, _, _, rejected := s.Stats(); rejected != 0 {
+		t.Error("Rejected messages with aggregate certificates:", rejected)
+	}
+	if aggregated[consensus.BLOCK_CERT] == 0 || aggregated[consensus.SILENCE_CERT] == 0 {
+		t.Error("Expected aggregate block and silence certificates, got", aggregated)
+	}
+	for _, r := range s.Replicas() {
+		if !r.Crashed() && r.LastEpoch() < config.MaxEpochToStart {
+			t.Errorf("Replica %v stuck in epoch %v", r.ID(), r.LastEpoch())
+		}
+	}
+}
+
+func TestSimulatorTimeouts(t *testing.T) {
+	config := testConfig(5)
+	config.MaxEpochToStart = 3
+	s := NewSimulator(config)
+	s.Crash(0, 0)
+	for id := range s.Replicas() {
+		s.SetTimeouts(id, NoTimeout())
+	}
+	s.Run(time.Minute)
+	// Without timeouts, no replica can leave the epoch of the crashed leader.
+	for _, r := range s.Replicas()[1:] {
+		if r.LastEpoch() != 0 || len(r.Decisions()) != 0 {
+			t.Errorf("Replica %v left epoch 0 without timeouts", r.ID())
+		}
+	}
+}
+
+func TestSimulatorCancelsTimeouts(t *testing.T) {
+	config := testConfig(4)
+	s := NewSimulator(config)
+	s.Crash(1, 0)
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	// The timeouts of the finished epochs are no longer scheduled
+	for _, r := range s.Replicas() {
+		if len(r.Decisions()) == 0 {
+			continue
+		}
+		for _, timeout := range r.timeouts.Expired(time.Unix(1<<40, 0)) {
+			if timeout.Epoch <= r.lastDecided {
+				t.Errorf("Replica %v has a timeout of epoch %v, finished epoch %v",
+					r.ID(), timeout.Epoch, r.lastDecided)
+			}
+		}
+	}
+}
+
+func TestSimulatorByzantineLeaders(t *testing.T) {
+	for _, attack := range []string{consensus.SILENCE_ATTACK, consensus.EQUIVOCATION_ATTACK} {
+		config := testConfig(6)
+		config.Attack = attack
+		config.AttackConfig.Byzantines = map[int]bool{1: true}
+		s := NewSimulator(config)
+		s.Run(time.Minute)
+		t.Log("Attack", attack)
+		testAgreement(t, s)
+		if attack != consensus.EQUIVOCATION_ATTACK {
+			continue
+		}
+		// Every replica learns that the byzantine leader has equivocated
+		for _, r := range s.Replicas() {
+			if len(r.Evidence()) == 0 || r.Evidence()[0].Proposer != 1 {
+				t.Errorf("Replica %v has no evidence against replica 1: %v", r.ID(), r.Evidence())
+			}
+		}
+	}
+}
+
+func TestSimulatorAttacks(t *testing.T) {
+	for _, attack := range consensus.Attacks() {
+		config := testConfig(7)
+		config.NumProcesses = 7
+		config.Attack = attack
+		config.AttackConfig = consensus.AttackConfig{
+			Byzantines: map[int]bool{1: true, 4: true},
+			Delay:      3 * config.TimeoutBigDelta,
+			Partition:  []int{0, 2, 3},
+		}
+		s := NewSimulator(config)
+		s.Run(time.Minute)
+		t.Log("Attack", attack)
+		testAgreement(t, s)
+		for _, r := range s.Replicas() {
+			if !config.AttackConfig.Byzantines[r.ID()] && len(r.Decisions()) == 0 {
+				t.Errorf("Replica %v decided no block under attack %v", r.ID(), attack)
+			}
+		}
+	}
+}
+
+func TestSimulatorHotStuff(t *testing.T) {
+	config := testConfig(9)
+	config.Model = "hot-stuff"
+	s := NewSimulator(config)
+	s.SetLinks(UniformDelay(config.TimeoutSmallDelta, config.TimeoutBigDelta))
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	// The blocks of the last three epochs do not head a three-chain
+	for _, r := range s.Replicas() {
+		if len(r.Decisions()) != int(config.MaxEpochToStart)-3 {
+			t.Errorf("Replica %v delivered %v blocks, expected %v",
+				r.ID(), len(r.Decisions()), config.MaxEpochToStart-3)
+		}
+	}
+}
+
+func TestSimulatorHotStuffCrashedLeader(t *testing.T) {
+	config := testConfig(3)
+	config.Model = "hot-stuff"
+	config.NumProcesses = 7
+	config.MaxEpochToStart = 20
+	s := NewSimulator(config)
+	// Blocks are committed in the epochs of the other six proposers
+	s.Crash(1, 0)
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	for _, r := range s.Replicas() {
+		if r.Crashed() {
+			continue
+		}
+		if r.LastEpoch() < config.MaxEpochToStart {
+			t.Errorf("Replica %v stuck in epoch %v", r.ID(), r.LastEpoch())
+		}
+		for _, d := range r.Decisions() {
+			if s.Replicas()[0].Proposer(d.Block.Epoch) == 1 {
+				t.Errorf("Replica %v decided a block of crashed leader in epoch %v", r.ID(), d.Block.Epoch)
+			}
+		}
+	}
+}
+
+func TestSimulatorHotStuffMissingProposals(t *testing.T) {
+	config := testConfig(6)
+	config.Model = "hot-stuff"
+	config.MaxEpochToStart = 12
+	s := NewSimulator(config)
+	base := FixedDelay(config.TimeoutSmallDelta, config.TimeoutBigDelta)
+	// Replica 3 does not receive the proposals of epochs 2 to 4, so it has
+	// to request the blocks to commit.
+	for id := 0; id < config.NumProcesses; id++ {
+		s.SetLink(id, 3, func(env *Envelope, rnd *rand.Rand) time.Duration {
+			if env.Message.Type == consensus.PROPOSE {
+				return DropEpochs(base, 2, 4)(env, rnd)
+			}
+			return base(env, rnd)
+		})
+	}
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	decisions := s.Replicas()[0].Decisions()
+	if len(s.Replicas()[3].Decisions()) == 0 ||
+		!s.Replicas()[3].Decisions()[0].Block.Equal(decisions[0].Block) {
+		t.Error("Replica 3 did not deliver the chain of the other replicas")
+	}
+}
+
+func TestSimulatorTendermint(t *testing.T) {
+	config := testConfig(10)
+	config.Model = "tendermint"
+	s := NewSimulator(config)
+	s.SetLinks(UniformDelay(config.TimeoutSmallDelta, config.TimeoutBigDelta))
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	// Proposals are received before the propose timeout expires
+	for _, r := range s.Replicas() {
+		if len(r.Decisions()) != int(config.MaxEpochToStart) {
+			t.Errorf("Replica %v delivered %v blocks, expected %v",
+				r.ID(), len(r.Decisions()), config.MaxEpochToStart)
+		}
+	}
+}
+
+func TestSimulatorTendermintCrashedLeader(t *testing.T) {
+	config := testConfig(11)
+	config.Model = "tendermint"
+	s := NewSimulator(config)
+	s.Crash(1, 0)
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	for _, r := range s.Replicas() {
+		if r.Crashed() {
+			continue
+		}
+		if r.LastEpoch() < config.MaxEpochToStart {
+			t.Errorf("Replica %v stuck in epoch %v", r.ID(), r.LastEpoch())
+		}
+		for _, d := range r.Decisions() {
+			if s.Replicas()[0].Proposer(d.Epoch) == 1 {
+				t.Errorf("Replica %v decided in the epoch %v of crashed leader", r.ID(), d.Epoch)
+			}
+		}
+	}
+}
+
+func TestSimulatorTendermintValidBlock(t *testing.T) {
+	config := testConfig(12)
+	config.Model = "tendermint"
+	config.MaxEpochToStart = 8
+	s := NewSimulator(config)
+	base := FixedDelay(config.TimeoutSmallDelta, config.TimeoutBigDelta)
+	// In epoch 2, replica 3 does not receive the proposal and precommits nil,
+	// while every replica misses a precommit for the block: replicas 0 to 2
+	// lock on the block of epoch 2, which the proposer of epoch 4 proposes
+	// again.
+	s.SetLink(2, 3, func(env *Envelope, rnd *rand.Rand) time.Duration {
+		if env.Message.Type == consensus.PROPOSE {
+			return DropEpochs(base, 2, 2)(env, rnd)
+		}
+		return base(env, rnd)
+	})
+	for _, link := range [][2]int{{1, 0}, {2, 1}, {0, 2}, {0, 3}} {
+		s.SetLink(link[0], link[1], func(env *Envelope, rnd *rand.Rand) time.Duration {
+			if env.Message.Type == consensus.PRECOMMIT {
+				return DropEpochs(base, 2, 2)(env, rnd)
+			}
+			return base(env, rnd)
+		})
+	}
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	for _, r := range s.Replicas() {
+		decided := false
+		for _, d := range r.Decisions() {
+			if d.Epoch == 2 || d.Epoch == 3 {
+				t.Errorf("Replica %v decided in epoch %v", r.ID(), d.Epoch)
+			}
+			if d.Epoch == 4 {
+				decided = d.Block.Epoch == 2
+			}
+		}
+		if !decided {
+			t.Errorf("Replica %v did not decide the block of epoch 2 in epoch 4", r.ID())
+		}
+	}
+}
+
+// eventLog records the events of the replicas.
+type eventLog []*consensus.Event
+
+func (l *eventLog) Emit(event *consensus.Event) {
+	*l = append(*l, event)
+}
+
+func TestSimulatorEvents(t *testing.T) {
+	config := testConfig(13)
+	events := &eventLog{}
+	config.Events = events
+	s := NewSimulator(config)
+	s.Crash(1, 0)
+	s.Run(time.Minute)
+	testAgreement(t, s)
+	counts := make(map[string]int)
+	decided := make(map[int]int)
+	for _, event := range *events {
+		counts[event.Type]++
+		if event.Type == consensus.DECIDED_EVENT {
+			decided[event.Process]++
+		}
+		if event.Time.Before(time.Unix(0, 0)) {
+			t.Errorf("Event %v of replica %v before the start", event.Type, event.Process)
+		}
+	}
+	for _, r := range s.Replicas() {
+		if decided[r.ID()] != len(r.Decisions()) {
+			t.Errorf("Replica %v reported %v decisions, expected %v",
+				r.ID(), decided[r.ID()], len(r.Decisions()))
+		}
+	}
+	// The epochs of the crashed proposer finish with silence certificates
+	for _, eventType := range []string{consensus.PROPOSAL_RECEIVED_EVENT, consensus.VOTED_EVENT,
+		consensus.CERTIFICATE_EVENT, consensus.SILENCE_CERTIFICATE_EVENT,
+		consensus.EPOCH_FINISHED_EVENT, consensus.TIMEOUT_EVENT} {
+		if counts[eventType] == 0 {
+			t.Errorf("No %v event reported", eventType)
+		}
+	}
+}
@@ -1,10 +1,46 @@
 package tendermint
 
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
+)
+
+// Phases of an epoch, whose latencies from the start of the epoch are
+// measured.
+const (
+	ProposalPhase    = iota // proposal received
+	VotePhase               // vote sent
+	CertificatePhase        // block certificate formed
+	DecisionPhase           // block decided
+	DeliveryPhase           // block delivered
+	numPhases
+)
+
 // Stats for a process.
 type Stats struct {
-	Instances  [3]int  // Started, Decided, Delivered
-	Messages   [11]int // PROPOSAL, PREVOTE, PRECOMMIT, VALUE
-	Deliveries [2]int  // Blocks, Transactions
+	Instances  [3]int                       // Started, Decided, Delivered
+	Messages   [consensus.PRECOMMIT + 1]int // By message type
+	Deliveries [2]int                       // Blocks, Transactions
+	Deltas     [2]time.Duration             // Small, Big, when published
+
+	// Latencies of the phases of the epochs, by phase, when published
+	Latencies  [numPhases]Percentiles
+	Histograms [numPhases]Histogram
+	// Decisions of the fast path, with the votes of all the processes, and
+	// of the slow path, after the equivocation timeout. Not recorded for
+	// models without fast path.
+	Paths [2]int
+
+	// Delays of control and bulk messages from their reception to their
+	// processing, when published
+	QueueDelays     [2]Percentiles
+	QueueHistograms [2]Histogram
+
+	latencies   [numPhases][]time.Duration
+	queueDelays [2][]time.Duration
 }
 
 func NewStats() *Stats {
@@ -30,3 +66,183 @@ func (s *Stats) InstanceDelivered(txs int) {
 func (s *Stats) MessageReceived(mtype int16) {
 	s.Messages[mtype] += 1
 }
+
+// MessageQueued records the delay of a control or bulk message from its
+// reception to its processing.
+func (s *Stats) MessageQueued(control bool, delay time.Duration) {
+	if control {
+		s.queueDelays[0] = append(s.queueDelays[0], delay)
+	} else {
+		s.queueDelays[1] = append(s.queueDelays[1], delay)
+	}
+}
+
+// PhaseReached records the latency of a phase of an epoch from its start.
+func (s *Stats) PhaseReached(phase int, latency time.Duration) {
+	s.latencies[phase] = append(s.latencies[phase], latency)
+}
+
+// DecidedOnPath records the path on which a block has been decided.
+func (s *Stats) DecidedOnPath(fast bool) {
+	if fast {
+		s.Paths[0] += 1
+	} else {
+		s.Paths[1] += 1
+	}
+}
+
+// computeLatencies summarizes the latencies and queueing delays recorded
+// since the stats were created.
+func (s *Stats) computeLatencies() {
+	for phase, latencies := range s.latencies {
+		s.Latencies[phase] = NewPercentiles(latencies)
+		s.Histograms[phase] = NewHistogram(latencies)
+	}
+	for class, delays := range s.queueDelays {
+		s.QueueDelays[class] = NewPercentiles(delays)
+		s.QueueHistograms[class] = NewHistogram(delays)
+	}
+}
+
+// Upper bounds of the buckets of latency histograms.
+var LatencyBuckets = []time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+	500 * time.Millisecond, time.Second, 2500 * time.Millisecond,
+	5 * time.Second, 10 * time.Second,
+}
+
+// Histogram counts a set of latencies by bucket of LatencyBuckets, the last
+// count being of the latencies above all the buckets, and sums them.
+type Histogram struct {
+	Counts []int
+	Sum    time.Duration
+}
+
+// NewHistogram counts a set of latencies by bucket.
+func NewHistogram(latencies []time.Duration) Histogram {
+	h := Histogram{Counts: make([]int, len(LatencyBuckets)+1)}
+	for _, latency := range latencies {
+		h.Counts[sort.Search(len(LatencyBuckets), func(i int) bool {
+			return latency <= LatencyBuckets[i]
+		})] += 1
+		h.Sum += latency
+	}
+	return h
+}
+
+// Add the counts and sum of another histogram to the histogram.
+func (h *Histogram) Add(hh Histogram) {
+	if h.Counts == nil {
+		h.Counts = make([]int, len(LatencyBuckets)+1)
+	}
+	for i, count := range hh.Counts {
+		h.Counts[i] += count
+	}
+	h.Sum += hh.Sum
+}
+
+// Percentiles summarizes a set of latencies.
+type Percentiles struct {
+	Count         int
+	P50, P90, P99 time.Duration
+	Max           time.Duration
+}
+
+// NewPercentiles computes the nearest-rank percentiles of a set of latencies.
+func NewPercentiles(latencies []time.Duration) Percentiles {
+	if len(latencies) == 0 {
+		return Percentiles{}
+	}
+	sorted := make([]time.Duration, len(latencies))
+	copy(sorted, latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := func(p int) time.Duration {
+		return sorted[(p*len(sorted)+99)/100-1]
+	}
+	return Percentiles{
+		Count: len(sorted),
+		P50:   rank(50),
+		P90:   rank(90),
+		P99:   rank(99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+func (p Percentiles) String() string {
+	return fmt.Sprintf("{%v %v %v %v %v}", p.Count, p.P50, p.P90, p.P99, p.Max)
+}
+
+// epochTiming is the start of an epoch and the phases it has reached.
+type epochTiming struct {
+	start   time.Time
+	reached [numPhases]bool
+}
+
+// Phases reached by the events of consensus instances.
+var eventPhases = map[string]int{
+	consensus.PROPOSAL_RECEIVED_EVENT: ProposalPhase,
+	consensus.VOTED_EVENT:             VotePhase,
+	consensus.CERTIFICATE_EVENT:       CertificatePhase,
+	consensus.DECIDED_EVENT:           DecisionPhase,
+}
+
+// EpochTimer measures the latencies of the phases of the epochs of a process,
+// the first time each phase is reached.
+type EpochTimer struct {
+	stats  func() *Stats
+	epochs map[int64]*epochTiming
+
+	// Whether the consensus model decides on a fast path, decisions are
+	// recorded by path only if so
+	fastPath bool
+}
+
+// NewEpochTimer creates a timer recording latencies in the current stats.
+func NewEpochTimer(stats func() *Stats, fastPath bool) *EpochTimer {
+	return &EpochTimer{
+		stats:    stats,
+		epochs:   make(map[int64]*epochTiming),
+		fastPath: fastPath,
+	}
+}
+
+// Start records the start of an epoch.
+func (t *EpochTimer) Start(epoch int64, now time.Time) {
+	t.epochs[epoch] = &epochTiming{start: now}
+}
+
+// Reached records that an epoch has reached a phase, returns whether the
+// phase is reached for the first time.
+func (t *EpochTimer) Reached(epoch int64, phase int, now time.Time) bool {
+	timing := t.epochs[epoch]
+	if timing == nil || timing.reached[phase] {
+		return false
+	}
+	timing.reached[phase] = true
+	t.stats().PhaseReached(phase, now.Sub(timing.start))
+	return true
+}
+
+// Event records the phase reached by an event, if any, and the path of
+// decisions.
+func (t *EpochTimer) Event(event *consensus.Event) {
+	phase, ok := eventPhases[event.Type]
+	if !ok {
+		return
+	}
+	if t.Reached(event.Epoch, phase, event.Time) && phase == DecisionPhase && t.fastPath {
+		t.stats().DecidedOnPath(event.FastPath)
+	}
+}
+
+// Delivered records the delivery of the block decided in an epoch and
+// forgets the epochs up to it.
+func (t *EpochTimer) Delivered(epoch int64, now time.Time) {
+	t.Reached(epoch, DeliveryPhase, now)
+	for e := range t.epochs {
+		if e <= epoch {
+			delete(t.epochs, e)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package tendermint
+
+import (
+	"testing"
+	"time"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
+)
+
+func TestPercentiles(t *testing.T) {
+	if p := NewPercentiles(nil); p.Count != 0 || p.Max != 0 {
+		t.Error("Expected empty percentiles, got", p)
+	}
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	p := NewPercentiles(latencies)
+	ms := time.Millisecond
+	if p.Count != 100 || p.P50 != 50*ms || p.P90 != 90*ms || p.P99 != 99*ms || p.Max != 100*ms {
+		t.Error("Expected percentiles {100 50ms 90ms 99ms 100ms}, got", p)
+	}
+	if latencies[0] != 100*ms {
+		t.Error("Expected latencies not to be sorted in place")
+	}
+	p = NewPercentiles([]time.Duration{3 * ms})
+	if p.Count != 1 || p.P50 != 3*ms || p.P99 != 3*ms || p.Max != 3*ms {
+		t.Error("Expected percentiles {1 3ms 3ms 3ms 3ms}, got", p)
+	}
+}
+
+func TestEpochTimer(t *testing.T) {
+	stats := NewStats()
+	timer := NewEpochTimer(func() *Stats { return stats }, true)
+	t0 := time.Now()
+	ms := time.Millisecond
+
+	timer.Start(1, t0)
+	timer.Start(2, t0.Add(50*ms))
+	timer.Event(&consensus.Event{Type: consensus.PROPOSAL_RECEIVED_EVENT, Epoch: 1, Time: t0.Add(10 * ms)})
+	// Only the first time a phase is reached is recorded
+	timer.Event(&consensus.Event{Type: consensus.PROPOSAL_RECEIVED_EVENT, Epoch: 1, Time: t0.Add(30 * ms)})
+	timer.Event(&consensus.Event{Type: consensus.VOTED_EVENT, Epoch: 1, Time: t0.Add(11 * ms)})
+	timer.Event(&consensus.Event{Type: consensus.TIMEOUT_EVENT, Epoch: 1, Time: t0.Add(12 * ms)})
+	timer.Event(&consensus.Event{Type: consensus.CERTIFICATE_EVENT, Epoch: 1, Time: t0.Add(20 * ms)})
+	timer.Event(&consensus.Event{Type: consensus.DECIDED_EVENT, Epoch: 1, Time: t0.Add(40 * ms), FastPath: true})
+	// Decisions are recorded once per epoch
+	timer.Event(&consensus.Event{Type: consensus.DECIDED_EVENT, Epoch: 1, Time: t0.Add(42 * ms), FastPath: true})
+	timer.Event(&consensus.Event{Type: consensus.PROPOSAL_RECEIVED_EVENT, Epoch: 2, Time: t0.Add(70 * ms)})
+	timer.Delivered(1, t0.Add(45*ms))
+	timer.Event(&consensus.Event{Type: consensus.DECIDED_EVENT, Epoch: 2, Time: t0.Add(150 * ms)})
+	timer.Delivered(2, t0.Add(160*ms))
+	// Epochs are forgotten once delivered
+	timer.Event(&consensus.Event{Type: consensus.VOTED_EVENT, Epoch: 2, Time: t0.Add(200 * ms)})
+
+	stats.computeLatencies()
+	expected := [numPhases]Percentiles{
+		ProposalPhase:    {Count: 2, P50: 10 * ms, P90: 20 * ms, P99: 20 * ms, Max: 20 * ms},
+		VotePhase:        {Count: 1, P50: 11 * ms, P90: 11 * ms, P99: 11 * ms, Max: 11 * ms},
+		CertificatePhase: {Count: 1, P50: 20 * ms, P90: 20 * ms, P99: 20 * ms, Max: 20 * ms},
+		DecisionPhase:    {Count: 2, P50: 40 * ms, P90: 100 * ms, P99: 100 * ms, Max: 100 * ms},
+		DeliveryPhase:    {Count: 2, P50: 45 * ms, P90: 110 * ms, P99: 110 * ms, Max: 110 * ms},
+	}
+	for phase := range expected {
+		if stats.Latencies[phase] != expected[phase] {
+			t.Errorf("Expected phase %v latencies %v, got %v", phase, expected[phase], stats.Latencies[phase])
+		}
+	}
+	if stats.Paths != [2]int{1, 1} {
+		t.Error("Expected one decision on each path, got", stats.Paths)
+	}
+}
+
+func TestEpochTimerWithoutFastPath(t *testing.T) {
+	stats := NewStats()
+	timer := NewEpochTimer(func() *Stats { return stats }, false)
+	t0 := time.Now()
+	timer.Start(1, t0)
+	timer.Event(&consensus.Event{Type: consensus.DECIDED_EVENT, Epoch: 1, Time: t0.Add(time.Millisecond)})
+	stats.computeLatencies()
+	if stats.Latencies[DecisionPhase].Count != 1 {
+		t.Error("Expected the decision latency to be recorded")
+	}
+	if stats.Paths != [2]int{0, 0} {
+		t.Error("Expected no decision path for models without fast path, got", stats.Paths)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	ms := time.Millisecond
+	h := NewHistogram([]time.Duration{ms, 5 * ms, 6 * ms, 20 * time.Second})
+	if len(h.Counts) != len(LatencyBuckets)+1 || h.Counts[0] != 2 || h.Counts[1] != 1 ||
+		h.Counts[len(LatencyBuckets)] != 1 || h.Sum != 20*time.Second+12*ms {
+		t.Error("Unexpected histogram", h)
+	}
+	var total Histogram
+	total.Add(h)
+	total.Add(NewHistogram([]time.Duration{ms}))
+	if total.Counts[0] != 3 || total.Sum != h.Sum+ms {
+		t.Error("Unexpected accumulated histogram", total)
+	}
+}
+
+func TestQueueDelays(t *testing.T) {
+	ms := time.Millisecond
+	stats := NewStats()
+	stats.MessageQueued(true, ms)
+	stats.MessageQueued(false, 10*ms)
+	stats.MessageQueued(false, 30*ms)
+	stats.computeLatencies()
+	if stats.QueueDelays[0].Count != 1 || stats.QueueDelays[0].Max != ms {
+		t.Error("Unexpected control queue delays", stats.QueueDelays[0])
+	}
+	if stats.QueueDelays[1].Count != 2 || stats.QueueDelays[1].P50 != 10*ms || stats.QueueDelays[1].Max != 30*ms {
+		t.Error("Unexpected bulk queue delays", stats.QueueDelays[1])
+	}
+	if stats.QueueHistograms[1].Sum != 40*ms {
+		t.Error("Unexpected bulk queue histogram", stats.QueueHistograms[1])
+	}
+}
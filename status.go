@@ -0,0 +1,84 @@
+package tendermint
+
+import (
+	"sort"
+	"time"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
+)
+
+// Status is a snapshot of the state of a process, used to inspect running
+// processes.
+type Status struct {
+	ID          int   `json:"id"`
+	LastEpoch   int64 `json:"lastEpoch"`
+	LastDecided int64 `json:"lastDecided"`
+
+	// Last block committed to the blockchain, if any
+	LastCommitted *BlockStatus `json:"lastCommitted,omitempty"`
+
+	// Epochs started and not yet decided, in increasing order
+	Epochs []*consensus.EpochStatus `json:"epochs"`
+
+	Verifier VerifierStats `json:"verifier"`
+}
+
+// BlockStatus summarizes a block.
+type BlockStatus struct {
+	Epoch   int64             `json:"epoch"`
+	Height  int64             `json:"height"`
+	BlockID consensus.BlockID `json:"block"`
+}
+
+// Status returns a snapshot of the state of the process, taken by its main
+// routine. Returns nil if the main routine does not take the snapshot
+// before the timeout, e.g., because the process is still bootstrapping.
+// This method can be invoked by any routine.
+func (p *Process) Status(timeout time.Duration) *Status {
+	reply := make(chan *Status, 1)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case p.statusQueue <- reply:
+	case <-timer.C:
+		return nil
+	}
+	select {
+	case status := <-reply:
+		return status
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Takes a snapshot of the state of the process, from its main routine.
+func (p *Process) status() *Status {
+	status := &Status{
+		ID:          p.id,
+		LastEpoch:   p.lastEpoch,
+		LastDecided: p.lastDecided,
+		Epochs:      []*consensus.EpochStatus{},
+		Verifier:    p.verifier.Stats(),
+	}
+	if block := p.blockchain.LastCommited; block != nil {
+		status.LastCommitted = &BlockStatus{
+			Epoch:   block.Epoch,
+			Height:  block.Height,
+			BlockID: block.BlockID(),
+		}
+	}
+	for _, epoch := range p.epochs {
+		if epoch == nil || epoch.GetEpoch() <= p.lastDecided || !epoch.Started() {
+			continue
+		}
+		if inspectable, ok := epoch.(consensus.Inspectable); ok {
+			status.Epochs = append(status.Epochs, inspectable.Status())
+		} else {
+			status.Epochs = append(status.Epochs, &consensus.EpochStatus{Epoch: epoch.GetEpoch()})
+		}
+	}
+	sort.Slice(status.Epochs, func(i, j int) bool {
+		return status.Epochs[i].Epoch < status.Epochs[j].Epoch
+	})
+	return status
+}
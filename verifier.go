@@ -1,14 +1,17 @@
 package tendermint
 
 import (
+	"context"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"dslab.inf.usi.ch/tendermint/bootstrap"
 	"dslab.inf.usi.ch/tendermint/consensus"
 	"dslab.inf.usi.ch/tendermint/crypto"
 	"dslab.inf.usi.ch/tendermint/net"
-	"github.com/hashicorp/golang-lru/simplelru"
+	lru "github.com/hashicorp/golang-lru"
 )
 
 // VerifierChanSize is the capacity of input and output channels.
@@ -18,26 +21,64 @@ var VerifierChanSize = 64
 var VerifierCacheSize = 1024
 
 // Verifier unmarshalls and verifies signatures of consensus messages.
+//
+// Signatures are verified by a pool of worker routines. The messages of a
+// sender are verified by the same worker, so that they are output in the
+// order they were received, while the messages of different senders are
+// verified concurrently.
+//
+// Control messages, if received in a separate input, are verified with strict
+// priority over the other (bulk) messages and output in a separate output.
 type Verifier struct {
-	keys    []crypto.PublicKey
-	input   <-chan net.Message
-	output  chan *consensus.Message
-	skipped chan net.Message
+	keys []crypto.PublicKey
+	// If set, keys are the ones of the validator set of the message epoch
+	membership *consensus.Membership
+	input      <-chan net.Message
+	output     chan *consensus.Message
+	skipped    chan net.Message
 
-	cache   simplelru.LRUCache
+	// Input and output of control messages, if set
+	controlInput  <-chan net.Message
+	controlOutput chan *consensus.Message
+
+	// Messages with more signatures to verify are verified in batch, if set
+	batchThreshold int
+
+	// Cache of verified signatures, shared by the workers
+	cache   *lru.Cache
+	workers []*verifierWorker
 	stats   VerifierStats
 	started sync.Once
+	// Time the verifier was started, in nanoseconds since the Unix epoch
+	startTime int64
+
+	// Main routine and workers return when the context is canceled
+	ctx      context.Context
+	cancel   context.CancelFunc
+	routines sync.WaitGroup
 }
 
-// NewVerifier creates and starts a new Verifier.
+// verifierWorker verifies the messages of a subset of the senders.
+type verifierWorker struct {
+	input   chan *consensus.Message
+	control chan *consensus.Message
+
+	// Messages verified and time spent verifying them, in nanoseconds
+	messages int64
+	busy     int64
+}
+
+// NewVerifier creates and starts a new Verifier, with the provided number of
+// worker routines, at least one.
 // If input or output channels are provided, they are created with VerifierChanSize capacity.
-func NewVerifier(keys []crypto.PublicKey, input <-chan net.Message, output chan *consensus.Message) *Verifier {
+func NewVerifier(keys []crypto.PublicKey, input <-chan net.Message, output chan *consensus.Message, workers int) *Verifier {
 	v := &Verifier{
 		keys:   keys,
 		input:  input,
 		output: output,
 	}
-	v.cache, _ = simplelru.NewLRU(VerifierCacheSize, nil)
+	v.cache, _ = lru.New(VerifierCacheSize)
+	v.ctx, v.cancel = context.WithCancel(context.Background())
 	if v.input == nil {
 		v.input = make(<-chan net.Message, VerifierChanSize)
 	}
@@ -45,6 +86,16 @@ func NewVerifier(keys []crypto.PublicKey, input <-chan net.Message, output chan
 		v.output = make(chan *consensus.Message, VerifierChanSize)
 	}
 	v.skipped = make(chan net.Message, VerifierChanSize)
+	if workers < 1 {
+		workers = 1
+	}
+	v.workers = make([]*verifierWorker, workers)
+	for i := range v.workers {
+		v.workers[i] = &verifierWorker{
+			input:   make(chan *consensus.Message, VerifierChanSize),
+			control: make(chan *consensus.Message, VerifierChanSize),
+		}
+	}
 	return v
 }
 
@@ -63,49 +114,151 @@ func (v *Verifier) Skipped() chan net.Message {
 	return v.skipped
 }
 
-// Starts the verifier, namely its main routine in background.
-// This main routine is only started in the first time this method is invoked.
+// Starts the verifier, namely its main routine and workers in background.
+// These routines are only started in the first time this method is invoked.
 func (v *Verifier) Start() {
-	v.started.Do(func() { go v.mainRoutine() })
+	v.started.Do(func() {
+		atomic.StoreInt64(&v.startTime, time.Now().UnixNano())
+		v.routines.Add(len(v.workers) + 1)
+		for _, worker := range v.workers {
+			go v.workerRoutine(worker)
+		}
+		go v.mainRoutine()
+	})
 }
 
+// Stop the verifier, waiting for its main routine and workers to return.
+// Messages not yet verified are discarded.
+func (v *Verifier) Stop() {
+	v.cancel()
+	v.routines.Wait()
+}
+
+// Unmarshalls consensus messages and dispatches them to the worker of their
+// sender, control messages first.
 func (v *Verifier) mainRoutine() {
+	defer v.routines.Done()
 	for {
-	NEXT_MESSAGE:
 		select {
+		case rawMessage := <-v.controlInput:
+			v.dispatch(rawMessage, true)
+			continue
+		default:
+		}
+		select {
+		case <-v.ctx.Done():
+			return
+		case rawMessage := <-v.controlInput:
+			v.dispatch(rawMessage, true)
 		case rawMessage := <-v.input:
-			// Ignore any message that is not a consensus one
-			if rawMessage.Code() != consensus.MessageCode {
-				v.skipMessage(rawMessage)
-				break NEXT_MESSAGE
+			v.dispatch(rawMessage, false)
+		}
+	}
+}
+
+func (v *Verifier) dispatch(rawMessage net.Message, control bool) {
+	// Ignore any message that is not a consensus one
+	if rawMessage.Code() != consensus.MessageCode {
+		v.skipMessage(rawMessage)
+		return
+	}
+	message := consensus.MessageFromBytes(rawMessage)
+	message.Received = time.Now()
+	// Senders are decoded from the wire, unknown ones cannot sign messages
+	if message.Sender < 0 || message.Sender >= len(v.publicKeys(message.Epoch)) {
+		atomic.AddInt64(&v.stats.Rejected, 1)
+		return
+	}
+	worker := v.workers[message.Sender%len(v.workers)]
+	input := worker.input
+	if control {
+		input = worker.control
+	}
+	select {
+	case input <- message:
+	case <-v.ctx.Done():
+	}
+}
+
+func (v *Verifier) workerRoutine(worker *verifierWorker) {
+	defer v.routines.Done()
+	for {
+		var message *consensus.Message
+		output := v.output
+		select {
+		case message = <-worker.control:
+			output = v.controlOutput
+		default:
+			select {
+			case <-v.ctx.Done():
+				return
+			case message = <-worker.control:
+				output = v.controlOutput
+			case message = <-worker.input:
+			}
+		}
+		start := time.Now()
+		valid := v.verifyMessage(message)
+		atomic.AddInt64(&worker.busy, int64(time.Since(start)))
+		atomic.AddInt64(&worker.messages, 1)
+		// If all signatures are valid, output the message
+		if valid {
+			select {
+			case output <- message:
+			case <-v.ctx.Done():
+				return
 			}
-			message := consensus.MessageFromBytes(rawMessage)
-			for _, sig := range message.GetCryptoSignatures() {
-				key := sig.Key()
-				v.stats.queries += 1
-				// Skip the verification of signatures on the cache
-				if _, exist := v.cache.Get(key); exist {
-					v.stats.cached += 1
-					continue
-				}
-				// If one signature verification fails, skip the message
-				if !v.verifySignature(sig) {
-					v.stats.rejected += 1
-					break NEXT_MESSAGE
-				}
-				v.cache.Add(key, sig)
+		}
+	}
+}
+
+// Verifies the signatures of a message.
+func (v *Verifier) verifyMessage(message *consensus.Message) bool {
+	var pending []*crypto.Signature
+	for _, sig := range message.GetCryptoSignatures() {
+		atomic.AddInt64(&v.stats.Queries, 1)
+		// Skip the verification of signatures on the cache.
+		// Aggregate signatures are not cached, as the same
+		// signature could be attached to different signers.
+		if sig.Signers == nil && v.cache.Contains(sig.Key()) {
+			atomic.AddInt64(&v.stats.Cached, 1)
+			continue
+		}
+		pending = append(pending, sig)
+	}
+	if v.batchThreshold > 0 && len(pending) > v.batchThreshold {
+		atomic.AddInt64(&v.stats.Batches, 1)
+		if ok, _ := crypto.VerifyBatch(pending, v.publicKeys(message.Epoch)); !ok {
+			atomic.AddInt64(&v.stats.Rejected, 1)
+			return false
+		}
+	} else {
+		for _, sig := range pending {
+			// If one signature verification fails, skip the message
+			if !v.verifySignature(sig, message.Epoch) {
+				atomic.AddInt64(&v.stats.Rejected, 1)
+				return false
 			}
-			// If all signatures are valid, output the message
-			v.output <- message
 		}
 	}
+	for _, sig := range pending {
+		if sig.Signers == nil {
+			v.cache.Add(sig.Key(), sig)
+		}
+	}
+	return true
+}
+
+func (v *Verifier) verifySignature(sig *crypto.Signature, epoch int64) bool {
+	return sig.Verify(v.publicKeys(epoch))
 }
 
-func (v *Verifier) verifySignature(sig *crypto.Signature) bool {
-	if sig.ID < 0 || sig.ID >= len(v.keys) {
-		return false
+// Public keys of the processes in an epoch, indexed by process ID.
+func (v *Verifier) publicKeys(epoch int64) []crypto.PublicKey {
+	if v.membership != nil {
+		return v.membership.At(epoch).PublicKeys()
 	}
-	return v.keys[sig.ID].VerifySignature(sig.Payload, sig.Signature)
+	return v.keys
 }
 
 func (v *Verifier) skipMessage(message net.Message) {
@@ -120,8 +273,45 @@ func (v *Verifier) skipMessage(message net.Message) {
 	}
 }
 
+// VerifierStats counts the signatures to verify, the ones found in the
+// cache of verified signatures, the messages rejected and the messages whose
+// signatures were verified in batch.
 type VerifierStats struct {
-	queries  int
-	cached   int
-	rejected int
+	Queries  int64 `json:"queries"`
+	Cached   int64 `json:"cached"`
+	Rejected int64 `json:"rejected"`
+	Batches  int64 `json:"batches"`
+
+	Workers []VerifierWorkerStats `json:"workers"`
+}
+
+// VerifierWorkerStats counts the messages verified by a worker, and the
+// fraction of the time since the verifier was started spent verifying them.
+type VerifierWorkerStats struct {
+	Messages    int64         `json:"messages"`
+	Busy        time.Duration `json:"busy"`
+	Utilization float64       `json:"utilization"`
+}
+
+// Stats returns the verifier stats, it can be invoked by any routine.
+func (v *Verifier) Stats() VerifierStats {
+	stats := VerifierStats{
+		Queries:  atomic.LoadInt64(&v.stats.Queries),
+		Cached:   atomic.LoadInt64(&v.stats.Cached),
+		Rejected: atomic.LoadInt64(&v.stats.Rejected),
+		Batches:  atomic.LoadInt64(&v.stats.Batches),
+		Workers:  make([]VerifierWorkerStats, len(v.workers)),
+	}
+	var elapsed time.Duration
+	if start := atomic.LoadInt64(&v.startTime); start > 0 {
+		elapsed = time.Since(time.Unix(0, start))
+	}
+	for i, worker := range v.workers {
+		stats.Workers[i].Messages = atomic.LoadInt64(&worker.messages)
+		stats.Workers[i].Busy = time.Duration(atomic.LoadInt64(&worker.busy))
+		if elapsed > 0 {
+			stats.Workers[i].Utilization = float64(stats.Workers[i].Busy) / float64(elapsed)
+		}
+	}
+	return stats
 }
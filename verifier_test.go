@@ -1,5 +1,217 @@
 package tendermint
 
+import (
+	"testing"
+	"time"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
+	"dslab.inf.usi.ch/tendermint/crypto"
+	"dslab.inf.usi.ch/tendermint/net"
+)
+
+func TestVerifierWorkers(t *testing.T) {
+	n, count := 3, 20
+	privKeys := make([]crypto.PrivateKey, n)
+	pubKeys := make([]crypto.PublicKey, n)
+	for i := range privKeys {
+		privKeys[i] = crypto.GeneratePrivateKey()
+		pubKeys[i] = privKeys[i].PubKey()
+	}
+	input := make(chan net.Message, n*count+2)
+	v := NewVerifier(pubKeys, input, nil, 4)
+	for e := 0; e < count; e++ {
+		for id := 0; id < n; id++ {
+			m := consensus.NewSilenceMessage(int64(e), int16(id))
+			m.Sign(privKeys[id])
+			input <- m.Marshall()
+			// A duplicated message, whose signature is cached
+			if e == 0 && id == 0 {
+				input <- m.Marshall()
+			}
+		}
+	}
+	// A message signed with the key of another process
+	m := consensus.NewSilenceMessage(int64(count), 1)
+	m.Sign(privKeys[2])
+	input <- m.Marshall()
+	v.Start()
+
+	// Messages of each sender are output in order
+	next := make([]int64, n)
+	for i := 0; i < n*count+1; i++ {
+		select {
+		case m := <-v.Output():
+			if m.Sender == 0 && m.Epoch == 0 && next[0] == 1 {
+				continue
+			}
+			if m.Epoch != next[m.Sender] {
+				t.Errorf("Expected message of epoch %v from %v, got epoch %v", next[m.Sender], m.Sender, m.Epoch)
+			}
+			next[m.Sender] = m.Epoch + 1
+		case <-time.After(time.Second):
+			t.Fatal("Not output generated in 1s")
+		}
+	}
+	select {
+	case m := <-v.Output():
+		t.Error("Unexpected output", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+	stats := v.Stats()
+	if stats.Rejected != 1 || stats.Cached != 1 || stats.Queries != int64(n*count+2) {
+		t.Errorf("Unexpected verifier stats %+v", stats)
+	}
+	var messages int64
+	for _, worker := range stats.Workers {
+		messages += worker.Messages
+		if worker.Utilization < 0 || worker.Utilization > 1 {
+			t.Errorf("Unexpected worker utilization %+v", worker)
+		}
+	}
+	if len(stats.Workers) != 4 || messages != int64(n*count+2) {
+		t.Errorf("Unexpected worker stats %+v", stats.Workers)
+	}
+}
+
+func TestVerifierBatch(t *testing.T) {
+	n := 4
+	privKeys := make([]crypto.PrivateKey, n)
+	pubKeys := make([]crypto.PublicKey, n)
+	for i := range privKeys {
+		privKeys[i] = crypto.GeneratePrivateKey()
+		pubKeys[i] = privKeys[i].PubKey()
+	}
+	input := make(chan net.Message, 2)
+	v := NewVerifier(pubKeys, input, nil, 1)
+	v.batchThreshold = 2
+	block := consensus.NewBlock([]byte("a value"), nil)
+	// Certificates of all processes, the vote of process 3 being first
+	// signed by process 1, then by process 3
+	for _, signer := range []int{1, 3} {
+		cert := consensus.NewBlockCertificate(7, block.BlockID(), block.Height)
+		for id := 0; id < n; id++ {
+			vote := consensus.NewVoteMessage(7, block.BlockID(), block.Height, int16(id), 0)
+			if id == 3 {
+				vote.Sign(privKeys[signer])
+			} else {
+				vote.Sign(privKeys[id])
+			}
+			cert.AddSignature(vote.Signature, vote.Sender)
+		}
+		m := consensus.NewQuitEpochMessage(7, cert)
+		m.Sign(privKeys[0])
+		input <- m.Marshall()
+	}
+	v.Start()
+	select {
+	case m := <-v.Output():
+		if m.Certificate.SignatureCount() != n {
+			t.Error("Unexpected output", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Not output generated in 1s")
+	}
+	select {
+	case m := <-v.Output():
+		t.Error("Unexpected output of message with invalid certificate", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+	stats := v.Stats()
+	if stats.Batches != 2 || stats.Rejected != 1 {
+		t.Errorf("Unexpected verifier stats %+v", stats)
+	}
+}
+
+func TestVerifierControlMessages(t *testing.T) {
+	privKey := crypto.GeneratePrivateKey()
+	input := make(chan net.Message, 8)
+	controlInput := make(chan net.Message, 8)
+	v := NewVerifier([]crypto.PublicKey{privKey.PubKey()}, input, nil, 1)
+	v.controlInput = controlInput
+	v.controlOutput = make(chan *consensus.Message, 8)
+	block := consensus.NewBlock([]byte("a value"), nil)
+	for e := int64(0); e < 4; e++ {
+		m := consensus.NewProposeMessage(e, block, nil, 0)
+		m.Sign(privKey)
+		input <- m.Marshall()
+		m = consensus.NewSilenceMessage(e, 0)
+		m.Sign(privKey)
+		controlInput <- m.Marshall()
+	}
+	v.Start()
+	for e := int64(0); e < 4; e++ {
+		select {
+		case m := <-v.controlOutput:
+			if m.Type != consensus.SILENCE || m.Epoch != e || m.Received.IsZero() {
+				t.Error("Unexpected control message", m)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Not output generated in 1s")
+		}
+		select {
+		case m := <-v.Output():
+			if m.Type != consensus.PROPOSE || m.Epoch != e {
+				t.Error("Unexpected bulk message", m)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Not output generated in 1s")
+		}
+	}
+}
+
+func TestVerifierStop(t *testing.T) {
+	privKey := crypto.GeneratePrivateKey()
+	input := make(chan net.Message, 4)
+	// Verified messages are never consumed, blocking the worker
+	v := NewVerifier([]crypto.PublicKey{privKey.PubKey()}, input, make(chan *consensus.Message), 2)
+	for e := int64(0); e < 4; e++ {
+		m := consensus.NewSilenceMessage(e, 0)
+		m.Sign(privKey)
+		input <- m.Marshall()
+	}
+	v.Start()
+	stopped := make(chan struct{})
+	go func() {
+		v.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Verifier not stopped in 1s")
+	}
+	// Stopping twice is harmless
+	v.Stop()
+}
+
+func TestVerifierUnknownSenders(t *testing.T) {
+	privKey := crypto.GeneratePrivateKey()
+	input := make(chan net.Message, 3)
+	v := NewVerifier([]crypto.PublicKey{privKey.PubKey()}, input, nil, 2)
+	// Senders are encoded as int16, 0xFFFF being decoded as -1
+	for _, sender := range []int16{-1, 1} {
+		m := consensus.NewSilenceMessage(1, sender)
+		m.Sign(privKey)
+		input <- m.Marshall()
+	}
+	m := consensus.NewSilenceMessage(2, 0)
+	m.Sign(privKey)
+	input <- m.Marshall()
+	v.Start()
+	defer v.Stop()
+	select {
+	case m := <-v.Output():
+		if m.Sender != 0 || m.Epoch != 2 {
+			t.Error("Unexpected output", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Not output generated in 1s")
+	}
+	if stats := v.Stats(); stats.Rejected != 2 {
+		t.Errorf("Expected messages from unknown senders to be rejected, got %+v", stats)
+	}
+}
+
 /*
 func TestVerifier(t *testing.T) {
 	privKeys := make([]crypto.PrivateKey, 2)
@@ -9,7 +221,7 @@ func TestVerifier(t *testing.T) {
 		pubKeys[i] = privKeys[i].PubKey()
 	}
 	input := make(chan net.Message, 1)
-	v := NewVerifier(pubKeys, input, nil)
+	v := NewVerifier(pubKeys, input, nil, 1)
 
 	// 1. Produce a consensus message
 	// 2. Marshall the message and add it to the verifier's input queue
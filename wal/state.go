@@ -0,0 +1,218 @@
+package wal
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
+)
+
+// State is the consensus state of a process recorded in the log.
+type State struct {
+	// Last epoch started by the process, -1 if none.
+	LastEpoch int64
+
+	// Epoch of the last decision, -1 if none.
+	LastDecided int64
+
+	// Last block committed by the process, nil if none.
+	LastCommited *consensus.Block
+
+	// Highest ranked certificate locked by the process, nil if none.
+	LockedCertificate *consensus.Certificate
+
+	// Evidence of equivocating proposers, in the order they were recorded.
+	Evidence []*consensus.Evidence
+
+	// Validator sets resulting from committed reconfigurations, in
+	// increasing order of epochs. Only the sets in effect from the last
+	// decided epoch on are retained.
+	Validators []*consensus.ValidatorSet
+
+	// Votes, precommits and silence messages sent in epochs not yet decided
+	votes      map[int64]consensus.BlockID
+	precommits map[int64]consensus.BlockID
+	silences   map[int64]bool
+}
+
+// NewState returns the state of a process that has not started any epoch.
+func NewState() *State {
+	return &State{
+		LastEpoch:   -1,
+		LastDecided: -1,
+		votes:       make(map[int64]consensus.BlockID),
+		precommits:  make(map[int64]consensus.BlockID),
+		silences:    make(map[int64]bool),
+	}
+}
+
+// Voted returns the block the process voted for in an epoch, if any.
+func (s *State) Voted(epoch int64) (consensus.BlockID, bool) {
+	blockID, ok := s.votes[epoch]
+	return blockID, ok
+}
+
+// Precommitted returns the block the process sent a precommit for in an
+// epoch, the zero block ID if for no block, if any.
+func (s *State) Precommitted(epoch int64) (consensus.BlockID, bool) {
+	blockID, ok := s.precommits[epoch]
+	return blockID, ok
+}
+
+// SentSilence returns whether the process sent a silence message in an epoch.
+func (s *State) SentSilence(epoch int64) bool {
+	return s.silences[epoch]
+}
+
+// HasEvidence returns whether an evidence of the same equivocation is recorded.
+func (s *State) HasEvidence(evidence *consensus.Evidence) bool {
+	for _, e := range s.Evidence {
+		if e.Equal(evidence) {
+			return true
+		}
+	}
+	return false
+}
+
+// Adds a validator set, replacing the one in effect from the same epoch.
+func (s *State) addValidators(set *consensus.ValidatorSet) {
+	if n := len(s.Validators); n > 0 && s.Validators[n-1].Epoch >= set.Epoch {
+		s.Validators[n-1] = set
+		return
+	}
+	s.Validators = append(s.Validators, set)
+}
+
+// Updates the last commit, votes, precommits and silences of decided epochs
+// are pruned.
+func (s *State) commit(epoch int64, block *consensus.Block) {
+	s.LastDecided = epoch
+	s.LastCommited = block
+	if epoch > s.LastEpoch {
+		s.LastEpoch = epoch
+	}
+	for e := range s.votes {
+		if e <= epoch {
+			delete(s.votes, e)
+		}
+	}
+	for e := range s.precommits {
+		if e <= epoch {
+			delete(s.precommits, e)
+		}
+	}
+	for e := range s.silences {
+		if e <= epoch {
+			delete(s.silences, e)
+		}
+	}
+	for len(s.Validators) > 1 && s.Validators[1].Epoch <= epoch {
+		s.Validators = s.Validators[1:]
+	}
+}
+
+// Applies the records read from a log.
+func (s *State) replay(reader io.Reader) error {
+	for {
+		r, err := readRecord(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err = s.apply(r); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *State) apply(r *record) error {
+	switch r.recordType {
+	case EPOCH:
+		if epoch := int64(encoding.Uint64(r.payload)); epoch > s.LastEpoch {
+			s.LastEpoch = epoch
+		}
+	case VOTE:
+		epoch := int64(encoding.Uint64(r.payload))
+		if epoch > s.LastDecided {
+			s.votes[epoch] = consensus.BlockIDFromBytes(r.payload[8:])
+		}
+	case PRECOMMIT:
+		epoch := int64(encoding.Uint64(r.payload))
+		if epoch > s.LastDecided {
+			s.precommits[epoch] = consensus.BlockIDFromBytes(r.payload[8:])
+		}
+	case SILENCE:
+		epoch := int64(encoding.Uint64(r.payload))
+		if epoch > s.LastDecided {
+			s.silences[epoch] = true
+		}
+	case LOCK:
+		certificate := consensus.CertificateFromBytes(r.payload)
+		if certificate.RanksHigherOrEqual(s.LockedCertificate) {
+			s.LockedCertificate = certificate
+		}
+	case COMMIT:
+		epoch := int64(encoding.Uint64(r.payload))
+		if epoch > s.LastDecided {
+			s.commit(epoch, consensus.BlockFromBytes(r.payload[8:]))
+		}
+	case EVIDENCE:
+		evidence := consensus.EvidenceFromBytes(r.payload)
+		if !s.HasEvidence(evidence) {
+			s.Evidence = append(s.Evidence, evidence)
+		}
+	case VALIDATORS:
+		set := consensus.ValidatorSetFromBytes(r.payload)
+		if set == nil {
+			return fmt.Errorf("invalid validator set record")
+		}
+		s.addValidators(set)
+	default:
+		return fmt.Errorf("invalid record type %d", r.recordType)
+	}
+	return nil
+}
+
+// Returns the records needed to restore this state.
+func (s *State) records() []*record {
+	var records []*record
+	if s.LastCommited != nil {
+		payload := make([]byte, 8+s.LastCommited.ByteSize())
+		encoding.PutUint64(payload, uint64(s.LastDecided))
+		s.LastCommited.MarshallTo(payload[8:])
+		records = append(records, &record{COMMIT, payload})
+	}
+	if s.LockedCertificate != nil {
+		payload := make([]byte, s.LockedCertificate.ByteSize())
+		s.LockedCertificate.MarshallTo(payload)
+		records = append(records, &record{LOCK, payload})
+	}
+	if s.LastEpoch >= 0 {
+		records = append(records, &record{EPOCH, encodeEpoch(s.LastEpoch)})
+	}
+	for _, epoch := range sortedEpochs(s.votes) {
+		records = append(records, &record{VOTE, encodeVote(epoch, s.votes[epoch])})
+	}
+	for _, epoch := range sortedEpochs(s.precommits) {
+		records = append(records, &record{PRECOMMIT, encodeVote(epoch, s.precommits[epoch])})
+	}
+	for epoch := range s.silences {
+		records = append(records, &record{SILENCE, encodeEpoch(epoch)})
+	}
+	for _, evidence := range s.Evidence {
+		records = append(records, &record{EVIDENCE, evidence.Marshall()})
+	}
+	for _, set := range s.Validators {
+		records = append(records, &record{VALIDATORS, set.Marshall()})
+	}
+	return records
+}
+
+func sortedEpochs(votes map[int64]consensus.BlockID) []int64 {
+	epochs := make([]int64, 0, len(votes))
+	for epoch := range votes {
+		epochs = append(epochs, epoch)
+	}
+	sort.Slice(epochs, func(i, j int) bool { return epochs[i] < epochs[j] })
+	return epochs
+}
@@ -0,0 +1,300 @@
+// Package wal implements a write-ahead log for the consensus state of a process.
+//
+// The log records the epochs started by a process, the votes, precommits and
+// silence messages it has sent, its locked certificate, its committed blocks, the
+// validator sets resulting from committed reconfigurations and the evidence
+// of equivocating proposers it has learned.
+// Every record is flushed to stable storage before the corresponding action
+// takes effect, so that a restarted process can recover its epoch window and
+// never sends conflicting votes in an epoch.
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
+)
+
+// Record types.
+const (
+	EPOCH = iota + 1
+	VOTE
+	SILENCE
+	LOCK
+	COMMIT
+	EVIDENCE
+	VALIDATORS
+	PRECOMMIT
+)
+
+// Record framing: type (1 byte), payload length (4 bytes), payload, and the
+// CRC-32 checksum (4 bytes) of the previous fields.
+const recordHeaderSize = 5
+const recordChecksumSize = 4
+
+// Larger sizes are considered corrupted records.
+const maxRecordSize = 1 << 26
+
+var encoding = binary.LittleEndian
+
+// ErrConflictingVote is returned when a process tries to log a vote for a
+// block different from the one it has voted for in the same epoch.
+var ErrConflictingVote = errors.New("conflicting vote in epoch")
+
+// ErrSilenceAfterVote is returned when a process tries to log a silence
+// message in an epoch in which it has voted.
+var ErrSilenceAfterVote = errors.New("silence after vote in epoch")
+
+// WAL is a write-ahead log stored in a local file.
+type WAL struct {
+	filename string
+	file     *os.File
+	buffer   *bufio.Writer
+
+	state *State
+}
+
+// Open opens the write-ahead log stored in filename, creating it if needed.
+//
+// The records in the file are replayed to reconstruct the process state,
+// then the file is compacted to the records needed to restore this state.
+// Trailing incomplete or corrupted records, written during a crash, are
+// discarded.
+func Open(filename string) (*WAL, error) {
+	state := NewState()
+	file, err := os.Open(filename)
+	if err == nil {
+		err = state.replay(bufio.NewReader(file))
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	w := &WAL{
+		filename: filename,
+		state:    state,
+	}
+	if err := w.compact(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// State returns the process state recovered from, and updated by, the log.
+func (w *WAL) State() *State {
+	return w.state
+}
+
+// StartEpoch records that the process has started an epoch.
+func (w *WAL) StartEpoch(epoch int64) error {
+	if epoch <= w.state.LastEpoch {
+		return nil
+	}
+	w.state.LastEpoch = epoch
+	return w.write(EPOCH, encodeEpoch(epoch))
+}
+
+// Vote records a vote for a block sent by the process in an epoch.
+//
+// A vote for the same block in the same epoch is accepted, but not recorded
+// again. An error is returned if the process has voted for a different block
+// in the epoch, in which case the vote must not be sent.
+func (w *WAL) Vote(epoch int64, blockID consensus.BlockID) error {
+	if voted, ok := w.state.votes[epoch]; ok {
+		if voted.Equal(blockID) {
+			return nil
+		}
+		return fmt.Errorf("%w %d", ErrConflictingVote, epoch)
+	}
+	w.state.votes[epoch] = blockID
+	return w.write(VOTE, encodeVote(epoch, blockID))
+}
+
+// Precommit records a precommit for a block, nil if for no block, sent by the
+// process in an epoch. As for votes, an error is returned if the process has
+// sent a precommit for a different block in the epoch.
+func (w *WAL) Precommit(epoch int64, blockID consensus.BlockID) error {
+	// Precommits for no block are recorded with the zero block ID
+	id := make(consensus.BlockID, consensus.BlockIDSize)
+	copy(id, blockID)
+	if precommitted, ok := w.state.precommits[epoch]; ok {
+		if precommitted.Equal(id) {
+			return nil
+		}
+		return fmt.Errorf("%w %d", ErrConflictingVote, epoch)
+	}
+	w.state.precommits[epoch] = id
+	return w.write(PRECOMMIT, encodeVote(epoch, id))
+}
+
+// Silence records a silence message sent by the process in an epoch.
+//
+// An error is returned if the process has voted in the epoch, in which case
+// the silence message must not be sent.
+func (w *WAL) Silence(epoch int64) error {
+	if _, ok := w.state.votes[epoch]; ok {
+		return fmt.Errorf("%w %d", ErrSilenceAfterVote, epoch)
+	}
+	if w.state.silences[epoch] {
+		return nil
+	}
+	w.state.silences[epoch] = true
+	return w.write(SILENCE, encodeEpoch(epoch))
+}
+
+// Lock records a locked certificate, if it ranks higher than the recorded one.
+func (w *WAL) Lock(certificate *consensus.Certificate) error {
+	if certificate == nil || (w.state.LockedCertificate != nil &&
+		w.state.LockedCertificate.RanksHigherOrEqual(certificate)) {
+		return nil
+	}
+	// A fresh buffer, so that the certificate's encoding is not cached
+	payload := make([]byte, certificate.ByteSize())
+	certificate.MarshallTo(payload)
+	w.state.LockedCertificate = consensus.CertificateFromBytes(payload)
+	return w.write(LOCK, payload)
+}
+
+// Commit records a block committed by the process in an epoch.
+func (w *WAL) Commit(epoch int64, block *consensus.Block) error {
+	if epoch <= w.state.LastDecided {
+		return nil
+	}
+	payload := make([]byte, 8+block.ByteSize())
+	encoding.PutUint64(payload, uint64(epoch))
+	block.MarshallTo(payload[8:])
+	w.state.commit(epoch, block)
+	return w.write(COMMIT, payload)
+}
+
+// Evidence records the evidence that a proposer has equivocated, if unknown.
+func (w *WAL) Evidence(evidence *consensus.Evidence) error {
+	if w.state.HasEvidence(evidence) {
+		return nil
+	}
+	payload := make([]byte, evidence.ByteSize())
+	evidence.MarshallTo(payload)
+	w.state.Evidence = append(w.state.Evidence, consensus.EvidenceFromBytes(payload))
+	return w.write(EVIDENCE, payload)
+}
+
+// Validators records a validator set resulting from a committed reconfiguration.
+func (w *WAL) Validators(set *consensus.ValidatorSet) error {
+	payload := set.Marshall()
+	w.state.addValidators(consensus.ValidatorSetFromBytes(payload))
+	return w.write(VALIDATORS, payload)
+}
+
+// Close flushes and closes the log file.
+func (w *WAL) Close() error {
+	err := w.buffer.Flush()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Writes a record to the log, flushing it to stable storage.
+func (w *WAL) write(recordType byte, payload []byte) error {
+	if err := writeRecord(w.buffer, recordType, payload); err != nil {
+		return err
+	}
+	if err := w.buffer.Flush(); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// Rewrites the log with the records needed to restore the current state,
+// atomically replacing the log file.
+func (w *WAL) compact() error {
+	tmpname := w.filename + ".tmp"
+	file, err := os.Create(tmpname)
+	if err != nil {
+		return err
+	}
+	buffer := bufio.NewWriter(file)
+	for _, r := range w.state.records() {
+		if err = writeRecord(buffer, r.recordType, r.payload); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = buffer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmpname, w.filename)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(tmpname)
+		return err
+	}
+	w.file = file
+	w.buffer = buffer
+	return nil
+}
+
+type record struct {
+	recordType byte
+	payload    []byte
+}
+
+func writeRecord(writer io.Writer, recordType byte, payload []byte) error {
+	buffer := make([]byte, recordHeaderSize+len(payload)+recordChecksumSize)
+	buffer[0] = recordType
+	encoding.PutUint32(buffer[1:], uint32(len(payload)))
+	copy(buffer[recordHeaderSize:], payload)
+	checksum := crc32.ChecksumIEEE(buffer[:recordHeaderSize+len(payload)])
+	encoding.PutUint32(buffer[recordHeaderSize+len(payload):], checksum)
+	_, err := writer.Write(buffer)
+	return err
+}
+
+// Reads a record, returning io.EOF if no complete and valid record is found.
+func readRecord(reader io.Reader) (*record, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, io.EOF
+	}
+	size := encoding.Uint32(header[1:])
+	if size > maxRecordSize {
+		return nil, io.EOF
+	}
+	buffer := make([]byte, int(size)+recordChecksumSize)
+	if _, err := io.ReadFull(reader, buffer); err != nil {
+		return nil, io.EOF
+	}
+	checksum := crc32.NewIEEE()
+	checksum.Write(header)
+	checksum.Write(buffer[:size])
+	if checksum.Sum32() != encoding.Uint32(buffer[size:]) {
+		return nil, io.EOF
+	}
+	return &record{recordType: header[0], payload: buffer[:size]}, nil
+}
+
+func encodeEpoch(epoch int64) []byte {
+	payload := make([]byte, 8)
+	encoding.PutUint64(payload, uint64(epoch))
+	return payload
+}
+
+func encodeVote(epoch int64, blockID consensus.BlockID) []byte {
+	payload := make([]byte, 8+consensus.BlockIDSize)
+	encoding.PutUint64(payload, uint64(epoch))
+	blockID.MarshallTo(payload[8:])
+	return payload
+}
@@ -0,0 +1,228 @@
+package wal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
+)
+
+func testOpen(t *testing.T, filename string) *WAL {
+	t.Helper()
+	w, err := Open(filename)
+	if err != nil {
+		t.Fatal("Unexpected error opening log", err)
+	}
+	return w
+}
+
+func testCertificate(epoch int64, block *consensus.Block, signers ...int) *consensus.Certificate {
+	c := consensus.NewBlockCertificate(epoch, block.BlockID(), block.Height)
+	for _, s := range signers {
+		c.AddSignature(make([]byte, consensus.SignatureSize), s)
+	}
+	return c
+}
+
+func TestWALRecovery(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "wal")
+	b0 := consensus.NewBlock([]byte("b0"), nil)
+	b1 := consensus.NewBlock([]byte("b1"), b0)
+
+	w := testOpen(t, filename)
+	if s := w.State(); s.LastEpoch != -1 || s.LastDecided != -1 ||
+		s.LastCommited != nil || s.LockedCertificate != nil {
+		t.Error("Expected empty state, got", s)
+	}
+	w.StartEpoch(0)
+	w.Vote(0, b0.BlockID())
+	w.Lock(testCertificate(0, b0, 0, 1, 2))
+	w.StartEpoch(1)
+	w.Vote(1, b1.BlockID())
+	w.Commit(0, b0)
+	w.StartEpoch(2)
+	w.Silence(2)
+	w.Close()
+
+	for i := 0; i < 2; i++ { // Recovery from compacted log
+		w = testOpen(t, filename)
+		s := w.State()
+		if s.LastEpoch != 2 || s.LastDecided != 0 {
+			t.Error("Expected last epoch 2 and decided 0, got", s.LastEpoch, s.LastDecided)
+		}
+		if !s.LastCommited.Equal(b0) {
+			t.Error("Expected last committed block", b0, "got", s.LastCommited)
+		}
+		if c := s.LockedCertificate; c == nil || c.Epoch != 0 ||
+			!c.BlockID().Equal(b0.BlockID()) || c.SignatureCount() != 3 {
+			t.Error("Unexpected locked certificate", c)
+		}
+		if _, ok := s.Voted(0); ok {
+			t.Error("Expected votes of decided epochs to be pruned")
+		}
+		if blockID, ok := s.Voted(1); !ok || !blockID.Equal(b1.BlockID()) {
+			t.Error("Expected vote for", b1.BlockID(), "in epoch 1, got", blockID)
+		}
+		if !s.SentSilence(2) {
+			t.Error("Expected silence in epoch 2")
+		}
+		w.Close()
+	}
+}
+
+func TestWALConflictingVotes(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "wal")
+	b0 := consensus.NewBlock([]byte("b0"), nil)
+	b0x := consensus.NewBlock([]byte("b0x"), nil)
+
+	w := testOpen(t, filename)
+	if err := w.Vote(0, b0.BlockID()); err != nil {
+		t.Error("Unexpected error voting", err)
+	}
+	w.Close()
+
+	w = testOpen(t, filename)
+	defer w.Close()
+	if err := w.Vote(0, b0.BlockID()); err != nil {
+		t.Error("Expected repeated vote to be accepted, got", err)
+	}
+	if err := w.Vote(0, b0x.BlockID()); !errors.Is(err, ErrConflictingVote) {
+		t.Error("Expected conflicting vote error, got", err)
+	}
+	if err := w.Silence(0); !errors.Is(err, ErrSilenceAfterVote) {
+		t.Error("Expected silence after vote error, got", err)
+	}
+	if err := w.Silence(1); err != nil {
+		t.Error("Unexpected error sending silence", err)
+	}
+	if err := w.Vote(1, b0x.BlockID()); err != nil {
+		t.Error("Unexpected error voting after silence", err)
+	}
+}
+
+func TestWALConflictingPrecommits(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "wal")
+	b0 := consensus.NewBlock([]byte("b0"), nil)
+
+	w := testOpen(t, filename)
+	if err := w.Precommit(0, nil); err != nil {
+		t.Error("Unexpected error sending nil precommit", err)
+	}
+	if err := w.Precommit(1, b0.BlockID()); err != nil {
+		t.Error("Unexpected error sending precommit", err)
+	}
+	// Precommits are recorded separately from votes
+	if err := w.Vote(1, nil); err != nil {
+		t.Error("Unexpected error voting", err)
+	}
+	w.Close()
+
+	w = testOpen(t, filename)
+	defer w.Close()
+	if err := w.Precommit(0, nil); err != nil {
+		t.Error("Expected repeated nil precommit to be accepted, got", err)
+	}
+	if err := w.Precommit(0, b0.BlockID()); !errors.Is(err, ErrConflictingVote) {
+		t.Error("Expected conflicting precommit error, got", err)
+	}
+	if err := w.Precommit(1, nil); !errors.Is(err, ErrConflictingVote) {
+		t.Error("Expected conflicting precommit error, got", err)
+	}
+	if precommitted, ok := w.State().Precommitted(1); !ok || !precommitted.Equal(b0.BlockID()) {
+		t.Error("Expected precommit to be recovered, got", precommitted)
+	}
+}
+
+func TestWALLockRanking(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "wal")
+	b0 := consensus.NewBlock([]byte("b0"), nil)
+	b1 := consensus.NewBlock([]byte("b1"), b0)
+
+	w := testOpen(t, filename)
+	w.Lock(nil)
+	w.Lock(testCertificate(3, b1, 0, 1))
+	w.Lock(testCertificate(1, b0, 0, 1))
+	w.Close()
+
+	w = testOpen(t, filename)
+	defer w.Close()
+	if c := w.State().LockedCertificate; c == nil || c.Epoch != 3 {
+		t.Error("Expected locked certificate of epoch 3, got", c)
+	}
+}
+
+func TestWALEvidence(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "wal")
+	b0 := consensus.NewBlock([]byte("b0"), nil)
+	b0x := consensus.NewBlock([]byte("b0x"), nil)
+	evidence := consensus.NewEvidence(testCertificate(2, b0, 1, 3), testCertificate(2, b0x, 1), 1)
+
+	w := testOpen(t, filename)
+	w.Evidence(evidence)
+	w.Evidence(consensus.NewEvidence(testCertificate(2, b0x, 1), testCertificate(2, b0, 1), 1))
+	w.Close()
+
+	w = testOpen(t, filename)
+	defer w.Close()
+	if e := w.State().Evidence; len(e) != 1 || !e[0].Equal(evidence) ||
+		!e[0].BlockIDs[1].Equal(b0x.BlockID()) {
+		t.Error("Expected recovered evidence against 1 in epoch 2, got", e)
+	}
+}
+
+func TestWALValidators(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "wal")
+	b0 := consensus.NewBlock([]byte("b0"), nil)
+	initial := consensus.NewValidatorSet(consensus.MIN_EPOCH, 4, nil, nil)
+	removed := initial.Apply(3, []consensus.ValidatorUpdate{{ID: 0}})
+	added := removed.Apply(6, []consensus.ValidatorUpdate{{ID: 4, VotingPower: 1}})
+
+	w := testOpen(t, filename)
+	w.Validators(removed)
+	w.Validators(added)
+	w.Commit(4, b0)
+	w.Close()
+
+	w = testOpen(t, filename)
+	defer w.Close()
+	// The set in effect from epoch 3 is retained, as it is in effect in epoch 4
+	if v := w.State().Validators; len(v) != 2 || !v[0].Equal(removed) || v[0].Epoch != 3 ||
+		!v[1].Equal(added) || v[1].Epoch != 6 {
+		t.Error("Expected recovered validator sets of epochs 3 and 6, got", v)
+	}
+	w.Commit(7, b0)
+	if v := w.State().Validators; len(v) != 1 || v[0].Epoch != 6 {
+		t.Error("Expected validator sets before epoch 6 to be pruned, got", v)
+	}
+}
+
+func TestWALTruncatedRecord(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "wal")
+	w := testOpen(t, filename)
+	w.StartEpoch(4)
+	w.StartEpoch(5)
+	w.Close()
+
+	// Crash while writing the last record
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Truncate(filename, info.Size()-2); err != nil {
+		t.Fatal(err)
+	}
+	w = testOpen(t, filename)
+	if w.State().LastEpoch != 4 {
+		t.Error("Expected last epoch 4, got", w.State().LastEpoch)
+	}
+	w.StartEpoch(6)
+	w.Close()
+
+	w = testOpen(t, filename)
+	defer w.Close()
+	if w.State().LastEpoch != 6 {
+		t.Error("Expected last epoch 6, got", w.State().LastEpoch)
+	}
+}
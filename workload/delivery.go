@@ -10,10 +10,11 @@ import (
 // Delivery records the delivery of a value.
 type Delivery struct {
 	// Consensus data.
-	Epoch   int64
-	Height  int64
-	Value   types.Value
-	BlockID consensus.BlockID
+	Epoch       int64
+	Height      int64
+	Value       types.Value
+	BlockID     consensus.BlockID
+	PrevBlockID consensus.BlockID
 
 	// Performance data.
 	Size int
@@ -26,10 +27,11 @@ type Delivery struct {
 // NewDelivery creates a Delivery from a delivered block.
 func NewDelivery(epoch int64, block *consensus.Block) *Delivery {
 	return &Delivery{
-		Epoch:   epoch,
-		Height:  block.Height,
-		Value:   block.Value,
-		BlockID: block.BlockID(),
+		Epoch:       epoch,
+		Height:      block.Height,
+		Value:       block.Value,
+		BlockID:     block.BlockID(),
+		PrevBlockID: block.PrevBlockID,
 
 		Size: len(block.Value),
 		Time: time.Now(),
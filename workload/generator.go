@@ -68,6 +68,9 @@ func (g *Generator) ProduceValues(stopCh chan struct{}) {
 // - the configured maxDuration is reached
 // - 100 deliveries with empty values are processed
 // - a delivery with epoch config.MaxEpoch - 1 is processed
+// - the provided channel is closed
+//
+// Logged deliveries are flushed to the output file before returning.
 func (g *Generator) Run(maxDuration time.Duration, stopCh chan struct{}) {
 	var writer = NewWriter(g.config, g.id)
 	g.log.Println("Workload generator started, maximum duration:", maxDuration)
@@ -85,11 +88,19 @@ func (g *Generator) Run(maxDuration time.Duration, stopCh chan struct{}) {
 	for {
 		var delivery *Delivery
 		var tickTime time.Time
+		var stopped bool
 		select {
 		case delivery = <-g.deliveryQueue:
 			lastDeliveryTime = delivery.Time
 			lastDeliveryEpoch = delivery.Epoch
 		case tickTime = <-ticker.C:
+		case <-stopCh:
+			stopped = true
+		}
+
+		if stopped {
+			g.log.Println("Workload generator interrupted")
+			break
 		}
 
 		if delivery == nil { // No new delivery in this round
@@ -0,0 +1,47 @@
+package workload
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGeneratorStop(t *testing.T) {
+	dir := "generator-test"
+	defer testRemovePath(t, dir)
+	if err := createDirectory(dir); err != nil {
+		t.Fatal("Unexpected error creating dir", dir, err)
+	}
+	config := DefaultConfig()
+	config.LogDirectory = dir
+	g := NewGenerator(0, config)
+
+	stopCh := make(chan struct{})
+	produced := make(chan struct{})
+	go func() {
+		g.ProduceValues(stopCh)
+		close(produced)
+	}()
+	done := make(chan struct{})
+	go func() {
+		g.Run(time.Hour, stopCh)
+		close(done)
+	}()
+	close(stopCh)
+	for _, ch := range []chan struct{}{produced, done} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatal("Generator not stopped in 1s")
+		}
+	}
+	// The deliveries file is flushed and closed
+	data, err := ioutil.ReadFile(dir + "/deliveries.0")
+	if err != nil {
+		t.Fatal("Unexpected error reading deliveries", err)
+	}
+	if !strings.HasPrefix(string(data), "# DONE") {
+		t.Errorf("Unexpected deliveries file content %q", data)
+	}
+}
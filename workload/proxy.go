@@ -36,3 +36,8 @@ func (g *Generator) GetValue() types.Value {
 		return nil
 	}
 }
+
+// ReportEvidence reports that the proposer of an epoch has equivocated.
+func (g *Generator) ReportEvidence(evidence *consensus.Evidence) {
+	g.log.Println("Proposer", evidence.Proposer, "equivocated in epoch", evidence.Epoch)
+}
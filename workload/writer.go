@@ -2,11 +2,15 @@ package workload
 
 import (
 	"bufio"
+	"encoding/base64"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
 )
 
 // Writer is a helper to log data to a file.
@@ -50,21 +54,62 @@ func (w *Writer) Close() {
 }
 
 // LogDelivery logs the information of a delivery.
+//
+// Each delivery is logged in a line with tab-separated fields: time, height,
+// epoch, block ID, previous block ID and, if there is an associated
+// submission, the latency in seconds.
 func (w *Writer) LogDelivery(d *Delivery) {
+	w.buffer.WriteString(FormatDelivery(d))
+}
+
+// FormatDelivery returns the line logged for a delivery.
+func FormatDelivery(d *Delivery) string {
 	var out strings.Builder
 	out.WriteString(formatTime(d.Time))
 	out.WriteString("\t")
 	out.WriteString(fmt.Sprint(d.Height))
-	//	out.WriteString("\t")
-	//	out.WriteString(fmt.Sprint(d.Epoch))
-	//	out.WriteString("\t")
-	//	out.WriteString(base64.RawStdEncoding.EncodeToString(d.BlockID))
+	out.WriteString("\t")
+	out.WriteString(fmt.Sprint(d.Epoch))
+	out.WriteString("\t")
+	out.WriteString(formatBlockID(d.BlockID))
+	out.WriteString("\t")
+	out.WriteString(formatBlockID(d.PrevBlockID))
 	if d.Submission != nil {
 		out.WriteString("\t")
 		out.WriteString(fmt.Sprintf("%.6f", d.Latency().Seconds()))
 	}
 	out.WriteString("\n")
-	w.buffer.WriteString(out.String())
+	return out.String()
+}
+
+// ParseDelivery parses a line logged by LogDelivery.
+//
+// The parsed delivery has no value nor associated submission. Its time has
+// only the time-of-day portion of the logged time.
+func ParseDelivery(line string) (*Delivery, error) {
+	fields := strings.Split(strings.TrimSpace(line), "\t")
+	if len(fields) < 5 {
+		return nil, fmt.Errorf("Invalid delivery line, %d fields: %q",
+			len(fields), line)
+	}
+	var d Delivery
+	var err error
+	if d.Time, err = time.Parse(timeLayout, fields[0]); err != nil {
+		return nil, err
+	}
+	if d.Height, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+		return nil, err
+	}
+	if d.Epoch, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+		return nil, err
+	}
+	if d.BlockID, err = parseBlockID(fields[3]); err != nil {
+		return nil, err
+	}
+	if d.PrevBlockID, err = parseBlockID(fields[4]); err != nil {
+		return nil, err
+	}
+	return &d, nil
 }
 
 // String returns string information about this writer.
@@ -75,8 +120,32 @@ func (w *Writer) String() string {
 
 //// Helpers
 
+const timeLayout = "15:04:05.00000"
+
 func formatTime(t time.Time) string {
-	return t.Format("15:04:05.00000")
+	return t.Format(timeLayout)
+}
+
+// Block IDs are logged in base64, a nil block ID is logged as "-".
+func formatBlockID(id consensus.BlockID) string {
+	if id == nil {
+		return "-"
+	}
+	return base64.RawStdEncoding.EncodeToString(id)
+}
+
+func parseBlockID(field string) (consensus.BlockID, error) {
+	if field == "-" {
+		return nil, nil
+	}
+	id, err := base64.RawStdEncoding.DecodeString(field)
+	if err != nil {
+		return nil, err
+	}
+	if len(id) != consensus.BlockIDSize {
+		return nil, fmt.Errorf("Invalid block ID size %d: %q", len(id), field)
+	}
+	return consensus.BlockID(id), nil
 }
 
 func checkDirectory(dir string) error {
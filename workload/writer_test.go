@@ -3,6 +3,8 @@ package workload
 import (
 	"os/exec"
 	"testing"
+
+	"dslab.inf.usi.ch/tendermint/consensus"
 )
 
 func testRemovePath(t *testing.T, path string) {
@@ -43,3 +45,35 @@ func TestWriterDirectory(t *testing.T) {
 		t.Error("Expected to check dir", dir, err)
 	}
 }
+
+func TestDeliveryFormat(t *testing.T) {
+	prev := consensus.NewBlock([]byte("prev"), nil)
+	block := consensus.NewBlock([]byte("value"), prev)
+	for _, b := range []*consensus.Block{prev, block} {
+		d := NewDelivery(7, b)
+		parsed, err := ParseDelivery(FormatDelivery(d))
+		if err != nil {
+			t.Fatal("Unexpected error parsing delivery", err)
+		}
+		if parsed.Epoch != d.Epoch || parsed.Height != d.Height {
+			t.Error("Expected epoch and height", d.Epoch, d.Height,
+				"got", parsed.Epoch, parsed.Height)
+		}
+		if !parsed.BlockID.Equal(d.BlockID) ||
+			!parsed.PrevBlockID.Equal(d.PrevBlockID) {
+			t.Error("Expected block IDs", d.BlockID, d.PrevBlockID,
+				"got", parsed.BlockID, parsed.PrevBlockID)
+		}
+		if formatTime(parsed.Time) != formatTime(d.Time) {
+			t.Error("Expected time", formatTime(d.Time),
+				"got", formatTime(parsed.Time))
+		}
+	}
+
+	for _, line := range []string{"", "10:00:00.00000\t1\t1",
+		"10:00:00.00000\t1\t1\tinvalid\t-"} {
+		if _, err := ParseDelivery(line); err == nil {
+			t.Error("Expected error parsing line", line)
+		}
+	}
+}